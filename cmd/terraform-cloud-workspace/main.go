@@ -0,0 +1,284 @@
+// Command terraform-cloud-workspace runs the workspace action outside of GitHub Actions, for example locally or from another CI system
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sethvargo/go-githubactions"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/action"
+)
+
+const usage = `Usage: terraform-cloud-workspace <command> [flags]
+
+Commands:
+  plan    Plan the workspace changes
+  apply   Plan and apply the workspace changes
+  import  Import the existing workspace resources, and plan or apply
+  render  Render the Terraform configuration without contacting Terraform Cloud
+  drift   Compare the live workspace settings with the configuration
+
+Run "terraform-cloud-workspace <command> -h" for the flags of a command.
+`
+
+// errUsage is returned when the command line can't be parsed, after the usage has been printed
+var errUsage = errors.New("invalid usage")
+
+// commonFlags are the flags shared by every command
+type commonFlags struct {
+	configFile             string
+	token                  string
+	host                   string
+	organization           string
+	name                   string
+	workspaces             string
+	runnerTerraformVersion string
+	terraformPath          string
+	terraformMirrorURL     string
+	terraformCacheDir      string
+	providerCacheDir       string
+	providerMirror         string
+	tfeProviderVersion     string
+	outputDirectory        string
+	terraformLogLevel      string
+	engine                 string
+}
+
+func (c *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.configFile, "config", "", "path to a config file holding any of the other settings")
+	fs.StringVar(&c.token, "token", os.Getenv("TFE_TOKEN"), "Terraform Cloud token, defaults to $TFE_TOKEN")
+	fs.StringVar(&c.host, "host", action.DefaultHost, "Terraform Cloud host")
+	fs.StringVar(&c.organization, "organization", "", "Terraform Cloud organization")
+	fs.StringVar(&c.name, "name", "", "name of the workspace, or prefix if workspaces are passed")
+	fs.StringVar(&c.workspaces, "workspaces", "", "comma separated list of workspace names")
+	fs.StringVar(&c.runnerTerraformVersion, "runner-terraform-version", action.DefaultRunnerTerraformVersion, "Terraform version or version constraint used to manage the workspaces")
+	fs.StringVar(&c.terraformPath, "terraform-path", "", "path to a Terraform binary to use instead of downloading Terraform")
+	fs.StringVar(&c.terraformMirrorURL, "terraform-mirror-url", "", "base URL of a mirror of https://releases.hashicorp.com to download Terraform from")
+	fs.StringVar(&c.terraformCacheDir, "terraform-cache-dir", "", "directory that downloaded Terraform binaries are cached in")
+	fs.StringVar(&c.providerCacheDir, "provider-cache-dir", "", "directory that Terraform provider plugins are cached in")
+	fs.StringVar(&c.providerMirror, "provider-mirror", "", "URL of a provider network mirror to install providers from")
+	fs.StringVar(&c.tfeProviderVersion, "tfe-provider-version", action.DefaultTFEProviderVersion, "version of the tfe Terraform provider")
+	fs.StringVar(&c.outputDirectory, "output-directory", "", "directory that keeps the generated configuration and Terraform logs")
+	fs.StringVar(&c.terraformLogLevel, "terraform-log-level", action.DefaultTerraformLogLevel, "level of the Terraform logs kept in the output directory")
+	fs.StringVar(&c.engine, "engine", "", `how the workspaces are reconciled, "terraform" or "api" to use the Terraform Cloud API without downloading Terraform`)
+}
+
+// workspaceNames returns the workspace names of the comma separated workspaces flag
+func (c *commonFlags) workspaceNames() []string {
+	if c.workspaces == "" {
+		return nil
+	}
+
+	names := strings.Split(c.workspaces, ",")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+
+	return names
+}
+
+// overrides returns the functions applying the common flags to the config, by flag name
+func (c *commonFlags) overrides() map[string]func(config *action.Config) {
+	return map[string]func(config *action.Config){
+		"host":                     func(config *action.Config) { config.Host = c.host },
+		"organization":             func(config *action.Config) { config.Organization = c.organization },
+		"name":                     func(config *action.Config) { config.Name = strings.TrimSpace(c.name) },
+		"workspaces":               func(config *action.Config) { config.Workspaces = c.workspaceNames() },
+		"runner-terraform-version": func(config *action.Config) { config.RunnerTerraformVersion = c.runnerTerraformVersion },
+		"terraform-path":           func(config *action.Config) { config.TerraformPath = c.terraformPath },
+		"terraform-mirror-url":     func(config *action.Config) { config.TerraformMirrorURL = c.terraformMirrorURL },
+		"terraform-cache-dir":      func(config *action.Config) { config.TerraformCacheDir = c.terraformCacheDir },
+		"provider-cache-dir":       func(config *action.Config) { config.ProviderCacheDir = c.providerCacheDir },
+		"provider-mirror":          func(config *action.Config) { config.ProviderMirror = c.providerMirror },
+		"tfe-provider-version":     func(config *action.Config) { config.TFEProviderVersion = c.tfeProviderVersion },
+		"output-directory":         func(config *action.Config) { config.OutputDirectory = c.outputDirectory },
+		"terraform-log-level":      func(config *action.Config) { config.TerraformLogLevel = c.terraformLogLevel },
+		"engine":                   func(config *action.Config) { config.Engine = c.engine },
+	}
+}
+
+// inputs returns the action inputs of the common flags
+func (c *commonFlags) inputs() (*action.Inputs, error) {
+	workspaces := ""
+
+	if names := c.workspaceNames(); names != nil {
+		b, err := json.Marshal(names)
+		if err != nil {
+			return nil, err
+		}
+
+		workspaces = string(b)
+	}
+
+	return &action.Inputs{
+		ConfigFile:             c.configFile,
+		Token:                  c.token,
+		Host:                   c.host,
+		Organization:           c.organization,
+		Name:                   strings.TrimSpace(c.name),
+		Workspaces:             workspaces,
+		RunnerTerraformVersion: c.runnerTerraformVersion,
+		TerraformPath:          c.terraformPath,
+		TerraformMirrorURL:     c.terraformMirrorURL,
+		TerraformCacheDir:      c.terraformCacheDir,
+		ProviderCacheDir:       c.providerCacheDir,
+		ProviderMirror:         c.providerMirror,
+		TFEProviderVersion:     c.tfeProviderVersion,
+		OutputDirectory:        c.outputDirectory,
+		TerraformLogLevel:      c.terraformLogLevel,
+		Engine:                 c.engine,
+	}, nil
+}
+
+// parseArgs returns the action inputs of the passed command line arguments, without the program name
+func parseArgs(args []string, output io.Writer) (*action.Inputs, error) {
+	if len(args) == 0 {
+		fmt.Fprint(output, usage)
+		return nil, errUsage
+	}
+
+	command := args[0]
+
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	common := &commonFlags{}
+	common.register(fs)
+
+	var (
+		importResources        bool
+		apply                  bool
+		planOnly               bool
+		planArtifact           string
+		allowWorkspaceDeletion bool
+	)
+
+	switch command {
+	case "plan":
+		fs.BoolVar(&importResources, "import", true, "import existing resources into a local copy of the state before planning")
+		fs.BoolVar(&planOnly, "plan-only", false, "plan against a copy of the state read without locking it, for read-only tokens")
+		fs.StringVar(&planArtifact, "plan-artifact", "", "directory to save the plan to, for a later apply")
+	case "apply":
+		fs.BoolVar(&importResources, "import", true, "import existing resources before planning")
+		fs.StringVar(&planArtifact, "plan-artifact", "", "directory of a plan saved by the plan command to apply")
+		fs.BoolVar(&allowWorkspaceDeletion, "allow-workspace-deletion", false, "allow workspaces to be deleted, irrecoverably deleting their state")
+	case "import":
+		fs.BoolVar(&apply, "apply", false, "apply the plan, without it the imports are only previewed in the plan")
+		fs.BoolVar(&allowWorkspaceDeletion, "allow-workspace-deletion", false, "allow workspaces to be deleted, irrecoverably deleting their state")
+	case "render", "drift":
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(output, usage)
+		return nil, flag.ErrHelp
+	default:
+		fmt.Fprintf(output, "Unknown command %q\n\n%s", command, usage)
+		return nil, errUsage
+	}
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+
+	if fs.NArg() > 0 {
+		fmt.Fprintf(output, "Unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return nil, errUsage
+	}
+
+	inputs, err := common.inputs()
+	if err != nil {
+		return nil, err
+	}
+
+	inputs.AllowWorkspaceDeletion = allowWorkspaceDeletion
+
+	overrides := common.overrides()
+	overrides["import"] = func(config *action.Config) { config.Import = importResources }
+	overrides["plan-artifact"] = func(config *action.Config) { config.PlanArtifact = planArtifact }
+	overrides["allow-workspace-deletion"] = func(config *action.Config) { config.AllowWorkspaceDeletion = allowWorkspaceDeletion }
+
+	switch command {
+	case "plan":
+		inputs.Import = importResources
+
+		if planOnly && planArtifact != "" {
+			return nil, fmt.Errorf("-plan-only and -plan-artifact can't be combined")
+		}
+
+		if planOnly {
+			inputs.Mode = action.ModePlanOnly
+		} else if planArtifact != "" {
+			inputs.Mode = action.ModePlan
+			inputs.PlanArtifact = planArtifact
+		}
+	case "apply":
+		inputs.Import = importResources
+		inputs.Apply = planArtifact == ""
+
+		if planArtifact != "" {
+			inputs.Mode = action.ModeApply
+			inputs.PlanArtifact = planArtifact
+		}
+	case "import":
+		inputs.Import = true
+		inputs.Apply = apply
+	case "render":
+		inputs.Mode = action.ModeRender
+	case "drift":
+		inputs.Mode = action.ModeDriftCheck
+	}
+
+	var passed []string
+
+	fs.Visit(func(f *flag.Flag) {
+		passed = append(passed, f.Name)
+	})
+
+	// the config file is applied over the inputs, so the passed flags and the command are applied again over the file
+	inputs.Override = func(config *action.Config) {
+		for _, name := range passed {
+			if override, ok := overrides[name]; ok {
+				override(config)
+			}
+		}
+
+		config.Mode = inputs.Mode
+		config.Apply = inputs.Apply
+
+		if command == "import" {
+			config.Import = true
+		}
+	}
+
+	return inputs, nil
+}
+
+func main() {
+	inputs, err := parseArgs(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if errors.Is(err, errUsage) {
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(2)
+	}
+
+	w := newHumanWriter(os.Stdout)
+
+	action.SetActions(githubactions.New(githubactions.WithWriter(w)))
+
+	err = action.Run(inputs)
+
+	if flushErr := w.Flush(); flushErr != nil && err == nil {
+		err = flushErr
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
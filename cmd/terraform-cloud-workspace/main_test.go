@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sethvargo/go-githubactions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/action"
+)
+
+func TestParseArgs(t *testing.T) {
+	t.Setenv("TFE_TOKEN", "secret")
+
+	for _, testCase := range []struct {
+		Description string
+		Args        []string
+		Expect      func(inputs *action.Inputs)
+	}{
+		{"plan", []string{"plan", "-name", "foo", "-workspaces", "staging, production"}, func(inputs *action.Inputs) {
+			inputs.Name = "foo"
+			inputs.Workspaces = `["staging","production"]`
+			inputs.Import = true
+		}},
+		{"plan only", []string{"plan", "-plan-only", "-import=false"}, func(inputs *action.Inputs) {
+			inputs.Mode = action.ModePlanOnly
+		}},
+		{"save the plan", []string{"plan", "-plan-artifact", "tfplan"}, func(inputs *action.Inputs) {
+			inputs.Import = true
+			inputs.Mode = action.ModePlan
+			inputs.PlanArtifact = "tfplan"
+		}},
+		{"apply", []string{"apply", "-config", "workspace.yml"}, func(inputs *action.Inputs) {
+			inputs.ConfigFile = "workspace.yml"
+			inputs.Import = true
+			inputs.Apply = true
+		}},
+		{"apply through the API", []string{"apply", "-engine", "api"}, func(inputs *action.Inputs) {
+			inputs.Import = true
+			inputs.Apply = true
+			inputs.Engine = action.EngineAPI
+		}},
+		{"apply a saved plan", []string{"apply", "-plan-artifact", "tfplan"}, func(inputs *action.Inputs) {
+			inputs.Import = true
+			inputs.Mode = action.ModeApply
+			inputs.PlanArtifact = "tfplan"
+		}},
+		{"import", []string{"import", "-apply"}, func(inputs *action.Inputs) {
+			inputs.Import = true
+			inputs.Apply = true
+		}},
+		{"render", []string{"render", "-output-directory", "out", "-terraform-log-level", "DEBUG"}, func(inputs *action.Inputs) {
+			inputs.Mode = action.ModeRender
+			inputs.OutputDirectory = "out"
+			inputs.TerraformLogLevel = "DEBUG"
+		}},
+		{"drift", []string{"drift", "-host", "tfe.example.com"}, func(inputs *action.Inputs) {
+			inputs.Mode = action.ModeDriftCheck
+			inputs.Host = "tfe.example.com"
+		}},
+	} {
+		t.Run(testCase.Description, func(t *testing.T) {
+			expect := &action.Inputs{
+				Token:                  "secret",
+				Host:                   action.DefaultHost,
+				RunnerTerraformVersion: action.DefaultRunnerTerraformVersion,
+				TFEProviderVersion:     action.DefaultTFEProviderVersion,
+				TerraformLogLevel:      action.DefaultTerraformLogLevel,
+			}
+			testCase.Expect(expect)
+
+			inputs, err := parseArgs(testCase.Args, io.Discard)
+			require.NoError(t, err)
+
+			assert.NotNil(t, inputs.Override)
+			inputs.Override = nil
+
+			assert.Equal(t, expect, inputs)
+		})
+	}
+
+	t.Run("flags take precedence over the config file", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "workspace.yml")
+		require.NoError(t, os.WriteFile(filePath, []byte("version: 1\nterraform_organization: file\nterraform_host: tfe.example.com\napply: true\nimport: false\n"), 0644))
+
+		inputs, err := parseArgs([]string{"plan", "-config", filePath, "-organization", "flags"}, io.Discard)
+		require.NoError(t, err)
+
+		config, err := action.NewConfig(inputs)
+		require.NoError(t, err)
+
+		assert.Equal(t, "flags", config.Organization)
+		assert.Equal(t, "tfe.example.com", config.Host, "the config file takes precedence over the flag defaults")
+		assert.False(t, config.Import)
+		assert.False(t, config.Apply, "the command takes precedence over the config file")
+	})
+
+	t.Run("reject invalid usage", func(t *testing.T) {
+		_, err := parseArgs(nil, io.Discard)
+		assert.ErrorIs(t, err, errUsage)
+
+		_, err = parseArgs([]string{"destroy"}, io.Discard)
+		assert.ErrorIs(t, err, errUsage)
+
+		_, err = parseArgs([]string{"render", "extra"}, io.Discard)
+		assert.ErrorIs(t, err, errUsage)
+
+		_, err = parseArgs([]string{"help"}, io.Discard)
+		assert.ErrorIs(t, err, flag.ErrHelp)
+
+		_, err = parseArgs([]string{"plan", "-plan-only", "-plan-artifact", "tfplan"}, io.Discard)
+		assert.EqualError(t, err, "-plan-only and -plan-artifact can't be combined")
+	})
+}
+
+func TestHumanWriter(t *testing.T) {
+	var out bytes.Buffer
+
+	w := newHumanWriter(&out)
+	gha := githubactions.New(githubactions.WithWriter(w))
+
+	gha.Infof("Planning...\n")
+	gha.AddMask("secret")
+	gha.Debugf("debugging")
+	gha.SetOutput("has_changes", "true")
+	gha.SetOutput("plan", "long\nplan")
+	gha.Warningf("Drift detected: %s", "50%\nchanged")
+	gha.Infof("Done")
+
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, "Planning...\n"+
+		"Output has_changes: true\n"+
+		"Warning: Drift detected: 50%\nchanged\n"+
+		"Done", out.String())
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// hiddenOutputs are the outputs that are too large to print, the plan and configuration are already logged
+var hiddenOutputs = map[string]bool{
+	"plan":             true,
+	"plan_json":        true,
+	"terraform_config": true,
+}
+
+var commandDataUnescaper = strings.NewReplacer("%0D", "\r", "%0A", "\n", "%25", "%")
+
+var commandPropertyUnescaper = strings.NewReplacer("%0D", "\r", "%0A", "\n", "%3A", ":", "%2C", ",", "%25", "%")
+
+// humanWriter translates the GitHub Actions workflow commands written by the action into human readable output, other lines are written as is
+type humanWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func newHumanWriter(w io.Writer) *humanWriter {
+	return &humanWriter{w: w}
+}
+
+// Write buffers the passed bytes and translates every complete line
+func (h *humanWriter) Write(p []byte) (int, error) {
+	h.buf.Write(p)
+
+	for {
+		i := bytes.IndexByte(h.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+
+		line := string(h.buf.Next(i + 1))
+
+		if err := h.writeLine(strings.TrimSuffix(line, "\n"), "\n"); err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the last incomplete line, if any
+func (h *humanWriter) Flush() error {
+	if h.buf.Len() == 0 {
+		return nil
+	}
+
+	line := h.buf.String()
+	h.buf.Reset()
+
+	return h.writeLine(line, "")
+}
+
+func (h *humanWriter) writeLine(line string, eol string) error {
+	if !strings.HasPrefix(line, "::") {
+		_, err := io.WriteString(h.w, line+eol)
+		return err
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(line, "::"), "::", 2)
+	if len(parts) != 2 {
+		_, err := io.WriteString(h.w, line+eol)
+		return err
+	}
+
+	name, properties, _ := strings.Cut(parts[0], " ")
+	message := commandDataUnescaper.Replace(parts[1])
+
+	var out string
+
+	switch name {
+	case "add-mask", "debug":
+		return nil
+	case "set-output":
+		output := ""
+
+		for _, p := range strings.Split(properties, ",") {
+			if k, v, ok := strings.Cut(p, "="); ok && k == "name" {
+				output = commandPropertyUnescaper.Replace(v)
+			}
+		}
+
+		if hiddenOutputs[output] {
+			return nil
+		}
+
+		out = fmt.Sprintf("Output %s: %s", output, message)
+	case "warning":
+		out = "Warning: " + message
+	case "error":
+		out = "Error: " + message
+	default:
+		out = message
+	}
+
+	_, err := io.WriteString(h.w, out+"\n")
+
+	return err
+}
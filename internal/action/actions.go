@@ -0,0 +1,11 @@
+package action
+
+import "github.com/sethvargo/go-githubactions"
+
+// gha logs messages and sets the step outputs, as GitHub Actions workflow commands written to stdout unless replaced with SetActions
+var gha = githubactions.New()
+
+// SetActions replaces the client used to log messages and set outputs, so the action can run outside of GitHub Actions
+func SetActions(a *githubactions.Action) {
+	gha = a
+}
@@ -0,0 +1,1189 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	tfe "github.com/hashicorp/go-tfe"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
+)
+
+// EngineTerraform reconciles the workspaces by planning and applying the generated configuration with Terraform
+const EngineTerraform = "terraform"
+
+// EngineAPI reconciles the workspaces directly through the Terraform Cloud API, without downloading or running Terraform
+const EngineAPI = "api"
+
+// APIChange is a change planned by the API engine. The change is described as a Terraform resource change,
+// so it is summarized, counted and checked against the destroy protection policy like a Terraform plan.
+type APIChange struct {
+	ResourceChange *tfjson.ResourceChange
+
+	apply func(ctx context.Context) error
+}
+
+// APIPlan is the ordered list of changes planned by the API engine, workspaces are changed before the resources that belong to them
+type APIPlan []*APIChange
+
+// newAPIChange returns a change of the resource with the passed type, name and for_each key, a nil key is used for resources without for_each
+func newAPIChange(resourceType string, name string, key interface{}, actions tfjson.Actions, before map[string]interface{}, after map[string]interface{}, apply func(ctx context.Context) error) *APIChange {
+	address := fmt.Sprintf("%s.%s", resourceType, name)
+	if key != nil {
+		address = fmt.Sprintf("%s[%q]", address, key)
+	}
+
+	change := &tfjson.Change{Actions: actions}
+
+	if before != nil {
+		change.Before = before
+	}
+
+	if after != nil {
+		change.After = after
+	}
+
+	return &APIChange{
+		ResourceChange: &tfjson.ResourceChange{
+			Address: address,
+			Mode:    tfjson.ManagedResourceMode,
+			Type:    resourceType,
+			Name:    name,
+			Index:   key,
+			Change:  change,
+		},
+		apply: apply,
+	}
+}
+
+// withSensitive marks the passed attributes as sensitive before and after the change
+func (c *APIChange) withSensitive(attributes ...string) *APIChange {
+	sensitive := map[string]interface{}{}
+	for _, attr := range attributes {
+		sensitive[attr] = true
+	}
+
+	c.ResourceChange.Change.BeforeSensitive = sensitive
+	c.ResourceChange.Change.AfterSensitive = sensitive
+
+	return c
+}
+
+// withUnknown marks the passed attributes as only known once the change is applied
+func (c *APIChange) withUnknown(attributes ...string) *APIChange {
+	unknown := map[string]interface{}{}
+	for _, attr := range attributes {
+		unknown[attr] = true
+	}
+
+	c.ResourceChange.Change.AfterUnknown = unknown
+
+	return c
+}
+
+// Plan returns the changes as a Terraform plan
+func (p APIPlan) Plan() *tfjson.Plan {
+	plan := &tfjson.Plan{FormatVersion: "1.0"}
+
+	for _, c := range p {
+		plan.ResourceChanges = append(plan.ResourceChanges, c.ResourceChange)
+	}
+
+	return plan
+}
+
+// Apply makes the planned API calls in order, stopping at the first failure
+func (p APIPlan) Apply(ctx context.Context) error {
+	for _, c := range p {
+		action := changeAction(c.ResourceChange.Change.Actions)
+
+		gha.Infof("%s: %s\n", c.ResourceChange.Address, apiChangeVerbs[action])
+
+		if err := c.apply(ctx); err != nil {
+			return fmt.Errorf("failed to %s %s: %w", action, c.ResourceChange.Address, err)
+		}
+	}
+
+	return nil
+}
+
+// apiChangeVerbs are the progress messages of each change action, worded the way Terraform words them
+var apiChangeVerbs = map[string]string{
+	"create":  "Creating...",
+	"update":  "Modifying...",
+	"replace": "Replacing...",
+	"destroy": "Destroying...",
+}
+
+// apiChangeHeaders describe each change action in the plan output, worded the way Terraform words them
+var apiChangeHeaders = map[string]struct {
+	Symbol      string
+	Description string
+}{
+	"create":  {"  +", "will be created"},
+	"update":  {"  ~", "will be updated in-place"},
+	"replace": {"-/+", "must be replaced"},
+	"destroy": {"  -", "will be destroyed"},
+}
+
+// String renders the changes the way "terraform show" renders a plan
+func (p APIPlan) String() string {
+	var b strings.Builder
+
+	b.WriteString("The Terraform Cloud API engine will perform the following actions:\n")
+
+	for _, c := range p {
+		rc := c.ResourceChange
+		action := changeAction(rc.Change.Actions)
+		header := apiChangeHeaders[action]
+
+		fmt.Fprintf(&b, "\n  # %s %s\n", rc.Address, header.Description)
+		fmt.Fprintf(&b, "%s resource %q %q {\n", header.Symbol, rc.Type, rc.Name)
+
+		before, _ := rc.Change.Before.(map[string]interface{})
+		after, _ := rc.Change.After.(map[string]interface{})
+		sensitive, _ := rc.Change.AfterSensitive.(map[string]interface{})
+		unknown, _ := rc.Change.AfterUnknown.(map[string]interface{})
+
+		keys := []string{}
+		for k := range before {
+			keys = append(keys, k)
+		}
+
+		for k := range after {
+			if _, ok := before[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+
+		for k := range unknown {
+			_, inBefore := before[k]
+			_, inAfter := after[k]
+
+			if !inBefore && !inAfter {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			beforeValue := planValue(rc.Type, k, before, sensitive, nil)
+			afterValue := planValue(rc.Type, k, after, sensitive, unknown)
+
+			switch action {
+			case "create":
+				fmt.Fprintf(&b, "      + %s = %s\n", k, afterValue)
+			case "destroy":
+				fmt.Fprintf(&b, "      - %s = %s\n", k, beforeValue)
+			default:
+				fmt.Fprintf(&b, "      ~ %s = %s -> %s\n", k, beforeValue, afterValue)
+			}
+		}
+
+		b.WriteString("    }\n")
+	}
+
+	outputs := NewPlanOutputs(p.Plan(), nil)
+
+	fmt.Fprintf(&b, "\nPlan: %d to add, %d to change, %d to destroy.\n", outputs.AddCount, outputs.ChangeCount, outputs.DestroyCount)
+
+	return b.String()
+}
+
+// planValue formats an attribute value for the plan output. Sensitive values and the values of sensitive variables are redacted.
+func planValue(resourceType string, attr string, object map[string]interface{}, sensitive map[string]interface{}, unknown map[string]interface{}) string {
+	if unknown[attr] == true {
+		return "(known after apply)"
+	}
+
+	value, ok := object[attr]
+	if !ok || value == nil {
+		return "null"
+	}
+
+	if sensitive[attr] == true || (resourceType == "tfe_variable" && attr == "value" && object["sensitive"] == true) {
+		return "(sensitive value)"
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "(unknown)"
+	}
+
+	return string(b)
+}
+
+// diffAttributes returns the desired attributes whose live value differs, with their live and desired values. The skipped attributes are not compared.
+func diffAttributes(live map[string]interface{}, desired map[string]interface{}, skip ...string) (map[string]interface{}, map[string]interface{}) {
+	before := map[string]interface{}{}
+	after := map[string]interface{}{}
+
+	for k, v := range desired {
+		if stringInSlice(k, skip) || reflect.DeepEqual(live[k], v) {
+			continue
+		}
+
+		before[k] = live[k]
+		after[k] = v
+	}
+
+	return before, after
+}
+
+// stringInSlice returns whether the passed string is in the slice
+func stringInSlice(s string, slice []string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// optionalString returns a pointer to the passed string, or nil if it is empty
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+// PlanAPIChanges compares the live Terraform Cloud settings of the workspaces with the desired configuration and plans the API calls reconciling them.
+// Workspaces are matched by ID and the other resources by name within their workspace. Like an import, variables, team access and run triggers
+// that are not configured are destroyed, while workspaces are never destroyed.
+func PlanAPIChanges(ctx context.Context, client *tfe.Client, workspaces []*Workspace, config *NewWorkspaceConfigOptions) (APIPlan, error) {
+	options := config.WorkspaceResourceOptions
+
+	// the workspace resource resolves the OAuth token of the configured VCS type
+	resource, err := NewWorkspaceResource(ctx, client, workspaces, options)
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := EffectiveWorkspaceSettings(workspaces, options)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := FetchRelatedTeams(ctx, client, options.Organization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch teams: %w", err)
+	}
+
+	plan := APIPlan{}
+	resources := APIPlan{}
+
+	for _, ws := range workspaces {
+		change, err := planWorkspace(ctx, client, ws, settings[ws.Workspace], options, resource.VCSRepo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan workspace %q: %w", ws.Name, err)
+		}
+
+		if change != nil {
+			plan = append(plan, change)
+		}
+
+		for _, planResources := range []func() (APIPlan, error){
+			func() (APIPlan, error) { return planVariables(ctx, client, ws, config.Variables) },
+			func() (APIPlan, error) { return planTeamAccess(ctx, client, ws, config.TeamAccess, teams) },
+			func() (APIPlan, error) {
+				return planRunTriggers(ctx, client, ws, config.RunTriggers, workspaces, options.Organization)
+			},
+			func() (APIPlan, error) { return planNotifications(ctx, client, ws, config.Notifications) },
+		} {
+			changes, err := planResources()
+			if err != nil {
+				return nil, fmt.Errorf("failed to plan the resources of workspace %q: %w", ws.Name, err)
+			}
+
+			resources = append(resources, changes...)
+		}
+	}
+
+	return append(plan, resources...), nil
+}
+
+// workspaceAttributes returns the configured attributes of a workspace, named the way WorkspaceDrift names them
+func workspaceAttributes(ws *Workspace, settings WorkspaceSettingsInput, options *WorkspaceResourceOptions, vcs *tfeprovider.VCSRepo) map[string]interface{} {
+	attrs := map[string]interface{}{"name": ws.Name}
+
+	if settings.AutoApply != nil {
+		attrs["auto_apply"] = *settings.AutoApply
+	}
+
+	for name, value := range map[string]string{
+		"agent_pool_id":     settings.AgentPoolID,
+		"description":       settings.Description,
+		"execution_mode":    settings.ExecutionMode,
+		"ssh_key_id":        settings.SSHKeyID,
+		"terraform_version": settings.TerraformVersion,
+		"working_directory": settings.WorkingDirectory,
+	} {
+		if value != "" {
+			attrs[name] = value
+		}
+	}
+
+	for name, value := range map[string]*bool{
+		"file_triggers_enabled": options.FileTriggersEnabled,
+		"global_remote_state":   options.GlobalRemoteState,
+		"queue_all_runs":        options.QueueAllRuns,
+		"speculative_enabled":   options.SpeculativeEnabled,
+	} {
+		if value != nil {
+			attrs[name] = *value
+		}
+	}
+
+	if tags := sortedTagNames(options.Tags[ws.Workspace]); len(tags) > 0 {
+		attrs["tag_names"] = tags
+	}
+
+	if consumers := remoteStateConsumerIDs(options); consumers != nil {
+		attrs["remote_state_consumer_ids"] = consumers
+	}
+
+	if vcs != nil {
+		attrs["vcs_repo.identifier"] = vcs.Identifier
+		attrs["vcs_repo.ingress_submodules"] = vcs.IngressSubmodules
+		attrs["vcs_repo.oauth_token_id"] = vcs.OauthTokenID
+	}
+
+	return attrs
+}
+
+// sortedTagNames returns the passed tags as a sorted list of names
+func sortedTagNames(tags Tags) []string {
+	names := []string{}
+	for _, t := range tags {
+		names = append(names, string(t))
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// remoteStateConsumerIDs returns the sorted IDs of the workspaces allowed to read the workspace state, nil is returned if every workspace is allowed
+func remoteStateConsumerIDs(options *WorkspaceResourceOptions) []string {
+	if options.GlobalRemoteState == nil || *options.GlobalRemoteState {
+		return nil
+	}
+
+	ids := strings.FieldsFunc(options.RemoteStateConsumerIDs, func(c rune) bool { return c == ',' })
+	for i := range ids {
+		ids[i] = strings.TrimSpace(ids[i])
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// workspaceUpdateOptions returns the API options setting the configured attributes of a workspace
+func workspaceUpdateOptions(ws *Workspace, settings WorkspaceSettingsInput, options *WorkspaceResourceOptions, vcs *tfeprovider.VCSRepo) tfe.WorkspaceUpdateOptions {
+	opts := tfe.WorkspaceUpdateOptions{
+		Name:                tfe.String(ws.Name),
+		AgentPoolID:         optionalString(settings.AgentPoolID),
+		AutoApply:           settings.AutoApply,
+		Description:         optionalString(settings.Description),
+		ExecutionMode:       optionalString(settings.ExecutionMode),
+		FileTriggersEnabled: options.FileTriggersEnabled,
+		GlobalRemoteState:   options.GlobalRemoteState,
+		QueueAllRuns:        options.QueueAllRuns,
+		SpeculativeEnabled:  options.SpeculativeEnabled,
+		TerraformVersion:    optionalString(settings.TerraformVersion),
+		WorkingDirectory:    optionalString(settings.WorkingDirectory),
+	}
+
+	if vcs != nil {
+		opts.VCSRepo = &tfe.VCSRepoOptions{
+			Identifier:        tfe.String(vcs.Identifier),
+			IngressSubmodules: tfe.Bool(vcs.IngressSubmodules),
+			OAuthTokenID:      tfe.String(vcs.OauthTokenID),
+		}
+	}
+
+	return opts
+}
+
+// planWorkspace plans the creation of a missing workspace or the update of the drifted attributes of an existing workspace, nil is returned if the workspace is up to date
+func planWorkspace(ctx context.Context, client *tfe.Client, ws *Workspace, settings WorkspaceSettingsInput, options *WorkspaceResourceOptions, vcs *tfeprovider.VCSRepo) (*APIChange, error) {
+	opts := workspaceUpdateOptions(ws, settings, options, vcs)
+	consumers := remoteStateConsumerIDs(options)
+
+	if ws.ID == nil {
+		return newAPIChange("tfe_workspace", "workspace", ws.Workspace, tfjson.Actions{tfjson.ActionCreate}, nil, workspaceAttributes(ws, settings, options, vcs), func(ctx context.Context) error {
+			created, err := client.Workspaces.Create(ctx, options.Organization, tfe.WorkspaceCreateOptions{
+				Name:                opts.Name,
+				AgentPoolID:         opts.AgentPoolID,
+				AutoApply:           opts.AutoApply,
+				Description:         opts.Description,
+				ExecutionMode:       opts.ExecutionMode,
+				FileTriggersEnabled: opts.FileTriggersEnabled,
+				GlobalRemoteState:   opts.GlobalRemoteState,
+				QueueAllRuns:        opts.QueueAllRuns,
+				SpeculativeEnabled:  opts.SpeculativeEnabled,
+				TerraformVersion:    opts.TerraformVersion,
+				WorkingDirectory:    opts.WorkingDirectory,
+				VCSRepo:             opts.VCSRepo,
+				Tags:                apiTags(sortedTagNames(options.Tags[ws.Workspace])),
+			})
+			if err != nil {
+				return err
+			}
+
+			ws.ID = &created.ID
+
+			if settings.SSHKeyID != "" {
+				if _, err := client.Workspaces.AssignSSHKey(ctx, created.ID, tfe.WorkspaceAssignSSHKeyOptions{SSHKeyID: tfe.String(settings.SSHKeyID)}); err != nil {
+					return err
+				}
+			}
+
+			if len(consumers) > 0 {
+				return client.Workspaces.UpdateRemoteStateConsumers(ctx, created.ID, tfe.WorkspaceUpdateRemoteStateConsumersOptions{Workspaces: apiWorkspaces(consumers)})
+			}
+
+			return nil
+		}), nil
+	}
+
+	live, err := client.Workspaces.ReadByID(ctx, *ws.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	drifts := WorkspaceDrift(live, ws, settings, options)
+	if live.Name != ws.Name {
+		drifts = compareAttribute(drifts, Drift{}, "name", ws.Name, live.Name)
+	}
+
+	if consumers != nil {
+		liveConsumers, err := listAll(ctx, func(ctx context.Context, listOptions tfe.ListOptions) ([]*tfe.Workspace, *tfe.Pagination, error) {
+			list, err := client.Workspaces.ListRemoteStateConsumers(ctx, live.ID, &tfe.RemoteStateConsumersListOptions{ListOptions: listOptions})
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return list.Items, list.Pagination, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list remote state consumers: %w", err)
+		}
+
+		liveIDs := []string{}
+		for _, c := range liveConsumers {
+			liveIDs = append(liveIDs, c.ID)
+		}
+
+		sort.Strings(liveIDs)
+
+		drifts = compareAttribute(drifts, Drift{}, "remote_state_consumer_ids", consumers, liveIDs)
+	}
+
+	if len(drifts) == 0 {
+		return nil, nil
+	}
+
+	before := map[string]interface{}{}
+	after := map[string]interface{}{}
+	drifted := map[string]bool{}
+
+	for _, d := range drifts {
+		before[d.Attribute] = d.Live
+		after[d.Attribute] = d.Desired
+		drifted[d.Attribute] = true
+	}
+
+	return newAPIChange("tfe_workspace", "workspace", ws.Workspace, tfjson.Actions{tfjson.ActionUpdate}, before, after, func(ctx context.Context) error {
+		if drifted["vcs_repo"] && vcs == nil {
+			if _, err := client.Workspaces.RemoveVCSConnectionByID(ctx, live.ID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := client.Workspaces.UpdateByID(ctx, live.ID, opts); err != nil {
+			return err
+		}
+
+		if drifted["ssh_key_id"] {
+			if _, err := client.Workspaces.AssignSSHKey(ctx, live.ID, tfe.WorkspaceAssignSSHKeyOptions{SSHKeyID: tfe.String(settings.SSHKeyID)}); err != nil {
+				return err
+			}
+		}
+
+		if drifted["tag_names"] {
+			if err := updateTags(ctx, client, live, sortedTagNames(options.Tags[ws.Workspace])); err != nil {
+				return err
+			}
+		}
+
+		if drifted["remote_state_consumer_ids"] {
+			return client.Workspaces.UpdateRemoteStateConsumers(ctx, live.ID, tfe.WorkspaceUpdateRemoteStateConsumersOptions{Workspaces: apiWorkspaces(consumers)})
+		}
+
+		return nil
+	}), nil
+}
+
+// apiTags returns the passed tag names as API tags
+func apiTags(names []string) []*tfe.Tag {
+	var tags []*tfe.Tag
+	for _, name := range names {
+		tags = append(tags, &tfe.Tag{Name: name})
+	}
+
+	return tags
+}
+
+// apiWorkspaces returns the passed workspace IDs as API workspaces
+func apiWorkspaces(ids []string) []*tfe.Workspace {
+	var workspaces []*tfe.Workspace
+	for _, id := range ids {
+		workspaces = append(workspaces, &tfe.Workspace{ID: id})
+	}
+
+	return workspaces
+}
+
+// updateTags adds the missing tags to the live workspace and removes the tags that are not configured
+func updateTags(ctx context.Context, client *tfe.Client, live *tfe.Workspace, names []string) error {
+	var add, remove []string
+
+	for _, name := range names {
+		if !stringInSlice(name, live.TagNames) {
+			add = append(add, name)
+		}
+	}
+
+	for _, name := range live.TagNames {
+		if !stringInSlice(name, names) {
+			remove = append(remove, name)
+		}
+	}
+
+	if len(add) > 0 {
+		if err := client.Workspaces.AddTags(ctx, live.ID, tfe.WorkspaceAddTagsOptions{Tags: apiTags(add)}); err != nil {
+			return err
+		}
+	}
+
+	if len(remove) > 0 {
+		return client.Workspaces.RemoveTags(ctx, live.ID, tfe.WorkspaceRemoveTagsOptions{Tags: apiTags(remove)})
+	}
+
+	return nil
+}
+
+// variableAttributes returns the attributes of a variable, named the way the tfe provider names them
+func variableAttributes(key string, value string, category string, description string, sensitive bool, hcl bool) map[string]interface{} {
+	return map[string]interface{}{
+		"key":         key,
+		"value":       value,
+		"category":    category,
+		"description": description,
+		"sensitive":   sensitive,
+		"hcl":         hcl,
+	}
+}
+
+// variableKey identifies a variable of a workspace, as variables of different categories can share a key
+func variableKey(category string, key string) string {
+	return fmt.Sprintf("%s/%s", category, key)
+}
+
+// planVariables plans the creation, update and deletion of the variables of the passed workspace.
+// The values of sensitive variables can't be read back, so they are written on every run.
+func planVariables(ctx context.Context, client *tfe.Client, ws *Workspace, variables Variables) (APIPlan, error) {
+	var live []*tfe.Variable
+
+	if ws.ID != nil {
+		var err error
+
+		if live, err = FetchRelatedVariables(ctx, client, ws); err != nil {
+			return nil, fmt.Errorf("failed to fetch variables: %w", err)
+		}
+	}
+
+	liveByKey := map[string]*tfe.Variable{}
+	for _, l := range live {
+		liveByKey[variableKey(string(l.Category), l.Key)] = l
+	}
+
+	plan := APIPlan{}
+	configured := map[string]bool{}
+
+	for i := range variables {
+		v := variables[i]
+		if v.Workspace.Workspace != ws.Workspace {
+			continue
+		}
+
+		configured[variableKey(v.Category, v.Key)] = true
+
+		name := fmt.Sprintf("%s-%s", ws.Workspace, v.Key)
+		desired := variableAttributes(v.Key, v.Value, v.Category, v.Description, v.Sensitive, v.HCL)
+
+		create := func(ctx context.Context) error {
+			category := tfe.CategoryType(v.Category)
+
+			_, err := client.Variables.Create(ctx, *ws.ID, tfe.VariableCreateOptions{
+				Key:         tfe.String(v.Key),
+				Value:       tfe.String(v.Value),
+				Description: tfe.String(v.Description),
+				Category:    &category,
+				HCL:         tfe.Bool(v.HCL),
+				Sensitive:   tfe.Bool(v.Sensitive),
+			})
+
+			return err
+		}
+
+		l, ok := liveByKey[variableKey(v.Category, v.Key)]
+		if !ok {
+			plan = append(plan, newAPIChange("tfe_variable", name, nil, tfjson.Actions{tfjson.ActionCreate}, nil, desired, create))
+			continue
+		}
+
+		current := variableAttributes(l.Key, l.Value, string(l.Category), l.Description, l.Sensitive, l.HCL)
+
+		// sensitive variables can't be made non sensitive, as is the case with the tfe provider
+		if l.Sensitive && !v.Sensitive {
+			id := l.ID
+
+			plan = append(plan, newAPIChange("tfe_variable", name, nil, tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate}, current, desired, func(ctx context.Context) error {
+				if err := client.Variables.Delete(ctx, *ws.ID, id); err != nil {
+					return err
+				}
+
+				return create(ctx)
+			}))
+
+			continue
+		}
+
+		var skip []string
+		if l.Sensitive {
+			skip = append(skip, "value")
+		}
+
+		before, after := diffAttributes(current, desired, skip...)
+
+		if l.Sensitive {
+			// the live value may differ from the configured one, so it is always written, for example to rotate a secret
+			before["value"] = l.Value
+			after["value"] = v.Value
+		}
+
+		if len(after) == 0 {
+			continue
+		}
+
+		id := l.ID
+
+		change := newAPIChange("tfe_variable", name, nil, tfjson.Actions{tfjson.ActionUpdate}, before, after, func(ctx context.Context) error {
+			_, err := client.Variables.Update(ctx, *ws.ID, id, tfe.VariableUpdateOptions{
+				Key:         tfe.String(v.Key),
+				Value:       tfe.String(v.Value),
+				Description: tfe.String(v.Description),
+				HCL:         tfe.Bool(v.HCL),
+				Sensitive:   tfe.Bool(v.Sensitive),
+			})
+
+			return err
+		})
+
+		if l.Sensitive {
+			change.withSensitive("value")
+		}
+
+		plan = append(plan, change)
+	}
+
+	for _, l := range live {
+		if configured[variableKey(string(l.Category), l.Key)] {
+			continue
+		}
+
+		id := l.ID
+
+		plan = append(plan, newAPIChange("tfe_variable", fmt.Sprintf("%s-%s", ws.Workspace, l.Key), nil, tfjson.Actions{tfjson.ActionDelete}, variableAttributes(l.Key, l.Value, string(l.Category), l.Description, l.Sensitive, l.HCL), nil, func(ctx context.Context) error {
+			return client.Variables.Delete(ctx, *ws.ID, id)
+		}))
+	}
+
+	return plan, nil
+}
+
+// teamAccessAttributes returns the configured attributes of a team access, run task permissions are left out as they are not returned by the API
+func teamAccessAttributes(ta TeamAccessItem) map[string]interface{} {
+	attrs := map[string]interface{}{"access": ta.Access}
+
+	if ta.Permissions != nil {
+		if ta.Access == "" {
+			attrs["access"] = string(tfe.AccessCustom)
+		}
+
+		attrs["runs"] = ta.Permissions.Runs
+		attrs["variables"] = ta.Permissions.Variables
+		attrs["state_versions"] = ta.Permissions.StateVersions
+		attrs["sentinel_mocks"] = ta.Permissions.SentinelMocks
+		attrs["workspace_locking"] = ta.Permissions.WorkspaceLocking
+	}
+
+	return attrs
+}
+
+// liveTeamAccessAttributes returns the attributes of a live team access, named the way teamAccessAttributes names them
+func liveTeamAccessAttributes(a *tfe.TeamAccess) map[string]interface{} {
+	return map[string]interface{}{
+		"access":            string(a.Access),
+		"runs":              string(a.Runs),
+		"variables":         string(a.Variables),
+		"state_versions":    string(a.StateVersions),
+		"sentinel_mocks":    string(a.SentinelMocks),
+		"workspace_locking": a.WorkspaceLocking,
+	}
+}
+
+// teamAccessUpdateOptions returns the API options setting the configured access of a team
+func teamAccessUpdateOptions(ta TeamAccessItem) tfe.TeamAccessUpdateOptions {
+	attrs := teamAccessAttributes(ta)
+
+	access := tfe.AccessType(attrs["access"].(string))
+	opts := tfe.TeamAccessUpdateOptions{Access: &access}
+
+	if ta.Permissions != nil {
+		runs := tfe.RunsPermissionType(ta.Permissions.Runs)
+		variables := tfe.VariablesPermissionType(ta.Permissions.Variables)
+		stateVersions := tfe.StateVersionsPermissionType(ta.Permissions.StateVersions)
+		sentinelMocks := tfe.SentinelMocksPermissionType(ta.Permissions.SentinelMocks)
+
+		opts.Runs = &runs
+		opts.Variables = &variables
+		opts.StateVersions = &stateVersions
+		opts.SentinelMocks = &sentinelMocks
+		opts.WorkspaceLocking = tfe.Bool(ta.Permissions.WorkspaceLocking)
+		opts.RunTasks = tfe.Bool(ta.Permissions.RunTasks)
+	}
+
+	return opts
+}
+
+// planTeamAccess plans the creation, update and deletion of the team access of the passed workspace, the configured teams must exist in the organization
+func planTeamAccess(ctx context.Context, client *tfe.Client, ws *Workspace, teamAccess TeamAccess, teams []*tfe.Team) (APIPlan, error) {
+	var live []*tfe.TeamAccess
+
+	if ws.ID != nil {
+		var err error
+
+		if live, err = FetchRelatedTeamAccess(ctx, client, ws); err != nil {
+			return nil, fmt.Errorf("failed to fetch team access: %w", err)
+		}
+	}
+
+	liveByTeam := map[string]*tfe.TeamAccess{}
+	for _, l := range live {
+		if l.Team != nil {
+			liveByTeam[l.Team.ID] = l
+		}
+	}
+
+	plan := APIPlan{}
+	configured := map[string]bool{}
+
+	for i := range teamAccess {
+		ta := teamAccess[i]
+		if ta.Workspace.Workspace != ws.Workspace {
+			continue
+		}
+
+		var team *tfe.Team
+
+		for _, t := range teams {
+			if t.Name == ta.TeamName {
+				team = t
+			}
+		}
+
+		if team == nil {
+			return nil, fmt.Errorf("team %q not found in the organization", ta.TeamName)
+		}
+
+		configured[team.ID] = true
+
+		key := fmt.Sprintf("%s-%s", ws.Workspace, team.ID)
+		desired := teamAccessAttributes(ta)
+		opts := teamAccessUpdateOptions(ta)
+
+		l, ok := liveByTeam[team.ID]
+		if !ok {
+			plan = append(plan, newAPIChange("tfe_team_access", "teams", key, tfjson.Actions{tfjson.ActionCreate}, nil, desired, func(ctx context.Context) error {
+				_, err := client.TeamAccess.Add(ctx, tfe.TeamAccessAddOptions{
+					Access:           opts.Access,
+					Runs:             opts.Runs,
+					Variables:        opts.Variables,
+					StateVersions:    opts.StateVersions,
+					SentinelMocks:    opts.SentinelMocks,
+					WorkspaceLocking: opts.WorkspaceLocking,
+					RunTasks:         opts.RunTasks,
+					Team:             team,
+					Workspace:        &tfe.Workspace{ID: *ws.ID},
+				})
+
+				return err
+			}))
+
+			continue
+		}
+
+		before, after := diffAttributes(liveTeamAccessAttributes(l), desired)
+		if len(after) == 0 {
+			continue
+		}
+
+		id := l.ID
+
+		plan = append(plan, newAPIChange("tfe_team_access", "teams", key, tfjson.Actions{tfjson.ActionUpdate}, before, after, func(ctx context.Context) error {
+			_, err := client.TeamAccess.Update(ctx, id, opts)
+			return err
+		}))
+	}
+
+	for _, l := range live {
+		if l.Team == nil || configured[l.Team.ID] {
+			continue
+		}
+
+		id := l.ID
+
+		plan = append(plan, newAPIChange("tfe_team_access", "teams", fmt.Sprintf("%s-%s", ws.Workspace, l.Team.ID), tfjson.Actions{tfjson.ActionDelete}, liveTeamAccessAttributes(l), nil, func(ctx context.Context) error {
+			return client.TeamAccess.Remove(ctx, id)
+		}))
+	}
+
+	return plan, nil
+}
+
+// runTriggerSource returns a function resolving the ID of the source workspace of a run trigger once the configured workspaces are created.
+// The ID is also returned if it is already known.
+func runTriggerSource(ctx context.Context, client *tfe.Client, trigger RunTrigger, workspaces []*Workspace, organization string) (func() string, string, error) {
+	if trigger.SourceName == "" {
+		return func() string { return trigger.SourceID }, trigger.SourceID, nil
+	}
+
+	for _, ws := range workspaces {
+		if ws.Name == trigger.SourceName {
+			id := ""
+			if ws.ID != nil {
+				id = *ws.ID
+			}
+
+			return func() string { return *ws.ID }, id, nil
+		}
+	}
+
+	source, err := client.Workspaces.Read(ctx, organization, trigger.SourceName)
+	if errors.Is(err, tfe.ErrResourceNotFound) {
+		return nil, "", fmt.Errorf("run trigger source workspace %q not found", trigger.SourceName)
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	return func() string { return source.ID }, source.ID, nil
+}
+
+// planRunTriggers plans the creation and deletion of the inbound run triggers of the passed workspace, run triggers can't be updated
+func planRunTriggers(ctx context.Context, client *tfe.Client, ws *Workspace, triggers RunTriggers, workspaces []*Workspace, organization string) (APIPlan, error) {
+	var live []*tfe.RunTrigger
+
+	if ws.ID != nil {
+		var err error
+
+		if live, err = FetchInboundRunTriggers(ctx, client, *ws.ID); err != nil {
+			return nil, fmt.Errorf("failed to fetch run triggers: %w", err)
+		}
+	}
+
+	plan := APIPlan{}
+	matched := map[string]bool{}
+
+	for _, t := range triggers {
+		if t.Workspace.Workspace != ws.Workspace {
+			continue
+		}
+
+		sourceID, id, err := runTriggerSource(ctx, client, t, workspaces, organization)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+
+		for _, l := range live {
+			if id != "" && l.Sourceable != nil && l.Sourceable.ID == id {
+				matched[l.ID] = true
+				found = true
+			}
+		}
+
+		if found {
+			continue
+		}
+
+		key := fmt.Sprintf("%s-%s", ws.Workspace, id)
+		after := map[string]interface{}{"sourceable_id": id}
+
+		if id == "" {
+			key = fmt.Sprintf("%s-%s", ws.Workspace, t.SourceName)
+			after = map[string]interface{}{}
+		}
+
+		change := newAPIChange("tfe_run_trigger", "trigger", key, tfjson.Actions{tfjson.ActionCreate}, nil, after, func(ctx context.Context) error {
+			_, err := client.RunTriggers.Create(ctx, *ws.ID, tfe.RunTriggerCreateOptions{
+				Sourceable: &tfe.Workspace{ID: sourceID()},
+			})
+
+			return err
+		})
+
+		if id == "" {
+			change.withUnknown("sourceable_id")
+		}
+
+		plan = append(plan, change)
+	}
+
+	for _, l := range live {
+		if matched[l.ID] || l.Sourceable == nil {
+			continue
+		}
+
+		id := l.ID
+
+		plan = append(plan, newAPIChange("tfe_run_trigger", "trigger", fmt.Sprintf("%s-%s", ws.Workspace, l.Sourceable.ID), tfjson.Actions{tfjson.ActionDelete}, map[string]interface{}{"sourceable_id": l.Sourceable.ID}, nil, func(ctx context.Context) error {
+			return client.RunTriggers.Delete(ctx, id)
+		}))
+	}
+
+	return plan, nil
+}
+
+// notificationAttributes returns the attributes of a notification, the token is left out as it can't be read back
+func notificationAttributes(input *NotificationInput) (map[string]interface{}, error) {
+	enabled := false
+
+	if input.Enabled != "" {
+		var err error
+
+		if enabled, err = strconv.ParseBool(input.Enabled); err != nil {
+			return nil, fmt.Errorf("failed to parse enabled of notification %q: %w", input.Name, err)
+		}
+	}
+
+	attrs := map[string]interface{}{
+		"name":             input.Name,
+		"destination_type": input.DestinationType,
+		"enabled":          enabled,
+		"url":              input.URL,
+	}
+
+	for name, values := range map[string][]string{
+		"email_addresses": input.EmailAddresses,
+		"email_user_ids":  input.EmailUserIDs,
+		"triggers":        input.Triggers,
+	} {
+		sorted := append([]string{}, values...)
+		sort.Strings(sorted)
+
+		attrs[name] = sorted
+	}
+
+	return attrs, nil
+}
+
+// planNotifications plans the creation and update of the notifications of the passed workspace.
+// Notifications are matched by name, and as with imports the notifications that are not configured are left untouched.
+func planNotifications(ctx context.Context, client *tfe.Client, ws *Workspace, notifications []*Notification) (APIPlan, error) {
+	wsNotifications := FilterNotifications(notifications, ws)
+	if len(wsNotifications) == 0 {
+		return nil, nil
+	}
+
+	var live []*tfe.NotificationConfiguration
+
+	if ws.ID != nil {
+		var err error
+
+		if live, err = FetchNotificationConfigurations(ctx, client, *ws.ID); err != nil {
+			return nil, fmt.Errorf("failed to fetch notifications: %w", err)
+		}
+	}
+
+	plan := APIPlan{}
+
+	for _, n := range wsNotifications {
+		desired, err := notificationAttributes(n.Input)
+		if err != nil {
+			return nil, err
+		}
+
+		input := n.Input
+
+		var triggers []tfe.NotificationTriggerType
+		for _, t := range input.Triggers {
+			triggers = append(triggers, tfe.NotificationTriggerType(t))
+		}
+
+		var users []*tfe.User
+		for _, id := range input.EmailUserIDs {
+			users = append(users, &tfe.User{ID: id})
+		}
+
+		enabled := desired["enabled"].(bool)
+
+		create := func(ctx context.Context) error {
+			destinationType := tfe.NotificationDestinationType(input.DestinationType)
+
+			_, err := client.NotificationConfigurations.Create(ctx, *ws.ID, tfe.NotificationConfigurationCreateOptions{
+				DestinationType: &destinationType,
+				Enabled:         &enabled,
+				Name:            tfe.String(input.Name),
+				Token:           optionalString(input.Token),
+				Triggers:        triggers,
+				URL:             optionalString(input.URL),
+				EmailAddresses:  input.EmailAddresses,
+				EmailUsers:      users,
+			})
+
+			return err
+		}
+
+		nc := FindNotificationConfigurationByName(live, input.Name)
+		if nc == nil {
+			plan = append(plan, newAPIChange("tfe_notification_configuration", "notifications", n.Key(), tfjson.Actions{tfjson.ActionCreate}, nil, desired, create).withSensitive("token"))
+			continue
+		}
+
+		current, err := notificationAttributes(ToNotification(nc, ws).Input)
+		if err != nil {
+			return nil, err
+		}
+
+		id := nc.ID
+
+		// the destination type can't be updated, as is the case with the tfe provider
+		if string(nc.DestinationType) != input.DestinationType {
+			plan = append(plan, newAPIChange("tfe_notification_configuration", "notifications", n.Key(), tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate}, current, desired, func(ctx context.Context) error {
+				if err := client.NotificationConfigurations.Delete(ctx, id); err != nil {
+					return err
+				}
+
+				return create(ctx)
+			}).withSensitive("token"))
+
+			continue
+		}
+
+		before, after := diffAttributes(current, desired)
+		if len(after) == 0 {
+			continue
+		}
+
+		plan = append(plan, newAPIChange("tfe_notification_configuration", "notifications", n.Key(), tfjson.Actions{tfjson.ActionUpdate}, before, after, func(ctx context.Context) error {
+			_, err := client.NotificationConfigurations.Update(ctx, id, tfe.NotificationConfigurationUpdateOptions{
+				Enabled:        &enabled,
+				Name:           tfe.String(input.Name),
+				Token:          optionalString(input.Token),
+				Triggers:       triggers,
+				URL:            optionalString(input.URL),
+				EmailAddresses: input.EmailAddresses,
+				EmailUsers:     users,
+			})
+
+			return err
+		}).withSensitive("token"))
+	}
+
+	return plan, nil
+}
+
+// RunAPIEngine plans the API calls reconciling the workspaces with the configuration, reports them like a Terraform plan and, if enabled, applies them
+func RunAPIEngine(ctx context.Context, config *Config, client *tfe.Client, workspaces []*Workspace, wsConfig *NewWorkspaceConfigOptions, destroyProtection *DestroyProtection) error {
+	plan, err := PlanAPIChanges(ctx, client, workspaces, wsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to plan: %w", err)
+	}
+
+	if len(plan) == 0 {
+		gha.Infof("No changes\n")
+
+		if err := PublishPlanSummary(ctx, config, nil); err != nil {
+			return err
+		}
+
+		if err := NewPlanOutputs(nil, workspaces).SetOutputs(); err != nil {
+			return err
+		}
+	} else {
+		planStr := plan.String()
+
+		gha.Infof(planStr)
+		gha.SetOutput("plan", planStr)
+
+		tfPlan := plan.Plan()
+
+		b, err := json.Marshal(tfPlan)
+		if err != nil {
+			return fmt.Errorf("failed to convert plan to JSON: %w", err)
+		}
+
+		gha.SetOutput("plan_json", string(b))
+
+		if err := PublishPlanSummary(ctx, config, NewPlanChanges(tfPlan, workspaces)); err != nil {
+			return err
+		}
+
+		if err := NewPlanOutputs(tfPlan, workspaces).SetOutputs(); err != nil {
+			return err
+		}
+
+		if err := destroyProtection.Check(tfPlan); err != nil {
+			return err
+		}
+
+		if config.Apply {
+			gha.Infof("Applying...\n")
+
+			if err := plan.Apply(ctx); err != nil {
+				return fmt.Errorf("failed to apply: %w", err)
+			}
+
+			gha.Infof("Success\n")
+		}
+	}
+
+	if !config.Apply {
+		return nil
+	}
+
+	ids := map[string]WorkspaceOutput{}
+
+	for _, ws := range workspaces {
+		if ws.ID != nil {
+			ids[ws.Workspace] = WorkspaceOutput{ID: *ws.ID, Name: ws.Name}
+		}
+	}
+
+	return setWorkspaceIDOutputs(ids, config.Host, config.Organization)
+}
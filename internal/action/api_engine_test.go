@@ -0,0 +1,316 @@
+package action
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// apiPlanActions returns the address and summarized action of each change of the plan
+func apiPlanActions(plan APIPlan) [][2]string {
+	actions := [][2]string{}
+
+	for _, c := range plan {
+		actions = append(actions, [2]string{c.ResourceChange.Address, changeAction(c.ResourceChange.Change.Actions)})
+	}
+
+	return actions
+}
+
+func TestPlanAPIChanges(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123", testServerResHandler(t, 200, `{"data": {"id": "ws-abc123", "type": "workspaces", "attributes": {"name": "foo-staging", "auto-apply": false}}}`))
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123/vars", testServerPagesHandler(t, [][]string{
+		{
+			`{"id": "var-1", "type": "vars", "attributes": {"key": "foo", "value": "bar", "category": "env"}}`,
+			`{"id": "var-2", "type": "vars", "attributes": {"key": "region", "value": "us-west-2", "category": "env"}}`,
+			`{"id": "var-3", "type": "vars", "attributes": {"key": "stale", "value": "1", "category": "env"}}`,
+			`{"id": "var-4", "type": "vars", "attributes": {"key": "secret", "value": "", "category": "env", "sensitive": true}}`,
+			`{"id": "var-5", "type": "vars", "attributes": {"key": "foo", "value": "bar", "category": "terraform"}}`,
+		},
+	}))
+	mux.HandleFunc("/api/v2/organizations/org/teams", testServerPagesHandler(t, newTestTeamPages([]string{"readers"})))
+	mux.HandleFunc("/api/v2/team-workspaces", testServerPagesHandler(t, [][]string{
+		{`{"id": "tws-1", "type": "team-workspaces", "attributes": {"access": "read"}, "relationships": {"team": {"data": {"id": "team-readers", "type": "teams"}}}}`},
+	}))
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123/run-triggers", testServerPagesHandler(t, [][]string{
+		{`{"id": "rt-1", "type": "run-triggers", "attributes": {"sourceable-name": "old"}, "relationships": {"sourceable": {"data": {"id": "ws-old", "type": "workspaces"}}}}`},
+	}))
+
+	client := newTestTFClient(t, server.URL)
+
+	workspaces := []*Workspace{
+		{Name: "foo-staging", Workspace: "staging", ID: strPtr("ws-abc123")},
+		{Name: "foo-production", Workspace: "production"},
+	}
+
+	plan, err := PlanAPIChanges(ctx, client, workspaces, &NewWorkspaceConfigOptions{
+		WorkspaceResourceOptions: &WorkspaceResourceOptions{
+			AutoApply:    boolPtr(true),
+			Organization: "org",
+		},
+		Variables: Variables{
+			{Key: "foo", Value: "bar", Category: "env", Workspace: workspaces[0]},
+			{Key: "region", Value: "us-east-1", Category: "env", Workspace: workspaces[0]},
+			{Key: "secret", Value: "changed", Category: "env", Sensitive: true, Workspace: workspaces[0]},
+			{Key: "foo", Value: "bar", Category: "env", Workspace: workspaces[1]},
+		},
+		TeamAccess: NewTeamAccess(TeamAccessInput{{TeamName: "readers", Access: "write"}}, workspaces),
+		RunTriggers: RunTriggers{
+			{SourceName: "foo-staging", Workspace: workspaces[1]},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, [][2]string{
+		{`tfe_workspace.workspace["staging"]`, "update"},
+		{`tfe_workspace.workspace["production"]`, "create"},
+		{"tfe_variable.staging-region", "update"},
+		{"tfe_variable.staging-secret", "update"},
+		{"tfe_variable.staging-stale", "destroy"},
+		{"tfe_variable.staging-foo", "destroy"},
+		{`tfe_team_access.teams["staging-team-readers"]`, "update"},
+		{`tfe_run_trigger.trigger["staging-ws-old"]`, "destroy"},
+		{"tfe_variable.production-foo", "create"},
+		{`tfe_team_access.teams["production-team-readers"]`, "create"},
+		{`tfe_run_trigger.trigger["production-ws-abc123"]`, "create"},
+	}, apiPlanActions(plan))
+
+	assert.Equal(t, map[string]interface{}{"auto_apply": false}, plan[0].ResourceChange.Change.Before)
+	assert.Equal(t, map[string]interface{}{"auto_apply": true}, plan[0].ResourceChange.Change.After)
+
+	assert.Equal(t, map[string]interface{}{"value": "changed"}, plan[3].ResourceChange.Change.After, "sensitive values are always written")
+	assert.Contains(t, plan.String(), "~ value = (sensitive value) -> (sensitive value)")
+	assert.Equal(t, map[string]interface{}{"key": "foo", "value": "bar", "category": "terraform", "description": "", "sensitive": false, "hcl": false}, plan[5].ResourceChange.Change.Before, "variables are matched by category and key")
+
+	outputs := NewPlanOutputs(plan.Plan(), workspaces)
+
+	assert.Equal(t, 4, outputs.AddCount)
+	assert.Equal(t, 4, outputs.ChangeCount)
+	assert.Equal(t, 3, outputs.DestroyCount)
+	assert.Equal(t, []string{"foo-production", "foo-staging"}, outputs.AffectedWorkspaces)
+
+	t.Run("fail on unknown teams", func(t *testing.T) {
+		_, err := PlanAPIChanges(ctx, client, workspaces[1:], &NewWorkspaceConfigOptions{
+			WorkspaceResourceOptions: &WorkspaceResourceOptions{Organization: "org"},
+			TeamAccess:               NewTeamAccess(TeamAccessInput{{TeamName: "writers", Access: "write"}}, workspaces[1:]),
+		})
+
+		assert.EqualError(t, err, "failed to plan the resources of workspace \"foo-production\": team \"writers\" not found in the organization")
+	})
+}
+
+func TestAPIPlanString(t *testing.T) {
+	plan := APIPlan{
+		newAPIChange("tfe_workspace", "workspace", "staging", tfjson.Actions{tfjson.ActionUpdate}, map[string]interface{}{"auto_apply": false}, map[string]interface{}{"auto_apply": true}, nil),
+		newAPIChange("tfe_variable", "staging-token", nil, tfjson.Actions{tfjson.ActionCreate}, nil, variableAttributes("token", "secret", "env", "", true, false), nil),
+		newAPIChange("tfe_run_trigger", "trigger", "staging-ws-old", tfjson.Actions{tfjson.ActionDelete}, map[string]interface{}{"sourceable_id": "ws-old"}, nil, nil),
+	}
+
+	assert.Equal(t, `The Terraform Cloud API engine will perform the following actions:
+
+  # tfe_workspace.workspace["staging"] will be updated in-place
+  ~ resource "tfe_workspace" "workspace" {
+      ~ auto_apply = false -> true
+    }
+
+  # tfe_variable.staging-token will be created
+  + resource "tfe_variable" "staging-token" {
+      + category = "env"
+      + description = ""
+      + hcl = false
+      + key = "token"
+      + sensitive = true
+      + value = (sensitive value)
+    }
+
+  # tfe_run_trigger.trigger["staging-ws-old"] will be destroyed
+  - resource "tfe_run_trigger" "trigger" {
+      - sourceable_id = "ws-old"
+    }
+
+Plan: 1 to add, 1 to change, 1 to destroy.
+`, plan.String())
+}
+
+// recordRequests returns a handler recording the method and path of every request, and responding with the handler of the request method
+func recordRequests(requests *[]string, handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.Method+" "+r.URL.Path)
+
+		handler, ok := handlers[r.Method]
+		if !ok {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+func TestAPIPlanApply(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("create a workspace", func(t *testing.T) {
+		mux := http.NewServeMux()
+		server := httptest.NewServer(mux)
+
+		t.Cleanup(func() {
+			server.Close()
+		})
+
+		var variableBody string
+
+		mux.HandleFunc("/api/v2/organizations/org/workspaces", testServerResHandler(t, 201, `{"data": {"id": "ws-new", "type": "workspaces", "attributes": {"name": "foo"}}}`))
+		mux.HandleFunc("/api/v2/workspaces/ws-new/vars", func(w http.ResponseWriter, r *http.Request) {
+			b, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			variableBody = string(b)
+
+			testServerResHandler(t, 201, `{"data": {"id": "var-1", "type": "vars", "attributes": {"key": "foo", "value": "bar", "category": "env"}}}`)(w, r)
+		})
+		mux.HandleFunc("/api/v2/organizations/org/teams", testServerPagesHandler(t, [][]string{{}}))
+
+		client := newTestTFClient(t, server.URL)
+
+		ws := &Workspace{Name: "foo", Workspace: "default"}
+
+		plan, err := PlanAPIChanges(ctx, client, []*Workspace{ws}, &NewWorkspaceConfigOptions{
+			WorkspaceResourceOptions: &WorkspaceResourceOptions{Organization: "org"},
+			Variables:                Variables{{Key: "foo", Value: "bar", Category: "env", Workspace: ws}},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, plan.Apply(ctx))
+
+		assert.Equal(t, strPtr("ws-new"), ws.ID)
+		assert.Contains(t, variableBody, `"key":"foo"`)
+	})
+
+	t.Run("update an existing workspace", func(t *testing.T) {
+		mux := http.NewServeMux()
+		server := httptest.NewServer(mux)
+
+		t.Cleanup(func() {
+			server.Close()
+		})
+
+		var (
+			requests      []string
+			workspaceBody []string
+			variableBody  string
+		)
+
+		liveWorkspace := `{"data": {"id": "ws-abc123", "type": "workspaces", "attributes": {"name": "foo-staging", "tag-names": ["old", "shared"], "vcs-repo": {"identifier": "org/repo"}}}}`
+
+		mux.HandleFunc("/api/v2/workspaces/ws-abc123", recordRequests(&requests, map[string]http.HandlerFunc{
+			http.MethodGet: testServerResHandler(t, 200, liveWorkspace),
+			http.MethodPatch: func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				workspaceBody = append(workspaceBody, string(b))
+
+				testServerResHandler(t, 200, liveWorkspace)(w, r)
+			},
+		}))
+		mux.HandleFunc("/api/v2/workspaces/ws-abc123/relationships/tags", recordRequests(&requests, map[string]http.HandlerFunc{
+			http.MethodPost:   testServerResHandler(t, 204, ""),
+			http.MethodDelete: testServerResHandler(t, 204, ""),
+		}))
+		mux.HandleFunc("/api/v2/workspaces/ws-abc123/vars", testServerPagesHandler(t, [][]string{
+			{`{"id": "var-1", "type": "vars", "attributes": {"key": "secret", "value": "", "category": "env", "sensitive": true}}`},
+		}))
+		mux.HandleFunc("/api/v2/workspaces/ws-abc123/vars/var-1", recordRequests(&requests, map[string]http.HandlerFunc{
+			http.MethodPatch: func(w http.ResponseWriter, r *http.Request) {
+				b, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				variableBody = string(b)
+
+				testServerResHandler(t, 200, `{"data": {"id": "var-1", "type": "vars", "attributes": {"key": "secret", "value": "", "category": "env", "sensitive": true}}}`)(w, r)
+			},
+		}))
+		mux.HandleFunc("/api/v2/organizations/org/teams", testServerPagesHandler(t, newTestTeamPages([]string{"readers", "writers"})))
+		mux.HandleFunc("/api/v2/team-workspaces", testServerPagesHandler(t, [][]string{
+			{
+				`{"id": "tws-1", "type": "team-workspaces", "attributes": {"access": "read"}, "relationships": {"team": {"data": {"id": "team-readers", "type": "teams"}}}}`,
+				`{"id": "tws-2", "type": "team-workspaces", "attributes": {"access": "write"}, "relationships": {"team": {"data": {"id": "team-writers", "type": "teams"}}}}`,
+			},
+		}))
+		mux.HandleFunc("/api/v2/team-workspaces/tws-1", recordRequests(&requests, map[string]http.HandlerFunc{
+			http.MethodPatch: testServerResHandler(t, 200, `{"data": {"id": "tws-1", "type": "team-workspaces", "attributes": {"access": "write"}}}`),
+		}))
+		mux.HandleFunc("/api/v2/team-workspaces/tws-2", recordRequests(&requests, map[string]http.HandlerFunc{
+			http.MethodDelete: testServerResHandler(t, 204, ""),
+		}))
+		mux.HandleFunc("/api/v2/workspaces/ws-abc123/run-triggers", testServerPagesHandler(t, [][]string{
+			{`{"id": "rt-1", "type": "run-triggers", "attributes": {"sourceable-name": "old"}, "relationships": {"sourceable": {"data": {"id": "ws-old", "type": "workspaces"}}}}`},
+		}))
+		mux.HandleFunc("/api/v2/run-triggers/rt-1", recordRequests(&requests, map[string]http.HandlerFunc{
+			http.MethodDelete: testServerResHandler(t, 204, ""),
+		}))
+		mux.HandleFunc("/api/v2/workspaces/ws-abc123/notification-configurations", recordRequests(&requests, map[string]http.HandlerFunc{
+			http.MethodGet: testServerPagesHandler(t, [][]string{
+				{`{"id": "nc-1", "type": "notification-configurations", "attributes": {"name": "alerts", "destination-type": "generic", "enabled": true, "url": "https://example.com/hook"}}`},
+			}),
+			http.MethodPost: testServerResHandler(t, 201, `{"data": {"id": "nc-2", "type": "notification-configurations", "attributes": {"name": "alerts", "destination-type": "slack", "enabled": true}}}`),
+		}))
+		mux.HandleFunc("/api/v2/notification-configurations/nc-1", recordRequests(&requests, map[string]http.HandlerFunc{
+			http.MethodDelete: testServerResHandler(t, 204, ""),
+		}))
+
+		client := newTestTFClient(t, server.URL)
+
+		ws := &Workspace{Name: "foo-staging", Workspace: "staging", ID: strPtr("ws-abc123")}
+
+		plan, err := PlanAPIChanges(ctx, client, []*Workspace{ws}, &NewWorkspaceConfigOptions{
+			WorkspaceResourceOptions: &WorkspaceResourceOptions{
+				Organization: "org",
+				Tags:         map[string]Tags{"staging": {"shared", "new"}},
+			},
+			Variables:  Variables{{Key: "secret", Value: "rotated", Category: "env", Sensitive: true, Workspace: ws}},
+			TeamAccess: NewTeamAccess(TeamAccessInput{{TeamName: "readers", Access: "write"}}, []*Workspace{ws}),
+			Notifications: []*Notification{
+				{Input: &NotificationInput{Name: "alerts", DestinationType: "slack", Enabled: "true", URL: "https://example.com/hook"}, Workspace: ws},
+			},
+		})
+		require.NoError(t, err)
+
+		requests = nil
+
+		require.NoError(t, plan.Apply(ctx))
+
+		assert.Equal(t, []string{
+			"PATCH /api/v2/workspaces/ws-abc123",
+			"PATCH /api/v2/workspaces/ws-abc123",
+			"POST /api/v2/workspaces/ws-abc123/relationships/tags",
+			"DELETE /api/v2/workspaces/ws-abc123/relationships/tags",
+			"PATCH /api/v2/workspaces/ws-abc123/vars/var-1",
+			"PATCH /api/v2/team-workspaces/tws-1",
+			"DELETE /api/v2/team-workspaces/tws-2",
+			"DELETE /api/v2/run-triggers/rt-1",
+			"DELETE /api/v2/notification-configurations/nc-1",
+			"POST /api/v2/workspaces/ws-abc123/notification-configurations",
+		}, requests)
+
+		require.Len(t, workspaceBody, 2)
+		assert.Contains(t, workspaceBody[0], `"vcs-repo":null`, "the VCS connection is removed")
+		assert.Contains(t, variableBody, `"value":"rotated"`)
+	})
+}
@@ -0,0 +1,192 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+// cliConfigFileName is the name of the run-scoped Terraform CLI configuration file written to the working directory
+const cliConfigFileName = ".terraformrc"
+
+// TerraformCLIConfig holds the settings of the Terraform CLI configuration written for a run
+type TerraformCLIConfig struct {
+	Host           string
+	Token          string
+	PluginCacheDir string
+	ProviderMirror string
+
+	// Blocks are the blocks of the user's CLI configuration merged into the configuration of the run
+	Blocks []string
+}
+
+// NewTerraformCLIConfig returns the Terraform CLI configuration of a run in the passed working directory.
+// Providers are cached in the configured provider cache directory, in TF_PLUGIN_CACHE_DIR if it is set, or else in the working directory,
+// so every "terraform init" of the run reuses the providers downloaded by the first one.
+func NewTerraformCLIConfig(config *Config, workDir string) *TerraformCLIConfig {
+	cacheDir := config.ProviderCacheDir
+	if cacheDir == "" {
+		cacheDir = os.Getenv("TF_PLUGIN_CACHE_DIR")
+	}
+
+	if cacheDir == "" {
+		cacheDir = filepath.Join(workDir, ".plugin-cache")
+	}
+
+	// Terraform runs in the working directory, so relative paths must be resolved from the current one
+	if abs, err := filepath.Abs(cacheDir); err == nil {
+		cacheDir = abs
+	}
+
+	return &TerraformCLIConfig{
+		Host:           config.Host,
+		Token:          config.Token,
+		PluginCacheDir: cacheDir,
+		ProviderMirror: config.ProviderMirror,
+	}
+}
+
+// Merge keeps the credentials, credentials_helper, host and provider_installation blocks of the passed CLI configuration.
+// The credentials of the Terraform Cloud host are replaced by the token of the run, and the provider installation is replaced when a provider mirror is set.
+// Only the native syntax is supported, an error is returned for configurations in the JSON syntax.
+func (c *TerraformCLIConfig) Merge(src string) error {
+	if isJSONCLIConfig(src) {
+		return errors.New("the JSON syntax is not supported, use the native syntax")
+	}
+
+	file, diags := hclsyntax.ParseConfig([]byte(src), cliConfigFileName, hcl.InitialPos)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	blocks := file.Body.(*hclsyntax.Body).Blocks
+
+	for _, b := range blocks {
+		switch b.Type {
+		case "credentials":
+			if len(b.Labels) == 1 && strings.EqualFold(b.Labels[0], c.Host) {
+				continue
+			}
+		case "credentials_helper", "host":
+		case "provider_installation":
+			if c.ProviderMirror != "" {
+				continue
+			}
+		default:
+			continue
+		}
+
+		r := b.Range()
+		c.Blocks = append(c.Blocks, src[r.Start.Byte:r.End.Byte])
+	}
+
+	return nil
+}
+
+// isJSONCLIConfig returns whether the passed CLI configuration is in the JSON syntax, that is it starts with an opening brace
+func isJSONCLIConfig(src string) bool {
+	tokens, _ := hclsyntax.LexConfig([]byte(src), cliConfigFileName, hcl.InitialPos)
+
+	for _, t := range tokens {
+		if t.Type != hclsyntax.TokenComment && t.Type != hclsyntax.TokenNewline {
+			return t.Type == hclsyntax.TokenOBrace
+		}
+	}
+
+	return false
+}
+
+// Render returns the CLI configuration in the Terraform CLI configuration file syntax
+func (c *TerraformCLIConfig) Render() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "credentials %q {\n  token = %q\n}\n", c.Host, c.Token)
+
+	for _, block := range c.Blocks {
+		fmt.Fprintf(&b, "\n%s\n", block)
+	}
+
+	if c.PluginCacheDir != "" {
+		fmt.Fprintf(&b, "\nplugin_cache_dir = %q\n", c.PluginCacheDir)
+	}
+
+	if c.ProviderMirror != "" {
+		// Terraform requires network mirror URLs to end with a slash
+		mirror := strings.TrimSuffix(c.ProviderMirror, "/") + "/"
+
+		fmt.Fprintf(&b, "\nprovider_installation {\n  network_mirror {\n    url = %q\n  }\n}\n", mirror)
+	}
+
+	return b.String()
+}
+
+// userCLIConfigPath returns the path of the CLI configuration Terraform uses outside of the action
+func userCLIConfigPath() (string, error) {
+	if p := os.Getenv("TF_CLI_CONFIG_FILE"); p != "" {
+		return p, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve homedir: %w", err)
+	}
+
+	return filepath.Join(home, ".terraformrc"), nil
+}
+
+// writeTerraformCLIConfigFile writes the passed CLI configuration, merged with the user's CLI configuration, to the working directory
+// and points the passed Terraform CLI to it with TF_CLI_CONFIG_FILE. The user's CLI configuration is left untouched,
+// and the file is removed along with the working directory at the end of the run.
+func writeTerraformCLIConfigFile(tf *tfexec.Terraform, config *TerraformCLIConfig, workDir string) error {
+	if config.PluginCacheDir != "" {
+		if err := os.MkdirAll(config.PluginCacheDir, 0755); err != nil {
+			return fmt.Errorf("failed to create the provider plugin cache directory: %w", err)
+		}
+	}
+
+	userPath, err := userCLIConfigPath()
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(userPath)
+	if err == nil {
+		if err := config.Merge(string(b)); err != nil {
+			return fmt.Errorf("failed to parse the Terraform CLI configuration at %s: %w", userPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read the Terraform CLI configuration at %s: %w", userPath, err)
+	}
+
+	configPath := filepath.Join(workDir, cliConfigFileName)
+
+	if err := ioutil.WriteFile(configPath, []byte(config.Render()), 0600); err != nil {
+		return fmt.Errorf("failed to write the Terraform CLI configuration: %w", err)
+	}
+
+	// the environment replaces the process environment, without the variables managed by tfexec
+	env := tfexec.CleanEnv(environMap(os.Environ()))
+	env["TF_CLI_CONFIG_FILE"] = configPath
+
+	return tf.SetEnv(env)
+}
+
+// environMap returns the passed "key=value" environment as a map
+func environMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+
+	return env
+}
@@ -0,0 +1,225 @@
+package action
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerraformCLIConfig(t *testing.T) {
+	t.Run("cache providers in the working directory", func(t *testing.T) {
+		t.Setenv("TF_PLUGIN_CACHE_DIR", "")
+
+		config := NewTerraformCLIConfig(&Config{Host: "app.terraform.io", Token: "secret"}, "/tmp/run")
+
+		assert.Equal(t, `credentials "app.terraform.io" {
+  token = "secret"
+}
+
+plugin_cache_dir = "/tmp/run/.plugin-cache"
+`, config.Render())
+	})
+
+	t.Run("use the configured cache and mirror", func(t *testing.T) {
+		t.Setenv("TF_PLUGIN_CACHE_DIR", "/var/cache/env")
+
+		config := NewTerraformCLIConfig(&Config{Host: "app.terraform.io", Token: "secret", ProviderMirror: "https://mirror.example.com/providers"}, "/tmp/run")
+
+		assert.Equal(t, "/var/cache/env", config.PluginCacheDir)
+
+		config = NewTerraformCLIConfig(&Config{Host: "app.terraform.io", Token: "secret", ProviderCacheDir: "/var/cache/providers", ProviderMirror: "https://mirror.example.com/providers"}, "/tmp/run")
+
+		assert.Equal(t, `credentials "app.terraform.io" {
+  token = "secret"
+}
+
+plugin_cache_dir = "/var/cache/providers"
+
+provider_installation {
+  network_mirror {
+    url = "https://mirror.example.com/providers/"
+  }
+}
+`, config.Render())
+	})
+}
+
+const testUserCLIConfig = `# user configuration
+plugin_cache_dir = "/home/user/.terraform.d/plugin-cache"
+disable_checkpoint = true
+
+credentials "app.terraform.io" {
+  token = "user-token"
+}
+
+credentials "tfe.example.com" {
+  token = "other-token" // private instance
+}
+
+credentials_helper "vault" {
+  args = ["--path", "secret/terraform"]
+}
+
+host "tfe.example.com" {
+  services = {
+    "tfe.v2" = "https://tfe.example.com/api/v2/"
+  }
+}
+
+/* prefer the internal mirror { */
+provider_installation {
+  network_mirror {
+    url     = "https://providers.example.com/"
+    include = ["example.com/*/*"]
+  }
+  direct {
+    exclude = ["example.com/*/*"]
+  }
+}
+`
+
+func TestTerraformCLIConfigMerge(t *testing.T) {
+	t.Run("keep credentials, hosts and provider installation", func(t *testing.T) {
+		config := &TerraformCLIConfig{Host: "app.terraform.io", Token: "secret"}
+
+		require.NoError(t, config.Merge(testUserCLIConfig))
+
+		assert.Equal(t, `credentials "app.terraform.io" {
+  token = "secret"
+}
+
+credentials "tfe.example.com" {
+  token = "other-token" // private instance
+}
+
+credentials_helper "vault" {
+  args = ["--path", "secret/terraform"]
+}
+
+host "tfe.example.com" {
+  services = {
+    "tfe.v2" = "https://tfe.example.com/api/v2/"
+  }
+}
+
+provider_installation {
+  network_mirror {
+    url     = "https://providers.example.com/"
+    include = ["example.com/*/*"]
+  }
+  direct {
+    exclude = ["example.com/*/*"]
+  }
+}
+`, config.Render())
+	})
+
+	t.Run("replace the provider installation with the mirror", func(t *testing.T) {
+		config := &TerraformCLIConfig{Host: "app.terraform.io", Token: "secret", ProviderMirror: "https://mirror.example.com"}
+
+		require.NoError(t, config.Merge(testUserCLIConfig))
+
+		require.Len(t, config.Blocks, 3)
+		assert.Equal(t, "credentials \"tfe.example.com\" {\n  token = \"other-token\" // private instance\n}", config.Blocks[0])
+		assert.NotContains(t, config.Render(), "providers.example.com")
+	})
+
+	t.Run("keep blocks holding heredocs", func(t *testing.T) {
+		config := &TerraformCLIConfig{Host: "app.terraform.io", Token: "secret"}
+
+		require.NoError(t, config.Merge(`motd = <<EOT
+credentials "app.terraform.io" {
+EOT
+
+credentials_helper "script" {
+  args = [<<-EOT
+    }
+    credentials "tfe.example.com" {
+    EOT
+  ]
+}
+
+credentials "app.terraform.io" {
+  token = <<EOT
+user-token
+}
+EOT
+}
+`))
+
+		assert.Equal(t, []string{`credentials_helper "script" {
+  args = [<<-EOT
+    }
+    credentials "tfe.example.com" {
+    EOT
+  ]
+}`}, config.Blocks)
+	})
+
+	t.Run("fail on invalid configuration", func(t *testing.T) {
+		config := &TerraformCLIConfig{Host: "app.terraform.io", Token: "secret"}
+
+		assert.ErrorContains(t, config.Merge("credentials \"app.terraform.io\" {\n  token = \"secret\n}\n"), ".terraformrc:2,18-3,1: Invalid multi-line string")
+		assert.EqualError(t, config.Merge("# JSON\n{\"credentials\": {\"app.terraform.io\": {\"token\": \"secret\"}}}\n"), "the JSON syntax is not supported, use the native syntax")
+		assert.Empty(t, config.Blocks)
+	})
+}
+
+func TestWriteTerraformCLIConfigFile(t *testing.T) {
+	home := t.TempDir()
+	workDir := t.TempDir()
+
+	t.Setenv("HOME", home)
+	t.Setenv("TF_CLI_CONFIG_FILE", "")
+
+	userPath := filepath.Join(home, ".terraformrc")
+	require.NoError(t, ioutil.WriteFile(userPath, []byte(testUserCLIConfig), 0644))
+
+	tf, err := tfexec.NewTerraform(workDir, filepath.Join(workDir, "terraform"))
+	require.NoError(t, err)
+
+	config := &TerraformCLIConfig{Host: "app.terraform.io", Token: "secret", PluginCacheDir: filepath.Join(workDir, ".plugin-cache")}
+
+	require.NoError(t, writeTerraformCLIConfigFile(tf, config, workDir))
+
+	b, err := ioutil.ReadFile(userPath)
+	require.NoError(t, err)
+
+	assert.Equal(t, testUserCLIConfig, string(b))
+
+	configPath := filepath.Join(workDir, cliConfigFileName)
+
+	b, err = ioutil.ReadFile(configPath)
+	require.NoError(t, err)
+
+	assert.Equal(t, config.Render(), string(b))
+	assert.Contains(t, string(b), `credentials "tfe.example.com"`)
+
+	info, err := os.Stat(configPath)
+	require.NoError(t, err)
+
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.DirExists(t, config.PluginCacheDir)
+}
+
+func TestWriteTerraformCLIConfigFileInvalid(t *testing.T) {
+	workDir := t.TempDir()
+	userPath := filepath.Join(t.TempDir(), "terraform.rc")
+
+	t.Setenv("TF_CLI_CONFIG_FILE", userPath)
+
+	require.NoError(t, ioutil.WriteFile(userPath, []byte(`{"plugin_cache_dir": "/tmp/cache"}`), 0644))
+
+	tf, err := tfexec.NewTerraform(workDir, filepath.Join(workDir, "terraform"))
+	require.NoError(t, err)
+
+	err = writeTerraformCLIConfigFile(tf, &TerraformCLIConfig{Host: "app.terraform.io", Token: "secret"}, workDir)
+
+	assert.EqualError(t, err, "failed to parse the Terraform CLI configuration at "+userPath+": the JSON syntax is not supported, use the native syntax")
+	assert.NoFileExists(t, filepath.Join(workDir, cliConfigFileName))
+}
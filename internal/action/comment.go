@@ -0,0 +1,147 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// PullRequestCommentOptions points at the pull request to comment on
+type PullRequestCommentOptions struct {
+	APIURL     string
+	Token      string
+	Repository string
+	Number     int
+	HTTPClient *http.Client
+}
+
+type issueComment struct {
+	ID   int64  `json:"id,omitempty"`
+	Body string `json:"body"`
+}
+
+// commentMarker returns the hidden marker identifying the comment of the named action run, so later runs update it rather than adding comments
+func commentMarker(name string) string {
+	return fmt.Sprintf("<!-- terraform-cloud-workspace-action:%s -->", name)
+}
+
+// PullRequestNumber returns the pull request number of the GitHub event in the passed event file, 0 is returned for events unrelated to a pull request
+func PullRequestNumber(eventPath string) (int, error) {
+	if eventPath == "" {
+		return 0, nil
+	}
+
+	b, err := ioutil.ReadFile(eventPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read GitHub event: %w", err)
+	}
+
+	var event struct {
+		PullRequest *struct {
+			Number int `json:"number"`
+		} `json:"pull_request"`
+		Issue *struct {
+			Number      int         `json:"number"`
+			PullRequest interface{} `json:"pull_request"`
+		} `json:"issue"`
+	}
+
+	if err := json.Unmarshal(b, &event); err != nil {
+		return 0, fmt.Errorf("failed to decode GitHub event: %w", err)
+	}
+
+	if event.PullRequest != nil {
+		return event.PullRequest.Number, nil
+	}
+
+	if event.Issue != nil && event.Issue.PullRequest != nil {
+		return event.Issue.Number, nil
+	}
+
+	return 0, nil
+}
+
+// githubRequest sends a GitHub API request and decodes the JSON response into v, if passed
+func githubRequest(ctx context.Context, opts *PullRequestCommentOptions, method string, path string, body interface{}, v interface{}) error {
+	var reqBody bytes.Buffer
+
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(opts.APIURL, "/")+path, &reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", opts.Token))
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("GitHub API request %s %s failed with status %d", method, path, res.StatusCode)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// UpsertPullRequestComment updates the pull request comment previously created for the named action run, or creates it if there is none
+func UpsertPullRequestComment(ctx context.Context, opts *PullRequestCommentOptions, name string, body string) error {
+	marker := commentMarker(name)
+	body = fmt.Sprintf("%s\n%s", marker, body)
+
+	for page := 1; ; page++ {
+		var comments []issueComment
+
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", opts.Repository, opts.Number, page)
+
+		if err := githubRequest(ctx, opts, http.MethodGet, path, nil, &comments); err != nil {
+			return fmt.Errorf("failed to list pull request comments: %w", err)
+		}
+
+		for _, c := range comments {
+			if strings.HasPrefix(c.Body, marker) {
+				if err := githubRequest(ctx, opts, http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", opts.Repository, c.ID), issueComment{Body: body}, nil); err != nil {
+					return fmt.Errorf("failed to update pull request comment: %w", err)
+				}
+
+				return nil
+			}
+		}
+
+		if len(comments) < 100 {
+			break
+		}
+	}
+
+	if err := githubRequest(ctx, opts, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", opts.Repository, opts.Number), issueComment{Body: body}, nil); err != nil {
+		return fmt.Errorf("failed to create pull request comment: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRequestNumber(t *testing.T) {
+	for _, testCase := range []struct {
+		Description string
+		Event       string
+		Expect      int
+	}{
+		{"pull request event", `{"pull_request": {"number": 12}}`, 12},
+		{"pull request comment event", `{"issue": {"number": 13, "pull_request": {}}}`, 13},
+		{"issue comment event", `{"issue": {"number": 14}}`, 0},
+		{"push event", `{"ref": "refs/heads/main"}`, 0},
+	} {
+		t.Run(testCase.Description, func(t *testing.T) {
+			filePath := path.Join(t.TempDir(), "event.json")
+			require.NoError(t, os.WriteFile(filePath, []byte(testCase.Event), 0644))
+
+			number, err := PullRequestNumber(filePath)
+			require.NoError(t, err)
+
+			assert.Equal(t, testCase.Expect, number)
+		})
+	}
+
+	t.Run("no event file", func(t *testing.T) {
+		number, err := PullRequestNumber("")
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, number)
+	})
+}
+
+func TestUpsertPullRequestComment(t *testing.T) {
+	ctx := context.Background()
+
+	newServer := func(t *testing.T, comments string) (*httptest.Server, *[]string) {
+		requests := []string{}
+
+		mux := http.NewServeMux()
+		server := httptest.NewServer(mux)
+
+		t.Cleanup(func() {
+			server.Close()
+		})
+
+		record := func(r *http.Request) {
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+			var body issueComment
+			if r.Method != http.MethodGet {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			}
+
+			requests = append(requests, r.Method+" "+r.URL.Path+" "+body.Body)
+		}
+
+		mux.HandleFunc("/api/v3/repos/org/repo/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+			record(r)
+
+			if r.Method == http.MethodGet {
+				testServerResHandler(t, 200, comments)(w, r)
+				return
+			}
+
+			testServerResHandler(t, 201, `{}`)(w, r)
+		})
+
+		mux.HandleFunc("/api/v3/repos/org/repo/issues/comments/", func(w http.ResponseWriter, r *http.Request) {
+			record(r)
+			testServerResHandler(t, 200, `{}`)(w, r)
+		})
+
+		return server, &requests
+	}
+
+	t.Run("update the existing comment", func(t *testing.T) {
+		server, requests := newServer(t, `[{"id": 1, "body": "LGTM"}, {"id": 2, "body": "<!-- terraform-cloud-workspace-action:foo -->\nold"}]`)
+
+		err := UpsertPullRequestComment(ctx, &PullRequestCommentOptions{
+			APIURL:     server.URL + "/api/v3/",
+			Token:      "token",
+			Repository: "org/repo",
+			Number:     7,
+		}, "foo", "new")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"GET /api/v3/repos/org/repo/issues/7/comments ",
+			"PATCH /api/v3/repos/org/repo/issues/comments/2 <!-- terraform-cloud-workspace-action:foo -->\nnew",
+		}, *requests)
+	})
+
+	t.Run("create a comment", func(t *testing.T) {
+		server, requests := newServer(t, `[{"id": 1, "body": "<!-- terraform-cloud-workspace-action:bar -->\nother"}]`)
+
+		err := UpsertPullRequestComment(ctx, &PullRequestCommentOptions{
+			APIURL:     server.URL + "/api/v3",
+			Token:      "token",
+			Repository: "org/repo",
+			Number:     7,
+		}, "foo", "new")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"GET /api/v3/repos/org/repo/issues/7/comments ",
+			"POST /api/v3/repos/org/repo/issues/7/comments <!-- terraform-cloud-workspace-action:foo -->\nnew",
+		}, *requests)
+	})
+}
@@ -0,0 +1,455 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfconfig"
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Defaults of the settings that action.yml also declares, shared with the command line flags
+const (
+	DefaultHost                   = "app.terraform.io"
+	DefaultRunnerTerraformVersion = "1.5.7"
+	DefaultTFEProviderVersion     = "0.30.2"
+)
+
+// ConfigFileVersion is the only config file schema version currently supported
+const ConfigFileVersion = 1
+
+// Config is the typed representation of every action setting, decoded either from the flat action inputs or from a config file
+type Config struct {
+	Version int `yaml:"version,omitempty"`
+
+	Token                     string                            `yaml:"-"`
+	Host                      string                            `yaml:"terraform_host,omitempty"`
+	Name                      string                            `yaml:"name,omitempty"`
+	PreviousName              string                            `yaml:"previous_name,omitempty"`
+	Description               string                            `yaml:"description,omitempty"`
+	Tags                      Tags                              `yaml:"tags,omitempty"`
+	WorkspaceTags             map[string]Tags                   `yaml:"workspace_tags,omitempty"`
+	Organization              string                            `yaml:"terraform_organization,omitempty"`
+	Apply                     bool                              `yaml:"apply,omitempty"`
+	RunnerTerraformVersion    string                            `yaml:"runner_terraform_version,omitempty"`
+	TerraformPath             string                            `yaml:"terraform_path,omitempty"`
+	TerraformMirrorURL        string                            `yaml:"terraform_mirror_url,omitempty"`
+	TerraformCacheDir         string                            `yaml:"terraform_cache_dir,omitempty"`
+	ProviderCacheDir          string                            `yaml:"provider_cache_dir,omitempty"`
+	ProviderMirror            string                            `yaml:"provider_mirror,omitempty"`
+	RemoteStates              map[string]tfconfig.RemoteState   `yaml:"remote_states,omitempty"`
+	Workspaces                []string                          `yaml:"workspaces,omitempty"`
+	WorkspaceRenames          map[string]string                 `yaml:"workspace_renames,omitempty"`
+	Variables                 VariablesInput                    `yaml:"variables,omitempty"`
+	WorkspaceVariables        WorkspaceVariablesInput           `yaml:"workspace_variables,omitempty"`
+	TeamAccess                TeamAccessInput                   `yaml:"team_access,omitempty"`
+	BackendConfig             map[string]interface{}            `yaml:"backend_config,omitempty"`
+	AgentPoolID               string                            `yaml:"agent_pool_id,omitempty"`
+	AutoApply                 *bool                             `yaml:"auto_apply,omitempty"`
+	ExecutionMode             string                            `yaml:"execution_mode,omitempty"`
+	FileTriggersEnabled       *bool                             `yaml:"file_triggers_enabled,omitempty"`
+	GlobalRemoteState         *bool                             `yaml:"global_remote_state,omitempty"`
+	NotificationConfiguration NotificationInputs                `yaml:"notification_configuration,omitempty"`
+	QueueAllRuns              *bool                             `yaml:"queue_all_runs,omitempty"`
+	RemoteStateConsumerIDs    string                            `yaml:"remote_state_consumer_ids,omitempty"`
+	SpeculativeEnabled        *bool                             `yaml:"speculative_enabled,omitempty"`
+	TerraformVersion          string                            `yaml:"terraform_version,omitempty"`
+	RunTriggers               RunTriggerInputs                  `yaml:"run_triggers,omitempty"`
+	WorkspaceRunTriggers      map[string]RunTriggerInputs       `yaml:"workspace_run_triggers,omitempty"`
+	SSHKeyID                  string                            `yaml:"ssh_key_id,omitempty"`
+	VCSIngressSubmodules      bool                              `yaml:"vcs_ingress_submodules,omitempty"`
+	VCSRepo                   string                            `yaml:"vcs_repo,omitempty"`
+	VCSTokenID                string                            `yaml:"vcs_token_id,omitempty"`
+	VCSType                   string                            `yaml:"vcs_type,omitempty"`
+	WorkingDirectory          string                            `yaml:"working_directory,omitempty"`
+	TFEProviderVersion        string                            `yaml:"tfe_provider_version,omitempty"`
+	Import                    bool                              `yaml:"import,omitempty"`
+	AllowWorkspaceDeletion    bool                              `yaml:"allow_workspace_deletion,omitempty"`
+	DestroyProtection         DestroyProtectionInput            `yaml:"destroy_protection,omitempty"`
+	WorkspaceSettings         map[string]WorkspaceSettingsInput `yaml:"workspace_settings,omitempty"`
+	WorkspaceNotifications    map[string]NotificationInputs     `yaml:"workspace_notifications,omitempty"`
+	VariableSets              VariableSetInputs                 `yaml:"variable_sets,omitempty"`
+	Mode                      string                            `yaml:"mode,omitempty"`
+	Engine                    string                            `yaml:"engine,omitempty"`
+	PlanArtifact              string                            `yaml:"plan_artifact,omitempty"`
+	OutputDirectory           string                            `yaml:"output_directory,omitempty"`
+	TerraformLogLevel         string                            `yaml:"terraform_log_level,omitempty"`
+	PRComment                 bool                              `yaml:"pr_comment,omitempty"`
+	GitHubToken               string                            `yaml:"-"`
+	GitHubAPIURL              string                            `yaml:"github_api_url,omitempty"`
+}
+
+// NewConfig decodes the YAML encoded action inputs into a Config, then overlays the config file if one was passed
+func NewConfig(inputs *Inputs) (*Config, error) {
+	config := &Config{
+		Token:                  inputs.Token,
+		Host:                   inputs.Host,
+		Name:                   inputs.Name,
+		PreviousName:           inputs.PreviousName,
+		Description:            inputs.Description,
+		Organization:           inputs.Organization,
+		Apply:                  inputs.Apply,
+		RunnerTerraformVersion: inputs.RunnerTerraformVersion,
+		TerraformPath:          inputs.TerraformPath,
+		TerraformMirrorURL:     inputs.TerraformMirrorURL,
+		TerraformCacheDir:      inputs.TerraformCacheDir,
+		ProviderCacheDir:       inputs.ProviderCacheDir,
+		ProviderMirror:         inputs.ProviderMirror,
+		AgentPoolID:            inputs.AgentPoolID,
+		AutoApply:              inputs.AutoApply,
+		ExecutionMode:          inputs.ExecutionMode,
+		FileTriggersEnabled:    inputs.FileTriggersEnabled,
+		GlobalRemoteState:      inputs.GlobalRemoteState,
+		QueueAllRuns:           inputs.QueueAllRuns,
+		RemoteStateConsumerIDs: inputs.RemoteStateConsumerIDs,
+		SpeculativeEnabled:     inputs.SpeculativeEnabled,
+		TerraformVersion:       inputs.TerraformVersion,
+		SSHKeyID:               inputs.SSHKeyID,
+		VCSIngressSubmodules:   inputs.VCSIngressSubmodules,
+		VCSRepo:                inputs.VCSRepo,
+		VCSTokenID:             inputs.VCSTokenID,
+		VCSType:                inputs.VCSType,
+		WorkingDirectory:       inputs.WorkingDirectory,
+		TFEProviderVersion:     inputs.TFEProviderVersion,
+		Import:                 inputs.Import,
+		AllowWorkspaceDeletion: inputs.AllowWorkspaceDeletion,
+		Mode:                   inputs.Mode,
+		Engine:                 inputs.Engine,
+		PlanArtifact:           inputs.PlanArtifact,
+		OutputDirectory:        inputs.OutputDirectory,
+		TerraformLogLevel:      inputs.TerraformLogLevel,
+		PRComment:              inputs.PRComment,
+		GitHubToken:            inputs.GitHubToken,
+		GitHubAPIURL:           inputs.GitHubAPIURL,
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.RemoteStates), &config.RemoteStates); err != nil {
+		return nil, fmt.Errorf("failed to parse remote state blocks: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.Workspaces), &config.Workspaces); err != nil {
+		return nil, fmt.Errorf("failed to decode workspaces: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.WorkspaceRenames), &config.WorkspaceRenames); err != nil {
+		return nil, fmt.Errorf("failed to decode workspace renames: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.Variables), &config.Variables); err != nil {
+		return nil, fmt.Errorf("failed to parse variables %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.WorkspaceVariables), &config.WorkspaceVariables); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace variables %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.TeamAccess), &config.TeamAccess); err != nil {
+		return nil, fmt.Errorf("failed to parse teams: %w", err)
+	}
+
+	backend, err := tfconfig.ParseBackend(inputs.BackendConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse backend configuration: %w", err)
+	}
+
+	config.BackendConfig = backend
+
+	if err := yaml.Unmarshal([]byte(inputs.Tags), &config.Tags); err != nil {
+		return nil, fmt.Errorf("failed to decode tag names: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.WorkspaceTags), &config.WorkspaceTags); err != nil {
+		return nil, fmt.Errorf("failed to decode workspace tag names: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.RunTriggers), &config.RunTriggers); err != nil {
+		return nil, fmt.Errorf("failed to decode run triggers: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.WorkspaceRunTriggers), &config.WorkspaceRunTriggers); err != nil {
+		return nil, fmt.Errorf("failed to decode workspace run triggers: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.NotificationConfiguration), &config.NotificationConfiguration); err != nil {
+		return nil, fmt.Errorf("failed to decode notification input: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.VariableSets), &config.VariableSets); err != nil {
+		return nil, fmt.Errorf("failed to decode variable sets: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.WorkspaceNotifications), &config.WorkspaceNotifications); err != nil {
+		return nil, fmt.Errorf("failed to decode workspace notifications: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.WorkspaceSettings), &config.WorkspaceSettings); err != nil {
+		return nil, fmt.Errorf("failed to decode workspace settings: %w", err)
+	}
+
+	if err := yaml.Unmarshal([]byte(inputs.DestroyProtection), &config.DestroyProtection); err != nil {
+		return nil, fmt.Errorf("failed to decode destroy protection: %w", err)
+	}
+
+	if inputs.ConfigFile != "" {
+		if err := LoadConfigFile(inputs.ConfigFile, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if inputs.Override != nil {
+		inputs.Override(config)
+	}
+
+	switch config.Mode {
+	case "", ModeDriftCheck, ModeApply, ModeRender:
+	case ModePlanOnly, ModePlan:
+		if config.Apply {
+			return nil, fmt.Errorf("apply must be false in %q mode", config.Mode)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported mode %q, mode must be empty, %q, %q, %q, %q or %q", config.Mode, ModeDriftCheck, ModePlanOnly, ModePlan, ModeApply, ModeRender)
+	}
+
+	if (config.Mode == ModePlan || config.Mode == ModeApply) && config.PlanArtifact == "" {
+		return nil, fmt.Errorf("plan_artifact must be set in %q mode", config.Mode)
+	}
+
+	switch config.Engine {
+	case "", EngineTerraform:
+	case EngineAPI:
+		if config.Mode == ModePlan || config.Mode == ModeApply {
+			return nil, fmt.Errorf("%q mode is not supported by the %q engine", config.Mode, EngineAPI)
+		}
+
+		if len(config.VariableSets) > 0 {
+			return nil, fmt.Errorf("variable sets are not supported by the %q engine", EngineAPI)
+		}
+
+		if len(config.RemoteStates) > 0 {
+			return nil, fmt.Errorf("remote states are not supported by the %q engine", EngineAPI)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported engine %q, engine must be empty, %q or %q", config.Engine, EngineTerraform, EngineAPI)
+	}
+
+	if err := config.resolveVariableValues(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// TerraformInstallOptions returns the options finding the Terraform binary that plans and applies the generated configuration
+func (c *Config) TerraformInstallOptions() *TerraformInstallOptions {
+	return &TerraformInstallOptions{
+		Version:   c.RunnerTerraformVersion,
+		Path:      c.TerraformPath,
+		MirrorURL: c.TerraformMirrorURL,
+		CacheDir:  c.TerraformCacheDir,
+	}
+}
+
+// resolveVariableValues reads the value_from sources of every workspace and variable set variable
+func (c *Config) resolveVariableValues() error {
+	if err := c.Variables.ResolveValues(); err != nil {
+		return err
+	}
+
+	for _, vs := range c.WorkspaceVariables {
+		if err := vs.ResolveValues(); err != nil {
+			return err
+		}
+	}
+
+	for _, set := range c.VariableSets {
+		if err := set.Variables.ResolveValues(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ConfigFileError is a config file validation error, pointing at the offending line when it is known
+type ConfigFileError struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (e *ConfigFileError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Message)
+}
+
+// ConfigFileErrors is a list of config file validation errors
+type ConfigFileErrors []*ConfigFileError
+
+func (e ConfigFileErrors) Error() string {
+	messages := make([]string, len(e))
+
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// errorOrNil returns the error list as an error, or nil if the list is empty
+func (e ConfigFileErrors) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+var yamlLineErrorRegexp = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// newConfigFileErrors converts a yaml.v3 decoding error to one ConfigFileError per reported problem
+func newConfigFileErrors(filePath string, err error) error {
+	var messages []string
+
+	var typeErr *yamlv3.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+
+	errs := make(ConfigFileErrors, len(messages))
+
+	for i, msg := range messages {
+		cfErr := &ConfigFileError{Path: filePath, Message: msg}
+
+		if m := yamlLineErrorRegexp.FindStringSubmatch(msg); m != nil {
+			cfErr.Line, _ = strconv.Atoi(m[1])
+			cfErr.Message = m[2]
+		}
+
+		errs[i] = cfErr
+	}
+
+	return errs
+}
+
+// LoadConfigFile decodes the YAML or JSON config file at filePath onto the passed config. Settings present in the file replace the values already set on the config.
+func LoadConfigFile(filePath string, config *Config) error {
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yamlv3.Node
+
+	if err := yamlv3.Unmarshal(b, &doc); err != nil {
+		return newConfigFileErrors(filePath, err)
+	}
+
+	if len(doc.Content) == 0 {
+		return &ConfigFileError{Path: filePath, Message: "config file is empty"}
+	}
+
+	root := doc.Content[0]
+
+	if root.Kind != yamlv3.MappingNode {
+		return &ConfigFileError{Path: filePath, Line: root.Line, Message: "config file must be a map of settings"}
+	}
+
+	versionNode := mappingValue(root, "version")
+	if versionNode == nil {
+		return &ConfigFileError{Path: filePath, Line: root.Line, Message: fmt.Sprintf("version must be set, the current version is %d", ConfigFileVersion)}
+	}
+
+	if versionNode.Value != strconv.Itoa(ConfigFileVersion) {
+		return &ConfigFileError{Path: filePath, Line: versionNode.Line, Message: fmt.Sprintf("unsupported config file version %q, the current version is %d", versionNode.Value, ConfigFileVersion)}
+	}
+
+	dec := yamlv3.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	var file Config
+
+	if err := dec.Decode(&file); err != nil {
+		return newConfigFileErrors(filePath, err)
+	}
+
+	overlayConfig(config, &file, root)
+
+	return validateWorkspaceKeys(filePath, root, config.Workspaces)
+}
+
+// overlayConfig replaces every setting of config that is set in the root node of the config file with the decoded file setting.
+// Maps and lists are replaced as a whole rather than merged with the values already set.
+func overlayConfig(config *Config, file *Config, root *yamlv3.Node) {
+	target := reflect.ValueOf(config).Elem()
+	source := reflect.ValueOf(file).Elem()
+
+	for i := 0; i < target.NumField(); i++ {
+		key := strings.Split(target.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+
+		if mappingValue(root, key) != nil {
+			target.Field(i).Set(source.Field(i))
+		}
+	}
+}
+
+// mappingValue returns the value node for the passed key of a mapping node, or nil if the key is not set
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// validateWorkspaceKeys ensures every per-workspace setting in the config file refers to a declared workspace
+func validateWorkspaceKeys(filePath string, root *yamlv3.Node, workspaceNames []string) error {
+	known := map[string]bool{}
+
+	if len(workspaceNames) == 0 {
+		known["default"] = true
+	}
+
+	for _, name := range workspaceNames {
+		known[name] = true
+	}
+
+	var errs ConfigFileErrors
+
+	for _, field := range []string{"workspace_tags", "workspace_variables", "workspace_run_triggers", "workspace_settings", "workspace_notifications"} {
+		node := mappingValue(root, field)
+		if node == nil || node.Kind != yamlv3.MappingNode {
+			continue
+		}
+
+		for i := 0; i < len(node.Content); i += 2 {
+			key := node.Content[i]
+
+			if !known[key.Value] {
+				errs = append(errs, &ConfigFileError{
+					Path:    filePath,
+					Line:    key.Line,
+					Message: fmt.Sprintf("%s references unknown workspace %q", field, key.Value),
+				})
+			}
+		}
+	}
+
+	return errs.errorOrNil()
+}
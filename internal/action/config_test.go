@@ -0,0 +1,251 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfconfig"
+)
+
+// writeTestConfigFile writes the passed contents to a config file in a temporary directory and returns its path
+func writeTestConfigFile(t *testing.T, name string, contents string) string {
+	filePath := path.Join(t.TempDir(), name)
+
+	require.NoError(t, os.WriteFile(filePath, []byte(contents), 0644))
+
+	return filePath
+}
+
+func TestNewConfig(t *testing.T) {
+	t.Run("decode YAML encoded inputs", func(t *testing.T) {
+		config, err := NewConfig(&Inputs{
+			Name:       "foo",
+			Workspaces: "[staging, production]",
+			Variables:  "[{key: foo, value: bar, category: env}]",
+			WorkspaceTags: `---
+staging: [staging]`,
+			RunTriggers:   "[id: ws-abc123]",
+			BackendConfig: "local: {path: foo.tfstate}",
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "foo", config.Name)
+		assert.Equal(t, []string{"staging", "production"}, config.Workspaces)
+		assert.Equal(t, VariablesInput{{Key: "foo", Value: "bar", Category: "env"}}, config.Variables)
+		assert.Equal(t, map[string]Tags{"staging": {"staging"}}, config.WorkspaceTags)
+		assert.Equal(t, RunTriggerInputs{{SourceID: "ws-abc123"}}, config.RunTriggers)
+		assert.Equal(t, map[string]interface{}{"local": map[string]interface{}{"path": "foo.tfstate"}}, config.BackendConfig)
+	})
+
+	t.Run("config file settings replace inputs", func(t *testing.T) {
+		filePath := writeTestConfigFile(t, "config.yml", `---
+version: 1
+workspaces: [staging, production]
+auto_apply: false
+variables:
+  - key: foo
+    value: baz
+    category: env
+workspace_variables:
+  production:
+    - key: environment
+      value: production
+      category: terraform
+remote_states:
+  teams:
+    backend: s3
+    config:
+      bucket: bucket
+      key: key
+      region: us-east-1
+notification_configuration:
+  name: notify
+  destination_type: email
+  enabled: true
+variable_sets:
+  - name: aws
+`)
+
+		config, err := NewConfig(&Inputs{
+			Name:         "foo",
+			Organization: "org",
+			AutoApply:    boolPtr(true),
+			Variables:    "[{key: foo, value: bar, category: env}]",
+			ConfigFile:   filePath,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "foo", config.Name)
+		assert.Equal(t, "org", config.Organization)
+		assert.Equal(t, []string{"staging", "production"}, config.Workspaces)
+		assert.Equal(t, boolPtr(false), config.AutoApply)
+		assert.Equal(t, VariablesInput{{Key: "foo", Value: "baz", Category: "env"}}, config.Variables)
+		assert.Equal(t, WorkspaceVariablesInput{
+			"production": {{Key: "environment", Value: "production", Category: "terraform"}},
+		}, config.WorkspaceVariables)
+		assert.Equal(t, map[string]tfconfig.RemoteState{
+			"teams": {Backend: "s3", Config: tfconfig.RemoteStateBackendConfig{Bucket: "bucket", Key: "key", Region: "us-east-1"}},
+		}, config.RemoteStates)
+		assert.Equal(t, NotificationInputs{{Name: "notify", DestinationType: "email", Enabled: "true"}}, config.NotificationConfiguration)
+		assert.Equal(t, VariableSetInputs{{Name: "aws"}}, config.VariableSets)
+	})
+
+	t.Run("config file settings replace maps and lists as a whole", func(t *testing.T) {
+		filePath := writeTestConfigFile(t, "config.yml", `---
+version: 1
+workspaces: [staging, production]
+tags: [shared]
+workspace_tags:
+  staging: [eu]
+`)
+
+		config, err := NewConfig(&Inputs{
+			Tags:          "[all, team]",
+			WorkspaceTags: "{staging: [us], production: [prod]}",
+			ConfigFile:    filePath,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, Tags{"shared"}, config.Tags)
+		assert.Equal(t, map[string]Tags{"staging": {"eu"}}, config.WorkspaceTags)
+	})
+
+	t.Run("overrides replace config file settings", func(t *testing.T) {
+		filePath := writeTestConfigFile(t, "config.yml", "version: 1\nterraform_organization: file\ntfe_provider_version: 0.40.0\n")
+
+		config, err := NewConfig(&Inputs{
+			Organization:       "inputs",
+			TFEProviderVersion: DefaultTFEProviderVersion,
+			ConfigFile:         filePath,
+			Override: func(config *Config) {
+				config.Organization = "override"
+			},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "override", config.Organization)
+		assert.Equal(t, "0.40.0", config.TFEProviderVersion)
+	})
+}
+
+func TestActionDefaults(t *testing.T) {
+	action := getActionConfig(t)
+
+	assert.Equal(t, DefaultHost, action.Inputs["terraform_host"].Default)
+	assert.Equal(t, DefaultRunnerTerraformVersion, action.Inputs["runner_terraform_version"].Default)
+	assert.Equal(t, DefaultTFEProviderVersion, action.Inputs["tfe_provider_version"].Default)
+	assert.Equal(t, DefaultTerraformLogLevel, action.Inputs["terraform_log_level"].Default)
+}
+
+func TestNewConfigMode(t *testing.T) {
+	config, err := NewConfig(&Inputs{Mode: ModeDriftCheck})
+	require.NoError(t, err)
+
+	assert.Equal(t, ModeDriftCheck, config.Mode)
+
+	_, err = NewConfig(&Inputs{Mode: "destroy"})
+
+	assert.EqualError(t, err, "unsupported mode \"destroy\", mode must be empty, \"drift-check\", \"plan-only\", \"plan\", \"apply\" or \"render\"")
+
+	_, err = NewConfig(&Inputs{Mode: ModePlanOnly, Apply: true})
+
+	assert.EqualError(t, err, "apply must be false in \"plan-only\" mode")
+
+	_, err = NewConfig(&Inputs{Mode: ModeApply})
+
+	assert.EqualError(t, err, "plan_artifact must be set in \"apply\" mode")
+}
+
+func TestNewConfigEngine(t *testing.T) {
+	config, err := NewConfig(&Inputs{Engine: EngineAPI, Mode: ModePlanOnly})
+	require.NoError(t, err)
+
+	assert.Equal(t, EngineAPI, config.Engine)
+
+	_, err = NewConfig(&Inputs{Engine: "pulumi"})
+
+	assert.EqualError(t, err, "unsupported engine \"pulumi\", engine must be empty, \"terraform\" or \"api\"")
+
+	_, err = NewConfig(&Inputs{Engine: EngineAPI, Mode: ModePlan, PlanArtifact: "tfplan"})
+
+	assert.EqualError(t, err, "\"plan\" mode is not supported by the \"api\" engine")
+
+	_, err = NewConfig(&Inputs{Engine: EngineAPI, VariableSets: "[{name: aws}]"})
+
+	assert.EqualError(t, err, "variable sets are not supported by the \"api\" engine")
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Run("load a JSON config file", func(t *testing.T) {
+		filePath := writeTestConfigFile(t, "config.json", `{
+  "version": 1,
+  "name": "foo",
+  "tags": ["all"],
+  "backend_config": {"s3": {"bucket": "bucket"}}
+}`)
+
+		config := &Config{}
+
+		require.NoError(t, LoadConfigFile(filePath, config))
+
+		assert.Equal(t, "foo", config.Name)
+		assert.Equal(t, Tags{"all"}, config.Tags)
+		assert.Equal(t, map[string]interface{}{"s3": map[string]interface{}{"bucket": "bucket"}}, config.BackendConfig)
+	})
+
+	for _, testCase := range []struct {
+		Description string
+		Contents    string
+		Expect      string
+	}{
+		{
+			Description: "require a version",
+			Contents:    "name: foo\n",
+			Expect:      "%s:1: version must be set, the current version is 1",
+		},
+		{
+			Description: "reject unsupported versions",
+			Contents:    "name: foo\nversion: 2\n",
+			Expect:      "%s:2: unsupported config file version \"2\", the current version is 1",
+		},
+		{
+			Description: "reject unknown settings",
+			Contents:    "version: 1\nname: foo\nautoapply: true\n",
+			Expect:      "%s:3: field autoapply not found in type action.Config",
+		},
+		{
+			Description: "reject mistyped settings",
+			Contents:    "version: 1\nworkspaces:\n  staging: true\n",
+			Expect:      "%s:3: cannot unmarshal !!map into []string",
+		},
+		{
+			Description: "reject invalid YAML",
+			Contents:    "version: 1\nname: foo: bar\n",
+			Expect:      "%s:2: mapping values are not allowed in this context",
+		},
+		{
+			Description: "reject settings for unknown workspaces",
+			Contents: `version: 1
+workspaces: [staging]
+workspace_tags:
+  staging: [staging]
+  production: [production]
+workspace_variables:
+  prod: []
+`,
+			Expect: "%[1]s:5: workspace_tags references unknown workspace \"production\"\n%[1]s:7: workspace_variables references unknown workspace \"prod\"",
+		},
+	} {
+		t.Run(testCase.Description, func(t *testing.T) {
+			filePath := writeTestConfigFile(t, "config.yml", testCase.Contents)
+
+			err := LoadConfigFile(filePath, &Config{})
+
+			assert.EqualError(t, err, fmt.Sprintf(testCase.Expect, filePath))
+		})
+	}
+}
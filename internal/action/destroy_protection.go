@@ -0,0 +1,131 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// defaultDenyDestroy are the resource types protected from deletion when no policy is configured
+var defaultDenyDestroy = []string{"tfe_workspace"}
+
+// DestroyProtectionInput configures which resource types a plan may delete or replace. Unset type lists fall back to protecting workspaces only.
+type DestroyProtectionInput struct {
+	DenyDestroy []string `yaml:"deny_destroy,omitempty"`
+	DenyReplace []string `yaml:"deny_replace,omitempty"`
+	MaxDestroy  int      `yaml:"max_destroy,omitempty"`
+}
+
+// DestroyProtection is the effective destroy protection policy
+type DestroyProtection struct {
+	DenyDestroy map[string]bool
+	DenyReplace map[string]bool
+	MaxDestroy  int
+}
+
+// DestroyViolation is a planned resource change that the destroy protection policy does not allow
+type DestroyViolation struct {
+	Address string
+	Action  string
+}
+
+// NewDestroyProtection returns the effective policy of the passed input. Replacements are denied for the destroy protected types unless deny_replace is set,
+// and allowWorkspaceDeletion lifts the protection of workspaces.
+func NewDestroyProtection(input DestroyProtectionInput, allowWorkspaceDeletion bool) (*DestroyProtection, error) {
+	if input.MaxDestroy < 0 {
+		return nil, fmt.Errorf("max_destroy must not be negative, got %d", input.MaxDestroy)
+	}
+
+	denyDestroy := input.DenyDestroy
+	if denyDestroy == nil {
+		denyDestroy = defaultDenyDestroy
+	}
+
+	denyReplace := input.DenyReplace
+	if denyReplace == nil {
+		denyReplace = denyDestroy
+	}
+
+	policy := &DestroyProtection{
+		DenyDestroy: map[string]bool{},
+		DenyReplace: map[string]bool{},
+		MaxDestroy:  input.MaxDestroy,
+	}
+
+	for _, t := range denyDestroy {
+		policy.DenyDestroy[t] = true
+	}
+
+	for _, t := range denyReplace {
+		policy.DenyReplace[t] = true
+	}
+
+	if allowWorkspaceDeletion {
+		delete(policy.DenyDestroy, "tfe_workspace")
+		delete(policy.DenyReplace, "tfe_workspace")
+	}
+
+	return policy, nil
+}
+
+// Violations returns the managed resource changes of the plan that the policy does not allow, and the number of resources the plan destroys, replacements included
+func (p *DestroyProtection) Violations(plan *tfjson.Plan) ([]DestroyViolation, int) {
+	violations := []DestroyViolation{}
+	destroyCount := 0
+
+	for _, rc := range plan.ResourceChanges {
+		if rc.Mode != tfjson.ManagedResourceMode || rc.Change == nil {
+			continue
+		}
+
+		switch {
+		case rc.Change.Actions.Replace():
+			destroyCount++
+
+			if p.DenyReplace[rc.Type] {
+				violations = append(violations, DestroyViolation{Address: rc.Address, Action: "replace"})
+			}
+		case rc.Change.Actions.Delete():
+			destroyCount++
+
+			if p.DenyDestroy[rc.Type] {
+				violations = append(violations, DestroyViolation{Address: rc.Address, Action: "destroy"})
+			}
+		}
+	}
+
+	return violations, destroyCount
+}
+
+// Check returns an error listing every resource change of the plan that the policy does not allow
+func (p *DestroyProtection) Check(plan *tfjson.Plan) error {
+	violations, destroyCount := p.Violations(plan)
+
+	overMax := p.MaxDestroy > 0 && destroyCount > p.MaxDestroy
+
+	if len(violations) == 0 && !overMax {
+		return nil
+	}
+
+	lines := []string{"the plan violates the destroy protection policy:"}
+
+	workspaces := false
+
+	for _, v := range violations {
+		lines = append(lines, fmt.Sprintf("  - %s would be %s, which is not allowed", v.Address, map[string]string{"destroy": "destroyed", "replace": "replaced"}[v.Action]))
+
+		workspaces = workspaces || strings.HasPrefix(v.Address, "tfe_workspace.")
+	}
+
+	if overMax {
+		lines = append(lines, fmt.Sprintf("  - %d resources would be destroyed, more than max_destroy %d", destroyCount, p.MaxDestroy))
+	}
+
+	if workspaces {
+		lines = append(lines, "allow_workspace_deletion must be true to allow workspace deletion. Deleting a workspace will permanently, irrecoverably delete all of its stored Terraform state versions")
+	}
+
+	return errors.New(strings.Join(lines, "\n"))
+}
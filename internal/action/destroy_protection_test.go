@@ -0,0 +1,96 @@
+package action
+
+import (
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDestroyPlan() *tfjson.Plan {
+	change := func(address string, resourceType string, actions ...tfjson.Action) *tfjson.ResourceChange {
+		return &tfjson.ResourceChange{
+			Address: address,
+			Mode:    tfjson.ManagedResourceMode,
+			Type:    resourceType,
+			Change:  &tfjson.Change{Actions: actions},
+		}
+	}
+
+	return &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			change(`tfe_workspace.workspace["staging"]`, "tfe_workspace", tfjson.ActionDelete),
+			change(`tfe_variable.staging-foo`, "tfe_variable", tfjson.ActionDelete, tfjson.ActionCreate),
+			change(`tfe_team_access.teams["staging-team-abc123"]`, "tfe_team_access", tfjson.ActionDelete),
+			change(`tfe_run_trigger.trigger["staging-ws-def456"]`, "tfe_run_trigger", tfjson.ActionCreate),
+		},
+	}
+}
+
+func TestNewDestroyProtection(t *testing.T) {
+	t.Run("protect workspaces by default", func(t *testing.T) {
+		policy, err := NewDestroyProtection(DestroyProtectionInput{}, false)
+		require.NoError(t, err)
+
+		assert.Equal(t, &DestroyProtection{
+			DenyDestroy: map[string]bool{"tfe_workspace": true},
+			DenyReplace: map[string]bool{"tfe_workspace": true},
+		}, policy)
+	})
+
+	t.Run("lift workspace protection", func(t *testing.T) {
+		policy, err := NewDestroyProtection(DestroyProtectionInput{DenyDestroy: []string{"tfe_workspace", "tfe_variable"}}, true)
+		require.NoError(t, err)
+
+		assert.Equal(t, &DestroyProtection{
+			DenyDestroy: map[string]bool{"tfe_variable": true},
+			DenyReplace: map[string]bool{"tfe_variable": true},
+		}, policy)
+	})
+
+	t.Run("reject a negative max destroy", func(t *testing.T) {
+		_, err := NewDestroyProtection(DestroyProtectionInput{MaxDestroy: -1}, false)
+		assert.EqualError(t, err, "max_destroy must not be negative, got -1")
+	})
+}
+
+func TestDestroyProtectionCheck(t *testing.T) {
+	t.Run("list violating resources", func(t *testing.T) {
+		policy, err := NewDestroyProtection(DestroyProtectionInput{
+			DenyDestroy: []string{"tfe_workspace", "tfe_team_access"},
+			DenyReplace: []string{"tfe_variable"},
+		}, false)
+		require.NoError(t, err)
+
+		violations, destroyCount := policy.Violations(newTestDestroyPlan())
+
+		assert.Equal(t, []DestroyViolation{
+			{Address: `tfe_workspace.workspace["staging"]`, Action: "destroy"},
+			{Address: "tfe_variable.staging-foo", Action: "replace"},
+			{Address: `tfe_team_access.teams["staging-team-abc123"]`, Action: "destroy"},
+		}, violations)
+		assert.Equal(t, 3, destroyCount)
+
+		assert.EqualError(t, policy.Check(newTestDestroyPlan()), "the plan violates the destroy protection policy:\n"+
+			"  - tfe_workspace.workspace[\"staging\"] would be destroyed, which is not allowed\n"+
+			"  - tfe_variable.staging-foo would be replaced, which is not allowed\n"+
+			"  - tfe_team_access.teams[\"staging-team-abc123\"] would be destroyed, which is not allowed\n"+
+			"allow_workspace_deletion must be true to allow workspace deletion. Deleting a workspace will permanently, irrecoverably delete all of its stored Terraform state versions")
+	})
+
+	t.Run("enforce max destroy", func(t *testing.T) {
+		policy, err := NewDestroyProtection(DestroyProtectionInput{DenyDestroy: []string{}, MaxDestroy: 2}, false)
+		require.NoError(t, err)
+
+		assert.EqualError(t, policy.Check(newTestDestroyPlan()), "the plan violates the destroy protection policy:\n"+
+			"  - 3 resources would be destroyed, more than max_destroy 2")
+	})
+
+	t.Run("allow destroys within the policy", func(t *testing.T) {
+		policy, err := NewDestroyProtection(DestroyProtectionInput{MaxDestroy: 3}, true)
+		require.NoError(t, err)
+
+		assert.NoError(t, policy.Check(newTestDestroyPlan()))
+	})
+}
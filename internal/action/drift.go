@@ -0,0 +1,356 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	tfe "github.com/hashicorp/go-tfe"
+)
+
+// ModeDriftCheck compares the live Terraform Cloud settings with the desired configuration without running Terraform
+const ModeDriftCheck = "drift-check"
+
+type DriftAction string
+
+const (
+	// DriftMissing is a resource that is configured but does not exist in Terraform Cloud
+	DriftMissing DriftAction = "missing"
+	// DriftUnexpected is a resource that exists in Terraform Cloud but is not configured
+	DriftUnexpected DriftAction = "unexpected"
+	// DriftChanged is an attribute whose live value differs from the configured value
+	DriftChanged DriftAction = "changed"
+)
+
+// redactedValue replaces sensitive values in a drift report
+const redactedValue = "(sensitive)"
+
+// Drift is a single difference between the desired configuration and the live Terraform Cloud settings
+type Drift struct {
+	Workspace string      `json:"workspace"`
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Attribute string      `json:"attribute,omitempty"`
+	Action    DriftAction `json:"action"`
+	Desired   interface{} `json:"desired,omitempty"`
+	Live      interface{} `json:"live,omitempty"`
+}
+
+// String returns a single line description of the drift
+func (d Drift) String() string {
+	name := fmt.Sprintf("%s %q", strings.ReplaceAll(d.Type, "_", " "), d.Name)
+
+	switch d.Action {
+	case DriftMissing:
+		return fmt.Sprintf("%s: %s is missing", d.Workspace, name)
+	case DriftUnexpected:
+		return fmt.Sprintf("%s: %s is not configured", d.Workspace, name)
+	default:
+		return fmt.Sprintf("%s: %s %s changed from %v to %v", d.Workspace, name, d.Attribute, d.Desired, d.Live)
+	}
+}
+
+// compareAttribute appends a changed drift to the report if the desired and live values differ
+func compareAttribute(report []Drift, base Drift, attribute string, desired interface{}, live interface{}) []Drift {
+	if reflect.DeepEqual(desired, live) {
+		return report
+	}
+
+	base.Attribute = attribute
+	base.Action = DriftChanged
+	base.Desired = desired
+	base.Live = live
+
+	return append(report, base)
+}
+
+// CheckDrift compares the live workspaces, variables, team access and run triggers with the configuration that NewWorkspaceConfig would render
+func CheckDrift(ctx context.Context, client *tfe.Client, workspaces []*Workspace, config *NewWorkspaceConfigOptions) ([]Drift, error) {
+	options := config.WorkspaceResourceOptions
+
+	settings, err := EffectiveWorkspaceSettings(workspaces, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// the teams of the organization are shared by every workspace
+	teams, err := FetchRelatedTeams(ctx, client, options.Organization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch teams: %w", err)
+	}
+
+	report := []Drift{}
+
+	for _, ws := range workspaces {
+		if ws.ID == nil {
+			report = append(report, Drift{Workspace: ws.Name, Type: "workspace", Name: ws.Name, Action: DriftMissing})
+			continue
+		}
+
+		live, err := client.Workspaces.ReadByID(ctx, *ws.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read workspace %q: %w", ws.Name, err)
+		}
+
+		report = append(report, WorkspaceDrift(live, ws, settings[ws.Workspace], options)...)
+
+		variables, err := FetchRelatedVariables(ctx, client, ws)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch variables of workspace %q: %w", ws.Name, err)
+		}
+
+		report = append(report, VariableDrift(variables, config.Variables, ws)...)
+
+		tfeTeamAccess, err := FetchRelatedTeamAccess(ctx, client, ws)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch team access of workspace %q: %w", ws.Name, err)
+		}
+
+		teamAccess, err := ToTeamAccessItems(tfeTeamAccess, teams, ws)
+		if err != nil {
+			return nil, err
+		}
+
+		report = append(report, TeamAccessDrift(teamAccess, config.TeamAccess, ws)...)
+
+		triggers, err := FetchInboundRunTriggers(ctx, client, *ws.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch run triggers of workspace %q: %w", ws.Name, err)
+		}
+
+		report = append(report, RunTriggerDrift(triggers, config.RunTriggers, ws)...)
+	}
+
+	return report, nil
+}
+
+// WorkspaceDrift compares the configured workspace attributes with the live workspace. Attributes that are not configured are not compared.
+func WorkspaceDrift(live *tfe.Workspace, ws *Workspace, settings WorkspaceSettingsInput, options *WorkspaceResourceOptions) []Drift {
+	report := []Drift{}
+	base := Drift{Workspace: ws.Name, Type: "workspace", Name: ws.Name}
+
+	if settings.AutoApply != nil {
+		report = compareAttribute(report, base, "auto_apply", *settings.AutoApply, live.AutoApply)
+	}
+
+	liveAgentPoolID := live.AgentPoolID
+	if live.AgentPool != nil {
+		liveAgentPoolID = live.AgentPool.ID
+	}
+
+	liveSSHKeyID := ""
+	if live.SSHKey != nil {
+		liveSSHKeyID = live.SSHKey.ID
+	}
+
+	for _, attr := range []struct {
+		Name    string
+		Desired string
+		Live    string
+	}{
+		{"agent_pool_id", settings.AgentPoolID, liveAgentPoolID},
+		{"description", settings.Description, live.Description},
+		{"execution_mode", settings.ExecutionMode, live.ExecutionMode},
+		{"ssh_key_id", settings.SSHKeyID, liveSSHKeyID},
+		{"terraform_version", settings.TerraformVersion, live.TerraformVersion},
+		{"working_directory", settings.WorkingDirectory, live.WorkingDirectory},
+	} {
+		if attr.Desired != "" {
+			report = compareAttribute(report, base, attr.Name, attr.Desired, attr.Live)
+		}
+	}
+
+	for _, attr := range []struct {
+		Name    string
+		Desired *bool
+		Live    bool
+	}{
+		{"file_triggers_enabled", options.FileTriggersEnabled, live.FileTriggersEnabled},
+		{"global_remote_state", options.GlobalRemoteState, live.GlobalRemoteState},
+		{"queue_all_runs", options.QueueAllRuns, live.QueueAllRuns},
+		{"speculative_enabled", options.SpeculativeEnabled, live.SpeculativeEnabled},
+	} {
+		if attr.Desired != nil {
+			report = compareAttribute(report, base, attr.Name, *attr.Desired, attr.Live)
+		}
+	}
+
+	if tags, ok := options.Tags[ws.Workspace]; ok {
+		desired := []string{}
+		for _, t := range tags {
+			desired = append(desired, string(t))
+		}
+
+		live := append([]string{}, live.TagNames...)
+
+		sort.Strings(desired)
+		sort.Strings(live)
+
+		report = compareAttribute(report, base, "tag_names", desired, live)
+	}
+
+	switch {
+	case options.VCSRepo == "" || (options.VCSType == "" && options.VCSTokenID == ""):
+		if live.VCSRepo != nil {
+			report = compareAttribute(report, base, "vcs_repo", nil, live.VCSRepo.Identifier)
+		}
+	case live.VCSRepo == nil:
+		report = compareAttribute(report, base, "vcs_repo", options.VCSRepo, nil)
+	default:
+		report = compareAttribute(report, base, "vcs_repo.identifier", options.VCSRepo, live.VCSRepo.Identifier)
+		report = compareAttribute(report, base, "vcs_repo.ingress_submodules", options.VCSIngressSubmodules, live.VCSRepo.IngressSubmodules)
+
+		if options.VCSTokenID != "" {
+			report = compareAttribute(report, base, "vcs_repo.oauth_token_id", options.VCSTokenID, live.VCSRepo.OAuthTokenID)
+		}
+	}
+
+	return report
+}
+
+// VariableDrift compares the configured variables of the passed workspace with its live variables. Sensitive values are redacted.
+func VariableDrift(live []*tfe.Variable, variables Variables, ws *Workspace) []Drift {
+	report := []Drift{}
+	desired := map[string]Variable{}
+
+	for _, v := range variables {
+		if v.Workspace.Workspace == ws.Workspace {
+			desired[v.Key] = v
+		}
+	}
+
+	found := map[string]bool{}
+
+	for _, l := range live {
+		base := Drift{Workspace: ws.Name, Type: "variable", Name: l.Key}
+
+		v, ok := desired[l.Key]
+		if !ok {
+			base.Action = DriftUnexpected
+			report = append(report, base)
+
+			continue
+		}
+
+		found[l.Key] = true
+
+		if !l.Sensitive && !v.Sensitive {
+			report = compareAttribute(report, base, "value", v.Value, l.Value)
+		} else if !l.Sensitive && v.Value != l.Value {
+			report = append(report, Drift{Workspace: ws.Name, Type: "variable", Name: l.Key, Attribute: "value", Action: DriftChanged, Desired: redactedValue, Live: redactedValue})
+		}
+
+		report = compareAttribute(report, base, "category", v.Category, string(l.Category))
+		report = compareAttribute(report, base, "description", v.Description, l.Description)
+		report = compareAttribute(report, base, "sensitive", v.Sensitive, l.Sensitive)
+		report = compareAttribute(report, base, "hcl", v.HCL, l.HCL)
+	}
+
+	for _, v := range variables {
+		if v.Workspace.Workspace == ws.Workspace && !found[v.Key] {
+			report = append(report, Drift{Workspace: ws.Name, Type: "variable", Name: v.Key, Action: DriftMissing})
+		}
+	}
+
+	return report
+}
+
+// TeamAccessDrift compares the configured team access of the passed workspace with its live team access
+func TeamAccessDrift(live []TeamAccessItem, teamAccess TeamAccess, ws *Workspace) []Drift {
+	report := []Drift{}
+	desired := map[string]TeamAccessItem{}
+
+	for _, ta := range teamAccess {
+		if ta.Workspace.Workspace == ws.Workspace {
+			desired[ta.TeamName] = ta
+		}
+	}
+
+	found := map[string]bool{}
+
+	for _, l := range live {
+		base := Drift{Workspace: ws.Name, Type: "team_access", Name: l.TeamName}
+
+		ta, ok := desired[l.TeamName]
+		if !ok {
+			base.Action = DriftUnexpected
+			report = append(report, base)
+
+			continue
+		}
+
+		found[l.TeamName] = true
+
+		if ta.Access != "" {
+			report = compareAttribute(report, base, "access", ta.Access, l.Access)
+		}
+
+		if ta.Permissions != nil {
+			livePermissions := TeamAccessPermissionsInput{}
+			if l.Permissions != nil {
+				livePermissions = *l.Permissions
+			}
+
+			// run task permissions are not returned by the team access API
+			livePermissions.RunTasks = ta.Permissions.RunTasks
+
+			report = compareAttribute(report, base, "permissions", *ta.Permissions, livePermissions)
+		}
+	}
+
+	for _, ta := range teamAccess {
+		if ta.Workspace.Workspace == ws.Workspace && !found[ta.TeamName] {
+			report = append(report, Drift{Workspace: ws.Name, Type: "team_access", Name: ta.TeamName, Action: DriftMissing})
+		}
+	}
+
+	return report
+}
+
+// RunTriggerDrift compares the configured inbound run triggers of the passed workspace with its live run triggers.
+// Sources configured by name are matched by name, sources configured by ID are matched by ID.
+func RunTriggerDrift(live []*tfe.RunTrigger, triggers RunTriggers, ws *Workspace) []Drift {
+	report := []Drift{}
+	matched := map[string]bool{}
+
+	for _, t := range triggers {
+		if t.Workspace.Workspace != ws.Workspace {
+			continue
+		}
+
+		name := t.SourceName
+		if name == "" {
+			name = t.SourceID
+		}
+
+		found := false
+
+		for _, l := range live {
+			if (t.SourceName != "" && l.SourceableName == t.SourceName) || (t.SourceName == "" && l.Sourceable != nil && l.Sourceable.ID == t.SourceID) {
+				matched[l.ID] = true
+				found = true
+			}
+		}
+
+		if !found {
+			report = append(report, Drift{Workspace: ws.Name, Type: "run_trigger", Name: name, Action: DriftMissing})
+		}
+	}
+
+	for _, l := range live {
+		if matched[l.ID] {
+			continue
+		}
+
+		name := l.SourceableName
+		if name == "" && l.Sourceable != nil {
+			name = l.Sourceable.ID
+		}
+
+		report = append(report, Drift{Workspace: ws.Name, Type: "run_trigger", Name: name, Action: DriftUnexpected})
+	}
+
+	return report
+}
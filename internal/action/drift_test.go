@@ -0,0 +1,190 @@
+package action
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tfe "github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceDrift(t *testing.T) {
+	ws := newTestWorkspace()
+
+	t.Run("report changed configured attributes", func(t *testing.T) {
+		live := &tfe.Workspace{
+			AutoApply:        false,
+			Description:      "clicked",
+			ExecutionMode:    "remote",
+			TerraformVersion: "1.1.0",
+			QueueAllRuns:     true,
+			TagNames:         []string{"b", "a"},
+		}
+
+		report := WorkspaceDrift(live, ws, WorkspaceSettingsInput{
+			AutoApply:        boolPtr(true),
+			Description:      "managed",
+			ExecutionMode:    "remote",
+			TerraformVersion: "1.1.0",
+		}, &WorkspaceResourceOptions{
+			QueueAllRuns: boolPtr(false),
+			Tags:         map[string]Tags{"default": {"a", "b"}},
+		})
+
+		assert.Equal(t, []Drift{
+			{Workspace: "ws", Type: "workspace", Name: "ws", Attribute: "auto_apply", Action: DriftChanged, Desired: true, Live: false},
+			{Workspace: "ws", Type: "workspace", Name: "ws", Attribute: "description", Action: DriftChanged, Desired: "managed", Live: "clicked"},
+			{Workspace: "ws", Type: "workspace", Name: "ws", Attribute: "queue_all_runs", Action: DriftChanged, Desired: false, Live: true},
+		}, report)
+	})
+
+	t.Run("report VCS changes", func(t *testing.T) {
+		live := &tfe.Workspace{
+			VCSRepo: &tfe.VCSRepo{Identifier: "org/other", OAuthTokenID: "ot-abc123"},
+		}
+
+		report := WorkspaceDrift(live, ws, WorkspaceSettingsInput{}, &WorkspaceResourceOptions{
+			VCSRepo:    "org/repo",
+			VCSTokenID: "ot-abc123",
+		})
+
+		assert.Equal(t, []Drift{
+			{Workspace: "ws", Type: "workspace", Name: "ws", Attribute: "vcs_repo.identifier", Action: DriftChanged, Desired: "org/repo", Live: "org/other"},
+		}, report)
+
+		report = WorkspaceDrift(live, ws, WorkspaceSettingsInput{}, &WorkspaceResourceOptions{})
+
+		assert.Equal(t, []Drift{
+			{Workspace: "ws", Type: "workspace", Name: "ws", Attribute: "vcs_repo", Action: DriftChanged, Live: "org/other"},
+		}, report)
+	})
+}
+
+func TestVariableDrift(t *testing.T) {
+	ws := newTestWorkspace()
+	other := &Workspace{Name: "other", Workspace: "other"}
+
+	variables := Variables{
+		{Key: "region", Value: "us-east-1", Category: "env", Workspace: ws},
+		{Key: "secret", Value: "desired-secret", Category: "env", Sensitive: true, Workspace: ws},
+		{Key: "token", Value: "abc", Category: "env", Sensitive: true, Workspace: ws},
+		{Key: "missing", Value: "foo", Category: "terraform", Workspace: ws},
+		{Key: "elsewhere", Value: "foo", Category: "terraform", Workspace: other},
+	}
+
+	report := VariableDrift([]*tfe.Variable{
+		{Key: "region", Value: "us-west-2", Category: tfe.CategoryEnv},
+		{Key: "secret", Value: "live-secret", Category: tfe.CategoryEnv},
+		{Key: "token", Category: tfe.CategoryEnv, Sensitive: true},
+		{Key: "clicked", Value: "bar", Category: tfe.CategoryEnv},
+	}, variables, ws)
+
+	assert.Equal(t, []Drift{
+		{Workspace: "ws", Type: "variable", Name: "region", Attribute: "value", Action: DriftChanged, Desired: "us-east-1", Live: "us-west-2"},
+		{Workspace: "ws", Type: "variable", Name: "secret", Attribute: "value", Action: DriftChanged, Desired: redactedValue, Live: redactedValue},
+		{Workspace: "ws", Type: "variable", Name: "secret", Attribute: "sensitive", Action: DriftChanged, Desired: true, Live: false},
+		{Workspace: "ws", Type: "variable", Name: "clicked", Action: DriftUnexpected},
+		{Workspace: "ws", Type: "variable", Name: "missing", Action: DriftMissing},
+	}, report)
+}
+
+func TestTeamAccessDrift(t *testing.T) {
+	ws := newTestWorkspace()
+
+	report := TeamAccessDrift([]TeamAccessItem{
+		{TeamName: "readers", Access: "write", Workspace: ws},
+		{TeamName: "clicked", Access: "admin", Workspace: ws},
+	}, TeamAccess{
+		{TeamName: "readers", Access: "read", Workspace: ws},
+		{TeamName: "writers", Access: "write", Workspace: ws},
+	}, ws)
+
+	assert.Equal(t, []Drift{
+		{Workspace: "ws", Type: "team_access", Name: "readers", Attribute: "access", Action: DriftChanged, Desired: "read", Live: "write"},
+		{Workspace: "ws", Type: "team_access", Name: "clicked", Action: DriftUnexpected},
+		{Workspace: "ws", Type: "team_access", Name: "writers", Action: DriftMissing},
+	}, report)
+}
+
+func TestRunTriggerDrift(t *testing.T) {
+	ws := newTestWorkspace()
+
+	report := RunTriggerDrift([]*tfe.RunTrigger{
+		{ID: "rt-1", SourceableName: "source", Sourceable: &tfe.Workspace{ID: "ws-source"}},
+		{ID: "rt-2", SourceableName: "by-id", Sourceable: &tfe.Workspace{ID: "ws-by-id"}},
+		{ID: "rt-3", SourceableName: "clicked", Sourceable: &tfe.Workspace{ID: "ws-clicked"}},
+	}, RunTriggers{
+		{SourceName: "source", SourceID: "${data.tfe_workspace.run_trigger_workspaces[\"source\"].id}", Workspace: ws},
+		{SourceID: "ws-by-id", Workspace: ws},
+		{SourceID: "ws-missing", Workspace: ws},
+	}, ws)
+
+	assert.Equal(t, []Drift{
+		{Workspace: "ws", Type: "run_trigger", Name: "ws-missing", Action: DriftMissing},
+		{Workspace: "ws", Type: "run_trigger", Name: "clicked", Action: DriftUnexpected},
+	}, report)
+}
+
+func TestCheckDrift(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123", testServerResHandler(t, 200, `{"data": {"id": "ws-abc123", "type": "workspaces", "attributes": {"name": "foo-staging", "auto-apply": false}}}`))
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123/vars", testServerPagesHandler(t, [][]string{
+		{`{"id": "var-1", "type": "vars", "attributes": {"key": "foo", "value": "bar", "category": "env"}}`},
+	}))
+	mux.HandleFunc("/api/v2/workspaces/ws-def456", testServerResHandler(t, 200, `{"data": {"id": "ws-def456", "type": "workspaces", "attributes": {"name": "foo-dev", "auto-apply": true}}}`))
+	mux.HandleFunc("/api/v2/workspaces/ws-def456/vars", testServerPagesHandler(t, [][]string{
+		{`{"id": "var-2", "type": "vars", "attributes": {"key": "foo", "value": "bar", "category": "env"}}`},
+	}))
+
+	teamRequests := 0
+	teamsHandler := testServerPagesHandler(t, newTestTeamPages([]string{"readers"}))
+
+	mux.HandleFunc("/api/v2/organizations/org/teams", func(w http.ResponseWriter, r *http.Request) {
+		teamRequests++
+		teamsHandler(w, r)
+	})
+	mux.HandleFunc("/api/v2/team-workspaces", testServerPagesHandler(t, [][]string{
+		{`{"id": "tws-1", "type": "team-workspaces", "attributes": {"access": "read"}, "relationships": {"team": {"data": {"id": "team-readers", "type": "teams"}}}}`},
+	}))
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123/run-triggers", testServerPagesHandler(t, [][]string{{}}))
+	mux.HandleFunc("/api/v2/workspaces/ws-def456/run-triggers", testServerPagesHandler(t, [][]string{{}}))
+
+	client := newTestTFClient(t, server.URL)
+
+	workspaces := []*Workspace{
+		{Name: "foo-staging", Workspace: "staging", ID: strPtr("ws-abc123")},
+		{Name: "foo-production", Workspace: "production"},
+		{Name: "foo-dev", Workspace: "dev", ID: strPtr("ws-def456")},
+	}
+
+	report, err := CheckDrift(ctx, client, workspaces, &NewWorkspaceConfigOptions{
+		WorkspaceResourceOptions: &WorkspaceResourceOptions{
+			AutoApply:    boolPtr(true),
+			Organization: "org",
+		},
+		Variables: Variables{
+			{Key: "foo", Value: "bar", Category: "env", Workspace: workspaces[0]},
+			{Key: "foo", Value: "bar", Category: "env", Workspace: workspaces[2]},
+		},
+		TeamAccess: NewTeamAccess(TeamAccessInput{{TeamName: "readers", Access: "read"}}, workspaces),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, teamRequests)
+
+	assert.Equal(t, []Drift{
+		{Workspace: "foo-staging", Type: "workspace", Name: "foo-staging", Attribute: "auto_apply", Action: DriftChanged, Desired: true, Live: false},
+		{Workspace: "foo-production", Type: "workspace", Name: "foo-production", Action: DriftMissing},
+	}, report)
+}
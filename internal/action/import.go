@@ -2,17 +2,18 @@ package action
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	tfe "github.com/hashicorp/go-tfe"
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	tfjson "github.com/hashicorp/terraform-json"
-	"github.com/sethvargo/go-githubactions"
 	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfconfig"
 )
 
-var maxPageSize int = 100
-
 func shouldImport(ctx context.Context, tf TerraformCLI, address string) (bool, error) {
 	state, err := tf.Show(ctx)
 	if err != nil {
@@ -40,7 +41,7 @@ type TerraformCLI interface {
 // ImportWorkspace imports the passed workspace into Terraform state
 func ImportWorkspace(ctx context.Context, tf TerraformCLI, client *tfe.Client, workspace *Workspace, organization string, opts ...tfexec.ImportOption) error {
 	if workspace.ID == nil {
-		githubactions.Infof("Workspace %q not found, skipping import\n", workspace.Name)
+		gha.Infof("Workspace %q not found, skipping import\n", workspace.Name)
 		return nil
 	}
 
@@ -52,18 +53,18 @@ func ImportWorkspace(ctx context.Context, tf TerraformCLI, client *tfe.Client, w
 	}
 
 	if !imp {
-		githubactions.Infof("Workspace %q already exists in state, skipping import\n", workspace.Name)
+		gha.Infof("Workspace %q already exists in state, skipping import\n", workspace.Name)
 		return nil
 	}
 
-	githubactions.Infof("Importing workspace: %s\n", workspace.Name)
+	gha.Infof("Importing workspace: %s\n", workspace.Name)
 
 	err = tf.Import(ctx, address, *workspace.ID, opts...)
 	if err != nil {
 		return err
 	}
 
-	githubactions.Infof("Successful workspace import: %s\n", workspace.Name)
+	gha.Infof("Successful workspace import: %s\n", workspace.Name)
 
 	return nil
 }
@@ -71,7 +72,7 @@ func ImportWorkspace(ctx context.Context, tf TerraformCLI, client *tfe.Client, w
 // ImportVariable imports the passed variable into Terraform state
 func ImportVariable(ctx context.Context, tf TerraformCLI, v *tfe.Variable, workspace *Workspace, organization string, opts ...tfexec.ImportOption) error {
 	if workspace.ID == nil {
-		githubactions.Infof("Workspace %q not found, skipping import\n", workspace.Name)
+		gha.Infof("Workspace %q not found, skipping import\n", workspace.Name)
 		return nil
 	}
 
@@ -83,11 +84,11 @@ func ImportVariable(ctx context.Context, tf TerraformCLI, v *tfe.Variable, works
 	}
 
 	if !imp {
-		githubactions.Infof("Variable %q already exists in state, skipping import\n", address)
+		gha.Infof("Variable %q already exists in state, skipping import\n", address)
 		return nil
 	}
 
-	githubactions.Infof("Importing variable: %q\n", address)
+	gha.Infof("Importing variable: %q\n", address)
 
 	importID := fmt.Sprintf("%s/%s/%s", organization, workspace.Name, v.ID)
 
@@ -96,23 +97,26 @@ func ImportVariable(ctx context.Context, tf TerraformCLI, v *tfe.Variable, works
 		return err
 	}
 
-	githubactions.Infof("Variable %q successfully imported\n", importID)
+	gha.Infof("Variable %q successfully imported\n", importID)
 
 	return nil
 }
 
 // GetTeam returns a Team object if a team matching the passed name is found in the target Terraform account, nil is returned if the team is not found
 func GetTeam(ctx context.Context, client *tfe.Client, teamName string, organization string) (*tfe.Team, error) {
-	teams, err := client.Teams.List(ctx, organization, tfe.TeamListOptions{
-		ListOptions: tfe.ListOptions{
-			PageSize: maxPageSize,
-		},
+	teams, err := listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]*tfe.Team, *tfe.Pagination, error) {
+		list, err := client.Teams.List(ctx, organization, &tfe.TeamListOptions{ListOptions: options})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return list.Items, list.Pagination, nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	for _, t := range teams.Items {
+	for _, t := range teams {
 		if t.Name == teamName {
 			return t, nil
 		}
@@ -124,7 +128,7 @@ func GetTeam(ctx context.Context, client *tfe.Client, teamName string, organizat
 // ImportTeamAccess imports a team access resource by looking up an existing relation
 func ImportTeamAccess(ctx context.Context, tf TerraformCLI, access *tfe.TeamAccess, workspace *Workspace, organization string, opts ...tfexec.ImportOption) error {
 	if workspace.ID == nil {
-		githubactions.Infof("Workspace %q not found, skipping team access import\n", workspace.Name)
+		gha.Infof("Workspace %q not found, skipping team access import\n", workspace.Name)
 		return nil
 	}
 
@@ -136,11 +140,11 @@ func ImportTeamAccess(ctx context.Context, tf TerraformCLI, access *tfe.TeamAcce
 	}
 
 	if !imp {
-		githubactions.Infof("Team access %q already exists in state, skipping import\n", address)
+		gha.Infof("Team access %q already exists in state, skipping import\n", address)
 		return nil
 	}
 
-	githubactions.Infof("Importing team access: %q\n", address)
+	gha.Infof("Importing team access: %q\n", address)
 
 	importID := fmt.Sprintf("%s/%s/%s", organization, workspace.Name, access.ID)
 
@@ -148,7 +152,7 @@ func ImportTeamAccess(ctx context.Context, tf TerraformCLI, access *tfe.TeamAcce
 		return err
 	}
 
-	githubactions.Infof("Team access %q successfully imported\n", importID)
+	gha.Infof("Team access %q successfully imported\n", importID)
 
 	return nil
 }
@@ -156,7 +160,7 @@ func ImportTeamAccess(ctx context.Context, tf TerraformCLI, access *tfe.TeamAcce
 // ImportRunTriggers imports all related inbound run triggers to the passed workspace
 func ImportRunTriggers(ctx context.Context, tf TerraformCLI, triggers []*tfe.RunTrigger, client *tfe.Client, workspace *Workspace) error {
 	if workspace.ID == nil {
-		githubactions.Infof("Workspace %q not found, skipping run trigger import\n", workspace.Name)
+		gha.Infof("Workspace %q not found, skipping run trigger import\n", workspace.Name)
 		return nil
 	}
 
@@ -169,106 +173,542 @@ func ImportRunTriggers(ctx context.Context, tf TerraformCLI, triggers []*tfe.Run
 		}
 
 		if !imp {
-			githubactions.Infof("Run trigger %q already exists in state, skipping import\n", address)
+			gha.Infof("Run trigger %q already exists in state, skipping import\n", address)
 			return nil
 		}
 
-		githubactions.Infof("Importing run trigger: %q\n", address)
+		gha.Infof("Importing run trigger: %q\n", address)
 
 		if err := tf.Import(ctx, address, trigger.ID); err != nil {
 			return err
 		}
 
-		githubactions.Infof("Run trigger %q successfully imported\n", address)
+		gha.Infof("Run trigger %q successfully imported\n", address)
 	}
 
 	return nil
 }
 
-// ImportWorkspaceResources discovers and imports resources related to the passed workspace
-func ImportWorkspaceResources(ctx context.Context, client *tfe.Client, tf *tfexec.Terraform, filePath string, workspace *Workspace, organization string, providers []Provider) error {
+// ImportNotificationConfiguration imports an existing notification configuration into Terraform state
+func ImportNotificationConfiguration(ctx context.Context, tf TerraformCLI, nc *tfe.NotificationConfiguration, workspace *Workspace, opts ...tfexec.ImportOption) error {
 	if workspace.ID == nil {
-		githubactions.Infof("Workspace %q is not found, skipping import", workspace.Name)
+		gha.Infof("Workspace %q not found, skipping notification configuration import\n", workspace.Name)
 		return nil
 	}
 
-	module := NewModule()
+	address := fmt.Sprintf("tfe_notification_configuration.notifications[%q]", resourceKey(workspace.Workspace, nc.Name))
 
-	wsConfig, err := NewWorkspaceResource(ctx, client, []*Workspace{workspace}, &WorkspaceResourceOptions{})
+	imp, err := shouldImport(ctx, tf, address)
 	if err != nil {
 		return err
 	}
 
-	module.AppendResource("tfe_workspace", "workspace", wsConfig)
+	if !imp {
+		gha.Infof("Notification configuration %q already exists in state, skipping import\n", address)
+		return nil
+	}
 
-	variables, err := FetchRelatedVariables(ctx, client, workspace)
+	gha.Infof("Importing notification configuration: %q\n", address)
+
+	if err = tf.Import(ctx, address, nc.ID, opts...); err != nil {
+		return err
+	}
+
+	gha.Infof("Notification configuration %q successfully imported\n", address)
+
+	return nil
+}
+
+// importAddress imports the resource at the passed address unless it already exists in state
+func importAddress(ctx context.Context, tf TerraformCLI, resourceType string, address string, importID string, opts ...tfexec.ImportOption) error {
+	imp, err := shouldImport(ctx, tf, address)
 	if err != nil {
 		return err
 	}
 
-	for _, variable := range variables {
-		v := ToVariable(variable, workspace)
+	if !imp {
+		gha.Infof("%s %q already exists in state, skipping import\n", resourceType, address)
+		return nil
+	}
+
+	gha.Infof("Importing %s: %q\n", strings.ToLower(resourceType), address)
 
-		module.AppendResource("tfe_variable", fmt.Sprintf("%s-%s", workspace.Workspace, v.Key), v.ToResource())
+	if err := tf.Import(ctx, address, importID, opts...); err != nil {
+		return err
 	}
 
-	tfeTeams, err := FetchRelatedTeams(ctx, client, workspace, organization)
+	gha.Infof("%s %q successfully imported\n", resourceType, address)
+
+	return nil
+}
+
+// variableSetImportTargets returns the addresses and import IDs of the existing managed variable sets, their variables
+// and the attachments of every variable set to the passed workspaces
+func variableSetImportTargets(ctx context.Context, client *tfe.Client, sets []*VariableSet, organization string) ([]importTarget, error) {
+	if len(sets) == 0 {
+		return nil, nil
+	}
+
+	existing, err := FetchVariableSets(ctx, client, organization)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []importTarget
+
+	for _, set := range sets {
+		vs := FindVariableSetByName(existing, set.Input.Name)
+		if vs == nil {
+			gha.Infof("Variable set %q not found, skipping import\n", set.Input.Name)
+			continue
+		}
+
+		if set.Input.Managed() {
+			targets = append(targets, importTarget{"Variable set", fmt.Sprintf("tfe_variable_set.variable_sets[%q]", set.Input.Name), vs.ID})
+
+			variables, err := FetchVariableSetVariables(ctx, client, vs.ID)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, v := range variables {
+				if !set.Input.Variables.hasKey(v.Key) {
+					continue
+				}
+
+				address := fmt.Sprintf("tfe_variable.variable_set_variables[%q]", resourceKey(set.Input.Name, v.Key))
+
+				targets = append(targets, importTarget{"Variable set variable", address, fmt.Sprintf("%s/%s/%s", organization, vs.ID, v.ID)})
+			}
+		}
+
+		for _, ws := range set.Workspaces {
+			if ws.ID == nil || !isAttached(vs, *ws.ID) {
+				continue
+			}
+
+			address := fmt.Sprintf("tfe_workspace_variable_set.variable_sets[%q]", resourceKey(ws.Workspace, set.Input.Name))
+
+			targets = append(targets, importTarget{"Workspace variable set", address, fmt.Sprintf("%s/%s/%s", organization, ws.Name, vs.Name)})
+		}
+	}
+
+	return targets, nil
+}
+
+// ImportVariableSets imports existing managed variable sets, their variables and the attachments of every variable set to the passed workspaces
+func ImportVariableSets(ctx context.Context, client *tfe.Client, tf TerraformCLI, sets []*VariableSet, organization string, opts ...tfexec.ImportOption) error {
+	targets, err := variableSetImportTargets(ctx, client, sets, organization)
 	if err != nil {
 		return err
 	}
 
+	for _, target := range targets {
+		if err := importAddress(ctx, tf, target.ResourceType, target.Address, target.ID, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// workspaceImport holds the existing resources of a workspace that are imported into state
+type workspaceImport struct {
+	Workspace     *Workspace
+	Variables     []*tfe.Variable
+	TeamAccess    []*tfe.TeamAccess
+	RunTriggers   []*tfe.RunTrigger
+	Notifications []*tfe.NotificationConfiguration
+
+	TeamAccessItems TeamAccess
+}
+
+// fetchWorkspaceImport discovers the existing resources of the passed workspace, looking up team access in the passed teams of the organization
+func fetchWorkspaceImport(ctx context.Context, client *tfe.Client, workspace *Workspace, teams []*tfe.Team, notifications []*Notification) (*workspaceImport, error) {
+	variables, err := FetchRelatedVariables(ctx, client, workspace)
+	if err != nil {
+		return nil, err
+	}
+
 	tfeTeamAccess, err := FetchRelatedTeamAccess(ctx, client, workspace)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	teamAccess, err := ToTeamAccessItems(tfeTeamAccess, tfeTeams, workspace)
+	teamAccess, err := ToTeamAccessItems(tfeTeamAccess, teams, workspace)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	tfeTriggers, err := FetchInboundRunTriggers(ctx, client, *workspace.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tfeNotifications []*tfe.NotificationConfiguration
+
+	if wsNotifications := FilterNotifications(notifications, workspace); len(wsNotifications) > 0 {
+		existing, err := FetchNotificationConfigurations(ctx, client, *workspace.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range wsNotifications {
+			if nc := FindNotificationConfigurationByName(existing, n.Input.Name); nc != nil {
+				tfeNotifications = append(tfeNotifications, nc)
+			}
+		}
+	}
+
+	return &workspaceImport{
+		Workspace:       workspace,
+		Variables:       variables,
+		TeamAccess:      tfeTeamAccess,
+		RunTriggers:     tfeTriggers,
+		Notifications:   tfeNotifications,
+		TeamAccessItems: teamAccess,
+	}, nil
+}
+
+// importTarget is an existing resource imported at a Terraform address
+type importTarget struct {
+	ResourceType string
+	Address      string
+	ID           string
+}
+
+// Targets returns the addresses and import IDs of the existing resources of the workspace
+func (w *workspaceImport) Targets(organization string) []importTarget {
+	ws := w.Workspace
+
+	targets := []importTarget{
+		{"Workspace", fmt.Sprintf("tfe_workspace.workspace[%q]", ws.Workspace), *ws.ID},
+	}
+
+	for _, v := range w.Variables {
+		targets = append(targets, importTarget{"Variable", fmt.Sprintf("tfe_variable.%s-%s", ws.Workspace, v.Key), fmt.Sprintf("%s/%s/%s", organization, ws.Name, v.ID)})
+	}
+
+	for _, access := range w.TeamAccess {
+		targets = append(targets, importTarget{"Team access", fmt.Sprintf("tfe_team_access.teams[\"%s-%s\"]", ws.Workspace, access.Team.ID), fmt.Sprintf("%s/%s/%s", organization, ws.Name, access.ID)})
+	}
+
+	for _, trigger := range w.RunTriggers {
+		targets = append(targets, importTarget{"Run trigger", fmt.Sprintf("tfe_run_trigger.trigger[\"%s-%s\"]", ws.Workspace, trigger.Sourceable.ID), trigger.ID})
+	}
+
+	for _, nc := range w.Notifications {
+		targets = append(targets, importTarget{"Notification configuration", fmt.Sprintf("tfe_notification_configuration.notifications[%q]", resourceKey(ws.Workspace, nc.Name)), nc.ID})
+	}
+
+	return targets
+}
+
+// newImportModule returns a module configuring the existing resources of the passed workspaces, so they can be imported
+func newImportModule(ctx context.Context, client *tfe.Client, imports []*workspaceImport, organization string) (*tfconfig.Module, error) {
+	module := NewModule()
+
+	workspaces := make([]*Workspace, 0, len(imports))
+	for _, imp := range imports {
+		workspaces = append(workspaces, imp.Workspace)
+	}
+
+	wsConfig, err := NewWorkspaceResource(ctx, client, workspaces, &WorkspaceResourceOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	module.AppendResource("tfe_workspace", "workspace", wsConfig)
+
+	var (
+		teamAccess    TeamAccess
+		triggers      RunTriggers
+		notifications []*Notification
+	)
+
+	for _, imp := range imports {
+		for _, variable := range imp.Variables {
+			v := ToVariable(variable, imp.Workspace)
+
+			module.AppendResource("tfe_variable", fmt.Sprintf("%s-%s", imp.Workspace.Workspace, v.Key), v.ToResource())
+		}
+
+		teamAccess = append(teamAccess, imp.TeamAccessItems...)
+		triggers = append(triggers, ToRunTriggers(imp.RunTriggers, imp.Workspace)...)
+
+		for _, nc := range imp.Notifications {
+			notifications = append(notifications, ToNotification(nc, imp.Workspace))
+		}
 	}
 
 	AppendTeamAccess(module, teamAccess, organization)
+	AppendRunTriggers(module, triggers)
+	AppendNotifications(module, notifications)
 
-	tfeTriggers, err := FetchInboundRunTriggers(ctx, client, *workspace.ID)
+	return module, nil
+}
+
+// stateCachingCLI is a Terraform CLI reading the state once, and again only after an import changed it
+type stateCachingCLI struct {
+	TerraformCLI
+
+	state *tfjson.State
+}
+
+func (c *stateCachingCLI) Show(ctx context.Context, opts ...tfexec.ShowOption) (*tfjson.State, error) {
+	if c.state != nil && len(opts) == 0 {
+		return c.state, nil
+	}
+
+	state, err := c.TerraformCLI.Show(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts) == 0 {
+		c.state = state
+	}
+
+	return state, nil
+}
+
+func (c *stateCachingCLI) Import(ctx context.Context, address string, id string, opts ...tfexec.ImportOption) error {
+	c.state = nil
+
+	return c.TerraformCLI.Import(ctx, address, id, opts...)
+}
+
+// ImportWorkspaceResources discovers and imports resources related to the passed workspace, one resource at a time
+func ImportWorkspaceResources(ctx context.Context, client *tfe.Client, tf *tfexec.Terraform, filePath string, workspace *Workspace, organization string, notifications []*Notification, providers []Provider, output OutputDirectory) error {
+	if workspace.ID == nil {
+		gha.Infof("Workspace %q is not found, skipping import", workspace.Name)
+		return nil
+	}
+
+	teams, err := FetchRelatedTeams(ctx, client, organization)
 	if err != nil {
 		return err
 	}
 
-	AppendRunTriggers(module, ToRunTriggers(tfeTriggers, workspace))
+	imp, err := fetchWorkspaceImport(ctx, client, workspace, teams, notifications)
+	if err != nil {
+		return err
+	}
+
+	module, err := newImportModule(ctx, client, []*workspaceImport{imp}, organization)
+	if err != nil {
+		return err
+	}
 
 	AddProviders(module, providers)
 
+	if err := output.SaveModule(filepath.Join("import", workspace.Workspace+".tf.json"), module); err != nil {
+		return err
+	}
+
 	if err := TerraformInit(ctx, tf, module, filePath); err != nil {
 		return err
 	}
 
-	if err := ImportWorkspace(ctx, tf, client, workspace, organization); err != nil {
+	cli := &stateCachingCLI{TerraformCLI: tf}
+
+	if err := ImportWorkspace(ctx, cli, client, workspace, organization); err != nil {
 		return err
 	}
 
-	for _, variable := range variables {
-		if err := ImportVariable(ctx, tf, variable, workspace, organization); err != nil {
+	for _, variable := range imp.Variables {
+		if err := ImportVariable(ctx, cli, variable, workspace, organization); err != nil {
 			return err
 		}
 	}
 
-	for _, access := range tfeTeamAccess {
-		if err := ImportTeamAccess(ctx, tf, access, workspace, organization); err != nil {
+	for _, access := range imp.TeamAccess {
+		if err := ImportTeamAccess(ctx, cli, access, workspace, organization); err != nil {
 			return err
 		}
 	}
 
-	if err := ImportRunTriggers(ctx, tf, tfeTriggers, client, workspace); err != nil {
+	if err := ImportRunTriggers(ctx, cli, imp.RunTriggers, client, workspace); err != nil {
 		return err
 	}
 
+	for _, nc := range imp.Notifications {
+		if err := ImportNotificationConfiguration(ctx, cli, nc, workspace); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// ImportResources discovers and imports resources related to the passed workspaces
-func ImportResources(ctx context.Context, client *tfe.Client, tf *tfexec.Terraform, module *tfconfig.Module, filePath string, workspaces []*Workspace, organization string, providers []Provider) error {
+// planImports discovers the existing resources of the passed workspaces and variable sets, and returns the import targets
+// that are missing from the state and configured in the passed module
+func planImports(ctx context.Context, client *tfe.Client, tf TerraformCLI, module *tfconfig.Module, workspaces []*Workspace, organization string, notifications []*Notification, variableSets []*VariableSet) ([]importTarget, error) {
+	state, err := tf.Show(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inState := map[string]bool{}
+
+	if state.Values != nil && state.Values.RootModule != nil {
+		for _, r := range state.Values.RootModule.Resources {
+			inState[r.Address] = true
+		}
+	}
+
+	var (
+		candidates []importTarget
+		teams      []*tfe.Team
+		refs       []string
+	)
+
 	for _, ws := range workspaces {
-		if err := ImportWorkspaceResources(ctx, client, tf, filePath, ws, organization, providers); err != nil {
+		if ws.ID == nil {
+			gha.Infof("Workspace %q not found, skipping import\n", ws.Name)
+			continue
+		}
+
+		if teams == nil {
+			if teams, err = FetchRelatedTeams(ctx, client, organization); err != nil {
+				return nil, err
+			}
+		}
+
+		imp, err := fetchWorkspaceImport(ctx, client, ws, teams, notifications)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, imp.Targets(organization)...)
+
+		refs = append(refs, fmt.Sprintf("${tfe_workspace.workspace[%q].id}", ws.Workspace), *ws.ID)
+
+		for _, trigger := range imp.RunTriggers {
+			refs = append(refs, fmt.Sprintf("${data.tfe_workspace.run_trigger_workspaces[%q].id}", trigger.SourceableName), trigger.Sourceable.ID)
+		}
+	}
+
+	for _, team := range teams {
+		refs = append(refs, fmt.Sprintf("${data.tfe_team.teams[%q].id}", team.Name), team.ID)
+	}
+
+	setTargets, err := variableSetImportTargets(ctx, client, variableSets, organization)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates = append(candidates, setTargets...)
+
+	configured, err := moduleAddresses(module, strings.NewReplacer(refs...))
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []importTarget
+
+	for _, target := range candidates {
+		if inState[target.Address] {
+			gha.Infof("%s %q already exists in state, skipping import\n", target.ResourceType, target.Address)
+			continue
+		}
+
+		if !configured[target.Address] {
+			gha.Warningf("%s %q is not configured, skipping import\n", target.ResourceType, target.Address)
+			continue
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// moduleAddresses returns the addresses of the resource instances of the passed module,
+// replacing the references in the for_each keys of its resources with the passed replacer
+func moduleAddresses(module *tfconfig.Module, refs *strings.Replacer) (map[string]bool, error) {
+	addresses := map[string]bool{}
+
+	for resourceType, resources := range module.Resources {
+		for name, resource := range resources {
+			b, err := json.Marshal(resource)
+			if err != nil {
+				return nil, err
+			}
+
+			var r struct {
+				ForEach interface{} `json:"for_each"`
+			}
+
+			if err := json.Unmarshal(b, &r); err != nil {
+				return nil, err
+			}
+
+			if r.ForEach == nil {
+				addresses[fmt.Sprintf("%s.%s", resourceType, name)] = true
+				continue
+			}
+
+			keys, ok := r.ForEach.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			for key := range keys {
+				addresses[fmt.Sprintf("%s.%s[%q]", resourceType, name, refs.Replace(key))] = true
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
+// supportsImportBlocks returns whether the passed Terraform CLI supports import blocks, added in Terraform 1.5
+func supportsImportBlocks(ctx context.Context, tf *tfexec.Terraform) (bool, error) {
+	v, _, err := tf.Version(ctx, false)
+	if err != nil {
+		return false, err
+	}
+
+	return v.GreaterThanOrEqual(version.Must(version.NewVersion("1.5.0"))), nil
+}
+
+// ImportResources discovers and imports resources related to the passed workspaces and variable sets.
+// With Terraform 1.5 or later, an import block is added to the passed module for each configured resource missing from the state,
+// so the resources are imported by the plan of the module. Otherwise they are imported one at a time, initializing Terraform for every workspace.
+// With readOnlyState set, the state must not change before the plan is applied, and an error is returned instead when earlier versions would import resources.
+func ImportResources(ctx context.Context, client *tfe.Client, tf *tfexec.Terraform, module *tfconfig.Module, filePath string, workspaces []*Workspace, organization string, notifications []*Notification, variableSets []*VariableSet, providers []Provider, output OutputDirectory, readOnlyState bool) error {
+	batch, err := supportsImportBlocks(ctx, tf)
+	if err != nil {
+		return fmt.Errorf("failed to read the Terraform version: %w", err)
+	}
+
+	if batch || readOnlyState {
+		targets, err := planImports(ctx, client, tf, module, workspaces, organization, notifications, variableSets)
+		if err != nil {
+			return err
+		}
+
+		if !batch && len(targets) > 0 {
+			addresses := make([]string, len(targets))
+			for i, target := range targets {
+				addresses[i] = target.Address
+			}
+
+			return fmt.Errorf("refusing to import %s, as Terraform versions earlier than 1.5 write imports to the state before the plan is applied. Import them with Terraform 1.5 or later, or in a run that doesn't save the plan", strings.Join(addresses, ", "))
+		}
+
+		for _, target := range targets {
+			gha.Infof("Planning the import of %s: %q\n", strings.ToLower(target.ResourceType), target.Address)
+
+			module.Imports = append(module.Imports, tfconfig.Import{To: target.Address, ID: target.ID})
+		}
+
+		return nil
+	}
+
+	for _, ws := range workspaces {
+		if err := ImportWorkspaceResources(ctx, client, tf, filePath, ws, organization, notifications, providers, output); err != nil {
 			return err
 		}
 
@@ -277,5 +717,5 @@ func ImportResources(ctx context.Context, client *tfe.Client, tf *tfexec.Terrafo
 		}
 	}
 
-	return nil
+	return ImportVariableSets(ctx, client, &stateCachingCLI{TerraformCLI: tf}, variableSets, organization)
 }
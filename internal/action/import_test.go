@@ -10,11 +10,14 @@ import (
 	"github.com/hashicorp/terraform-exec/tfexec"
 	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
 )
 
 type TestTFExec struct {
-	State      *tfjson.State
-	ImportArgs []*ImportArgs
+	State       *tfjson.State
+	ImportArgs  []*ImportArgs
+	StateMvArgs [][2]string
 }
 
 type ImportArgs struct {
@@ -37,6 +40,12 @@ func (tf *TestTFExec) Import(ctx context.Context, address string, ID string, opt
 	return nil
 }
 
+func (tf *TestTFExec) StateMv(ctx context.Context, source string, destination string, opts ...tfexec.StateMvCmdOption) error {
+	tf.StateMvArgs = append(tf.StateMvArgs, [2]string{source, destination})
+
+	return nil
+}
+
 func strPtr(s string) *string {
 	return &s
 }
@@ -284,6 +293,56 @@ func TestImportRelatedRunTriggers(t *testing.T) {
 	})
 }
 
+func TestImportNotificationConfiguration(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("import a notification configuration", func(t *testing.T) {
+		tf := TestTFExec{
+			State: &tfjson.State{},
+		}
+
+		err := ImportNotificationConfiguration(ctx, &tf, &tfe.NotificationConfiguration{ID: "nc-abc123", Name: "notify"}, newTestWorkspace())
+		require.NoError(t, err)
+
+		assert.Len(t, tf.ImportArgs, 1)
+		assert.Equal(t, &ImportArgs{
+			Address: "tfe_notification_configuration.notifications[\"default/notify\"]",
+			ID:      "nc-abc123",
+			Opts:    ([]tfexec.ImportOption)(nil),
+		}, tf.ImportArgs[0])
+	})
+
+	t.Run("skip import if the notification configuration already exists in state", func(t *testing.T) {
+		tf := TestTFExec{
+			State: &tfjson.State{
+				Values: &tfjson.StateValues{
+					RootModule: &tfjson.StateModule{
+						Resources: []*tfjson.StateResource{
+							{Address: "tfe_notification_configuration.notifications[\"default/notify\"]"},
+						},
+					},
+				},
+			},
+		}
+
+		err := ImportNotificationConfiguration(ctx, &tf, &tfe.NotificationConfiguration{ID: "nc-abc123", Name: "notify"}, newTestWorkspace())
+		require.NoError(t, err)
+
+		assert.Len(t, tf.ImportArgs, 0)
+	})
+
+	t.Run("skip import if the workspace is not set with an ID", func(t *testing.T) {
+		tf := TestTFExec{
+			State: &tfjson.State{},
+		}
+
+		err := ImportNotificationConfiguration(ctx, &tf, &tfe.NotificationConfiguration{ID: "nc-abc123", Name: "notify"}, &Workspace{Name: "ws", Workspace: "default"})
+		require.NoError(t, err)
+
+		assert.Len(t, tf.ImportArgs, 0)
+	})
+}
+
 var runTriggerAPIResponse string = `{
   "data": [
     {
@@ -457,3 +516,98 @@ var runTriggerAPIResponse string = `{
     }
   }
 }`
+
+func TestPlanImports(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123/vars", testServerPagesHandler(t, [][]string{
+		{
+			`{"id": "var-1", "type": "vars", "attributes": {"key": "foo", "value": "bar", "category": "env"}}`,
+			`{"id": "var-2", "type": "vars", "attributes": {"key": "region", "value": "us-east-1", "category": "env"}}`,
+		},
+	}))
+	mux.HandleFunc("/api/v2/organizations/org/teams", testServerPagesHandler(t, newTestTeamPages([]string{"readers"})))
+	mux.HandleFunc("/api/v2/team-workspaces", testServerPagesHandler(t, [][]string{
+		{`{"id": "tws-1", "type": "team-workspaces", "attributes": {"access": "read"}, "relationships": {"team": {"data": {"id": "team-readers", "type": "teams"}}}}`},
+	}))
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123/run-triggers", testServerPagesHandler(t, [][]string{
+		{`{"id": "rt-1", "type": "run-triggers", "attributes": {"sourceable-name": "source"}, "relationships": {"sourceable": {"data": {"id": "ws-source", "type": "workspaces"}}}}`},
+	}))
+
+	client := newTestTFClient(t, server.URL)
+
+	tf := &TestTFExec{
+		State: &tfjson.State{
+			Values: &tfjson.StateValues{
+				RootModule: &tfjson.StateModule{
+					Resources: []*tfjson.StateResource{
+						{Address: "tfe_workspace.workspace[\"staging\"]"},
+						{Address: "tfe_variable.staging-foo"},
+					},
+				},
+			},
+		},
+	}
+
+	staging := &Workspace{Name: "app-staging", Workspace: "staging", ID: strPtr("ws-abc123")}
+	workspaces := []*Workspace{
+		staging,
+		{Name: "app-production", Workspace: "production"},
+	}
+
+	module := NewModule()
+	module.AppendResource("tfe_workspace", "workspace", tfeprovider.Workspace{ForEach: map[string]*tfeprovider.Workspace{"staging": {}, "production": {}}})
+	module.AppendResource("tfe_variable", "staging-foo", tfeprovider.Variable{})
+	AppendTeamAccess(module, TeamAccess{{TeamName: "readers", Access: "read", Workspace: staging}}, "org")
+	AppendRunTriggers(module, RunTriggers{{SourceID: "${data.tfe_workspace.run_trigger_workspaces[\"source\"].id}", SourceName: "source", Workspace: staging}})
+
+	targets, err := planImports(ctx, client, tf, module, workspaces, "org", nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []importTarget{
+		{ResourceType: "Team access", Address: "tfe_team_access.teams[\"staging-team-readers\"]", ID: "org/app-staging/tws-1"},
+		{ResourceType: "Run trigger", Address: "tfe_run_trigger.trigger[\"staging-ws-source\"]", ID: "rt-1"},
+	}, targets, "the unconfigured variable is skipped")
+}
+
+func TestStateCachingCLI(t *testing.T) {
+	ctx := context.Background()
+
+	tf := &countingTFExec{TestTFExec: &TestTFExec{State: &tfjson.State{}}}
+	cli := &stateCachingCLI{TerraformCLI: tf}
+
+	for i := 0; i < 3; i++ {
+		_, err := cli.Show(ctx)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, tf.shows)
+
+	require.NoError(t, cli.Import(ctx, "tfe_variable.staging-foo", "org/app-staging/var-1"))
+
+	_, err := cli.Show(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, tf.shows)
+	assert.Len(t, tf.ImportArgs, 1)
+}
+
+// countingTFExec counts the calls to Show
+type countingTFExec struct {
+	*TestTFExec
+
+	shows int
+}
+
+func (tf *countingTFExec) Show(ctx context.Context, opts ...tfexec.ShowOption) (*tfjson.State, error) {
+	tf.shows++
+
+	return tf.TestTFExec.Show(ctx, opts...)
+}
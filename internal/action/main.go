@@ -7,27 +7,34 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/hashicorp/go-tfe"
 	"github.com/hashicorp/terraform-exec/tfexec"
-	"github.com/sethvargo/go-githubactions"
-	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfconfig"
 	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
-	yaml "gopkg.in/yaml.v2"
 )
 
 type Inputs struct {
 	Token                     string
 	Host                      string
 	Name                      string
+	PreviousName              string
 	Description               string
 	Tags                      string
 	WorkspaceTags             string
 	Organization              string
 	Apply                     bool
 	RunnerTerraformVersion    string
+	TerraformPath             string
+	TerraformMirrorURL        string
+	TerraformCacheDir         string
+	ProviderCacheDir          string
+	ProviderMirror            string
 	RemoteStates              string
 	Workspaces                string
+	WorkspaceRenames          string
 	Variables                 string
 	WorkspaceVariables        string
 	TeamAccess                string
@@ -53,11 +60,36 @@ type Inputs struct {
 	TFEProviderVersion        string
 	Import                    bool
 	AllowWorkspaceDeletion    bool
+	DestroyProtection         string
+	ConfigFile                string
+	WorkspaceSettings         string
+	WorkspaceNotifications    string
+	VariableSets              string
+	Mode                      string
+	Engine                    string
+	PlanArtifact              string
+	OutputDirectory           string
+	TerraformLogLevel         string
+	PRComment                 bool
+	GitHubToken               string
+	GitHubAPIURL              string
+
+	// Override is called with the config once the config file is applied, so settings such as command line flags take precedence over the file
+	Override func(config *Config)
 }
 
-func Run(config *Inputs) error {
+func Run(inputs *Inputs) error {
 	ctx := context.Background()
 
+	config, err := NewConfig(inputs)
+	if err != nil {
+		return err
+	}
+
+	if config.Mode == ModeRender {
+		return RunRender(ctx, config)
+	}
+
 	client, err := tfe.NewClient(&tfe.Config{
 		Address: fmt.Sprintf("https://%s", config.Host),
 		Token:   config.Token,
@@ -66,6 +98,51 @@ func Run(config *Inputs) error {
 		return fmt.Errorf("failed to create Terraform client: %w", err)
 	}
 
+	if config.Mode == ModeApply {
+		return RunApply(ctx, config, client)
+	}
+
+	workspaces, err := ParseWorkspaces(config.Workspaces, config.Name)
+	if err != nil {
+		return fmt.Errorf("failed to parse workspaces: %w", err)
+	}
+
+	previousName := config.PreviousName
+	if previousName == "" {
+		previousName = config.Name
+	}
+
+	previousWorkspaces, err := PreviousWorkspaces(config.Workspaces, previousName, config.WorkspaceRenames)
+	if err != nil {
+		return fmt.Errorf("failed to parse workspace renames: %w", err)
+	}
+
+	if err := SetWorkspaceIDs(ctx, client, workspaces, config.Organization); err != nil {
+		return fmt.Errorf("failed to set workspace IDs: %w", err)
+	}
+
+	if err := SetPreviousWorkspaceIDs(ctx, client, workspaces, previousWorkspaces, config.Organization); err != nil {
+		return fmt.Errorf("failed to set previous workspace IDs: %w", err)
+	}
+
+	wsConfig, err := NewRunOptions(config, workspaces)
+	if err != nil {
+		return err
+	}
+
+	destroyProtection, err := NewDestroyProtection(config.DestroyProtection, config.AllowWorkspaceDeletion)
+	if err != nil {
+		return fmt.Errorf("failed to parse destroy protection: %w", err)
+	}
+
+	if config.Mode == ModeDriftCheck {
+		return RunDriftCheck(ctx, client, workspaces, wsConfig)
+	}
+
+	if config.Engine == EngineAPI {
+		return RunAPIEngine(ctx, config, client, workspaces, wsConfig, destroyProtection)
+	}
+
 	workDir, err := ioutil.TempDir("", config.Name)
 	if err != nil {
 		return fmt.Errorf("failed to create working directory: %w", err)
@@ -73,70 +150,208 @@ func Run(config *Inputs) error {
 
 	defer os.RemoveAll(workDir)
 
-	tf, err := NewTerraformExec(ctx, workDir, config.RunnerTerraformVersion)
+	tf, err := NewTerraformExec(ctx, workDir, config.TerraformInstallOptions())
 	if err != nil {
 		return fmt.Errorf("failed to create tfexec instance: %w", err)
 	}
 
-	if err := writeTerraformrcFile(config.Host, config.Token); err != nil {
-		return fmt.Errorf("failed to write .terraformrc file")
+	output := OutputDirectory(config.OutputDirectory)
+
+	if err := output.SetLogPath(tf, config.TerraformLogLevel); err != nil {
+		return fmt.Errorf("failed to set the Terraform log path: %w", err)
 	}
 
-	var remoteStates map[string]tfconfig.RemoteState
+	if err := writeTerraformCLIConfigFile(tf, NewTerraformCLIConfig(config, workDir), workDir); err != nil {
+		return fmt.Errorf("failed to write the Terraform CLI configuration file: %w", err)
+	}
 
-	err = yaml.Unmarshal([]byte(config.RemoteStates), &remoteStates)
+	module, err := NewWorkspaceConfig(ctx, client, workspaces, wsConfig)
 	if err != nil {
-		return fmt.Errorf("failed to parse remote state blocks: %w", err)
+		return fmt.Errorf("failed to create new workspace configuration: %w", err)
 	}
 
-	var wsInputs []string
+	filePath := path.Join(workDir, "main.tf.json")
 
-	err = yaml.Unmarshal([]byte(config.Workspaces), &wsInputs)
-	if err != nil {
-		return fmt.Errorf("failed to decode workspaces: %w", err)
+	if err = TerraformInit(ctx, tf, module, filePath); err != nil {
+		return fmt.Errorf("failed to initialize the Terraform configuration: %w", err)
 	}
 
-	workspaces, err := ParseWorkspaces(wsInputs, config.Name)
-	if err != nil {
-		return fmt.Errorf("failed to parse workspaces: %w", err)
+	if config.Mode == ModePlanOnly {
+		// read the backend state without locking it and plan against a local copy
+		snapshot, err := PullState(ctx, tf, workDir)
+		if err != nil {
+			return err
+		}
+
+		module.Terraform.Backend = nil
+
+		if err = TerraformInit(ctx, tf, module, filePath, tfexec.Reconfigure(true)); err != nil {
+			return fmt.Errorf("failed to initialize the Terraform configuration: %w", err)
+		}
+
+		gha.Infof("Planning against state serial %d\n", snapshot.Serial)
+		gha.SetOutput("state_serial", strconv.FormatInt(snapshot.Serial, 10))
+	} else if !config.Apply && config.Mode != ModePlan {
+		// copy state to local backend to avoid mutating state when apply=false
+		module.Terraform.Backend = nil
+
+		if err = TerraformInit(ctx, tf, module, filePath); err != nil {
+			return fmt.Errorf("failed to initialize the Terraform configuration: %w", err)
+		}
 	}
 
-	if err := SetWorkspaceIDs(ctx, client, workspaces, config.Organization); err != nil {
-		return fmt.Errorf("failed to set workspace IDs: %w", err)
+	var snapshot *StateSnapshot
+
+	if config.Mode == ModePlan {
+		// the saved plan is applied against the backend state, which must not change before the plan is approved
+		if _, snapshot, err = ReadStateSnapshot(ctx, tf); err != nil {
+			return fmt.Errorf("failed to read the state from the configured backend: %w", err)
+		}
+
+		moves, err := RenamedWorkspaceMoves(ctx, tf, workspaces, previousWorkspaces)
+		if err != nil {
+			return fmt.Errorf("failed to move renamed workspaces: %w", err)
+		}
+
+		if len(moves) > 0 {
+			sources := make([]string, 0, len(moves))
+			for source := range moves {
+				sources = append(sources, source)
+			}
+
+			sort.Strings(sources)
+
+			return fmt.Errorf("refusing to move %s to the renamed workspaces, as the moves would change the state before the plan is applied. Rename the workspaces in a run that doesn't save the plan first", strings.Join(sources, ", "))
+		}
+	} else if err = MoveRenamedWorkspaces(ctx, tf, workspaces, previousWorkspaces); err != nil {
+		return fmt.Errorf("failed to move renamed workspaces: %w", err)
+	}
+
+	if config.Import {
+		if err = ImportResources(ctx, client, tf, module, filePath, workspaces, config.Organization, wsConfig.Notifications, wsConfig.VariableSets, wsConfig.Providers, output, config.Mode == ModePlan); err != nil {
+			return fmt.Errorf("failed to import resources: %w", err)
+		}
 	}
 
-	genVars := VariablesInput{}
+	if err := output.SaveModule("main.tf.json", module); err != nil {
+		return err
+	}
 
-	err = yaml.Unmarshal([]byte(config.Variables), &genVars)
-	if err != nil {
-		return fmt.Errorf("failed to parse variables %w", err)
+	if err := SetRenderedConfigOutput(module); err != nil {
+		return err
 	}
 
-	wsVars := WorkspaceVariablesInput{}
+	planPath := "plan.txt"
 
-	err = yaml.Unmarshal([]byte(config.WorkspaceVariables), &wsVars)
+	planOpts := []tfexec.PlanOption{
+		tfexec.Out(planPath),
+	}
+
+	diff, err := tf.Plan(ctx, planOpts...)
 	if err != nil {
-		return fmt.Errorf("failed to parse workspace variables %w", err)
+		return fmt.Errorf("failed to plan: %w", err)
 	}
 
-	wsNames := make([]string, len(workspaces))
-	for i, ws := range workspaces {
-		wsNames[i] = ws.Name
+	if diff {
+		planStr, err := tf.ShowPlanFileRaw(ctx, planPath)
+		if err != nil {
+			return fmt.Errorf("failed to show plan: %w", err)
+		}
+
+		gha.Infof(planStr)
+		gha.SetOutput("plan", planStr)
+
+		plan, err := tf.ShowPlanFile(ctx, planPath)
+		if err != nil {
+			return fmt.Errorf("failed to create plan struct: %w", err)
+		}
+
+		b, err := json.Marshal(plan)
+		if err != nil {
+			return fmt.Errorf("failed to convert plan to JSON: %w", err)
+		}
+
+		gha.SetOutput("plan_json", string(b))
+
+		if err := PublishPlanSummary(ctx, config, NewPlanChanges(plan, workspaces)); err != nil {
+			return err
+		}
+
+		if err := NewPlanOutputs(plan, workspaces).SetOutputs(); err != nil {
+			return err
+		}
+
+		if err := destroyProtection.Check(plan); err != nil {
+			return err
+		}
+
+		if err := CheckWorkspaceReplacements(ctx, client, plan, config.Organization); err != nil {
+			return err
+		}
+
+		if config.Apply {
+			gha.Infof("Applying...\n")
+
+			if err = tf.Apply(ctx, tfexec.DirOrPlan(planPath)); err != nil {
+				return fmt.Errorf("failed to apply: %w", err)
+			}
+
+			gha.Infof("Success\n")
+		}
+	} else {
+		gha.Infof("No changes\n")
+
+		if err := PublishPlanSummary(ctx, config, nil); err != nil {
+			return err
+		}
+
+		if err := NewPlanOutputs(nil, workspaces).SetOutputs(); err != nil {
+			return err
+		}
 	}
 
+	if config.Mode == ModePlan {
+		tfVersion, err := TerraformVersion(ctx, tf)
+		if err != nil {
+			return fmt.Errorf("failed to read the Terraform version: %w", err)
+		}
+
+		if err := SavePlanArtifact(workDir, planPath, config.PlanArtifact, &PlanArtifactMetadata{
+			Name:             config.Name,
+			TerraformVersion: tfVersion,
+			State:            *snapshot,
+		}); err != nil {
+			return err
+		}
+
+		gha.Infof("Saved the plan computed against state serial %d to %s\n", snapshot.Serial, config.PlanArtifact)
+		gha.SetOutput("state_serial", strconv.FormatInt(snapshot.Serial, 10))
+	}
+
+	if config.Apply {
+		if err := SetWorkspaceOutputs(ctx, tf, config.Host, config.Organization); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewRunOptions builds the workspace configuration options of the passed workspaces from the action config
+func NewRunOptions(config *Config, workspaces []*Workspace) (*NewWorkspaceConfigOptions, error) {
 	variables := Variables{}
 
 	for _, ws := range workspaces {
-		for _, v := range genVars {
+		for _, v := range config.Variables {
 			variables = append(variables, *NewVariable(v, ws))
 		}
 	}
 
-	for wsName, wvs := range wsVars {
+	for wsName, wvs := range config.WorkspaceVariables {
 		ws := FindWorkspace(workspaces, wsName)
 
 		if ws == nil {
-			return fmt.Errorf("failed to match workspace variable with known workspaces. Workspace %s not found", wsName)
+			return nil, fmt.Errorf("failed to match workspace variable with known workspaces. Workspace %s not found", wsName)
 		}
 
 		for _, v := range wvs {
@@ -146,56 +361,36 @@ func Run(config *Inputs) error {
 
 	variables.MaskSensitive()
 
-	var teamInputs TeamAccessInput
-
-	if err = yaml.Unmarshal([]byte(config.TeamAccess), &teamInputs); err != nil {
-		return fmt.Errorf("failed to parse teams: %w", err)
-	}
-
-	teamAccess := NewTeamAccess(teamInputs, workspaces)
+	teamAccess := NewTeamAccess(config.TeamAccess, workspaces)
 
-	backend, err := tfconfig.ParseBackend(config.BackendConfig)
+	tags, err := MergeWorkspaceTags(config.Tags, config.WorkspaceTags, workspaces)
 	if err != nil {
-		return fmt.Errorf("failed to parse backend configuration: %w", err)
-	}
-
-	var tagInputs Tags
-	if err = yaml.Unmarshal([]byte(config.Tags), &tagInputs); err != nil {
-		return fmt.Errorf("failed to decode tag names: %w", err)
-	}
-
-	var wsTagInputs map[string]Tags
-	if err = yaml.Unmarshal([]byte(config.WorkspaceTags), &wsTagInputs); err != nil {
-		return fmt.Errorf("failed to decode workspace tag names: %w", err)
+		return nil, fmt.Errorf("failed to format workspace tags: %w", err)
 	}
 
-	tags, err := MergeWorkspaceTags(tagInputs, wsTagInputs, workspaces)
+	triggers, err := MergeRunTriggers(config.RunTriggers, config.WorkspaceRunTriggers, workspaces, config.Organization)
 	if err != nil {
-		return fmt.Errorf("failed to format workspace tags: %w", err)
+		return nil, fmt.Errorf("failed to merge run triggers: %w", err)
 	}
 
-	var triggerInputs RunTriggerInputs
-	if err = yaml.Unmarshal([]byte(config.RunTriggers), &triggerInputs); err != nil {
-		return fmt.Errorf("failed to decode workspace tag names: %w", err)
+	notifications, err := MergeNotifications(config.NotificationConfiguration, config.WorkspaceNotifications, workspaces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge notifications: %w", err)
 	}
 
-	var workspaceTriggerInputs map[string]RunTriggerInputs
-	if err = yaml.Unmarshal([]byte(config.WorkspaceRunTriggers), &workspaceTriggerInputs); err != nil {
-		return fmt.Errorf("failed to decode workspace tag names: %w", err)
+	for _, n := range notifications {
+		n.MaskToken()
 	}
 
-	triggers, err := MergeRunTriggers(triggerInputs, workspaceTriggerInputs, workspaces, config.Organization)
+	variableSets, err := NewVariableSets(config.VariableSets, workspaces)
 	if err != nil {
-		return fmt.Errorf("failed to merge run triggers: %w", err)
+		return nil, fmt.Errorf("failed to parse variable sets: %w", err)
 	}
 
-	var notificationInput *NotificationInput
-	if err = yaml.Unmarshal([]byte(config.NotificationConfiguration), &notificationInput); err != nil {
-		return fmt.Errorf("failed to decode notification input: %w", err)
+	for _, set := range variableSets {
+		set.MaskSensitive()
 	}
 
-	notifications := MergeNotifications(notificationInput, workspaces)
-
 	providers := []Provider{
 		{
 			Name:    "tfe",
@@ -207,8 +402,8 @@ func Run(config *Inputs) error {
 		},
 	}
 
-	module, err := NewWorkspaceConfig(ctx, client, workspaces, &NewWorkspaceConfigOptions{
-		Backend: backend,
+	return &NewWorkspaceConfigOptions{
+		Backend: config.BackendConfig,
 		WorkspaceResourceOptions: &WorkspaceResourceOptions{
 			AgentPoolID:            config.AgentPoolID,
 			AutoApply:              config.AutoApply,
@@ -228,87 +423,71 @@ func Run(config *Inputs) error {
 			VCSTokenID:             config.VCSTokenID,
 			VCSType:                config.VCSType,
 			WorkingDirectory:       config.WorkingDirectory,
+			WorkspaceSettings:      config.WorkspaceSettings,
 		},
-		RemoteStates:  remoteStates,
+		RemoteStates:  config.RemoteStates,
 		Variables:     variables,
 		TeamAccess:    teamAccess,
 		RunTriggers:   triggers,
 		Notifications: notifications,
+		VariableSets:  variableSets,
 		Providers:     providers,
-	})
+	}, nil
+}
+
+// RunDriftCheck reports the differences between the live Terraform Cloud settings and the desired configuration, without running Terraform
+func RunDriftCheck(ctx context.Context, client *tfe.Client, workspaces []*Workspace, config *NewWorkspaceConfigOptions) error {
+	report, err := CheckDrift(ctx, client, workspaces, config)
 	if err != nil {
-		return fmt.Errorf("failed to create new workspace configuration: %w", err)
+		return fmt.Errorf("failed to check drift: %w", err)
 	}
 
-	filePath := path.Join(workDir, "main.tf.json")
-
-	if err = TerraformInit(ctx, tf, module, filePath); err != nil {
-		return fmt.Errorf("failed to initialize the Terraform configuration: %w", err)
+	b, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to convert drift report to JSON: %w", err)
 	}
 
-	if !config.Apply {
-		// copy state to local backend to avoid mutating state when apply=false
-		module.Terraform.Backend = nil
+	gha.SetOutput("drift", string(b))
+	gha.SetOutput("drift_detected", fmt.Sprintf("%t", len(report) > 0))
 
-		if err = TerraformInit(ctx, tf, module, filePath); err != nil {
-			return fmt.Errorf("failed to initialize the Terraform configuration: %w", err)
-		}
+	if len(report) == 0 {
+		gha.Infof("No drift detected\n")
+		return nil
 	}
 
-	if config.Import {
-		if err = ImportResources(ctx, client, tf, module, filePath, workspaces, config.Organization, providers); err != nil {
-			return fmt.Errorf("failed to import resources: %w", err)
-		}
+	for _, d := range report {
+		gha.Warningf("Drift detected: %s\n", d)
 	}
 
-	planPath := "plan.txt"
+	return nil
+}
 
-	planOpts := []tfexec.PlanOption{
-		tfexec.Out(planPath),
-	}
+// PublishPlanSummary writes the Markdown plan summary to the GitHub Actions job summary and, if enabled, to a pull request comment
+func PublishPlanSummary(ctx context.Context, config *Config, changes []PlanChange) error {
+	summary := PlanSummaryMarkdown(fmt.Sprintf("Terraform Cloud workspace plan: %s", config.Name), changes)
 
-	diff, err := tf.Plan(ctx, planOpts...)
-	if err != nil {
-		return fmt.Errorf("failed to plan: %w", err)
+	if err := WriteStepSummary(summary); err != nil {
+		return err
 	}
 
-	if diff {
-		planStr, err := tf.ShowPlanFileRaw(ctx, planPath)
-		if err != nil {
-			return fmt.Errorf("failed to show plan: %w", err)
-		}
-
-		githubactions.Infof(planStr)
-		githubactions.SetOutput("plan", planStr)
-
-		plan, err := tf.ShowPlanFile(ctx, planPath)
-		if err != nil {
-			return fmt.Errorf("failed to create plan struct: %w", err)
-		}
-
-		b, err := json.Marshal(plan)
-		if err != nil {
-			return fmt.Errorf("failed to convert plan to JSON: %w", err)
-		}
-
-		githubactions.SetOutput("plan_json", string(b))
-
-		if !config.AllowWorkspaceDeletion && WillDestroy(plan, "tfe_workspace") {
-			return fmt.Errorf("error: allow_workspace_deletion must be true to allow workspace deletion. Deleting a workspace will permanently, irrecoverably delete all of its stored Terraform state versions")
-		}
-
-		if config.Apply {
-			githubactions.Infof("Applying...\n")
+	if !config.PRComment {
+		return nil
+	}
 
-			if err = tf.Apply(ctx, tfexec.DirOrPlan(planPath)); err != nil {
-				return fmt.Errorf("failed to apply: %w", err)
-			}
+	number, err := PullRequestNumber(os.Getenv("GITHUB_EVENT_PATH"))
+	if err != nil {
+		return err
+	}
 
-			githubactions.Infof("Success\n")
-		}
-	} else {
-		githubactions.Infof("No changes\n")
+	if number == 0 {
+		gha.Infof("Not running for a pull request, skipping the plan comment\n")
+		return nil
 	}
 
-	return nil
+	return UpsertPullRequestComment(ctx, &PullRequestCommentOptions{
+		APIURL:     config.GitHubAPIURL,
+		Token:      config.GitHubToken,
+		Repository: os.Getenv("GITHUB_REPOSITORY"),
+		Number:     number,
+	}, config.Name, summary)
 }
@@ -58,8 +58,8 @@ func removeTestWorkspacesFunc(t *testing.T, ctx context.Context, client *tfe.Cli
 
 // removeTestWorkspaces deletes matching test workspaces created by the integration tests
 func removeTestWorkspaces(t *testing.T, ctx context.Context, client *tfe.Client, match string) {
-	workspaces, err := client.Workspaces.List(ctx, os.Getenv("TF_ORGANIZATION"), tfe.WorkspaceListOptions{
-		Search: tfe.String(match),
+	workspaces, err := client.Workspaces.List(ctx, os.Getenv("TF_ORGANIZATION"), &tfe.WorkspaceListOptions{
+		Search: match,
 		ListOptions: tfe.ListOptions{
 			PageSize: maxPageSize,
 		},
@@ -265,8 +265,8 @@ production:
 
 	t.Cleanup(removeTestWorkspacesFunc(t, ctx, client, inputs.Name))
 
-	ws, err := client.Workspaces.List(ctx, inputs.Organization, tfe.WorkspaceListOptions{
-		Search: &inputs.Name,
+	ws, err := client.Workspaces.List(ctx, inputs.Organization, &tfe.WorkspaceListOptions{
+		Search: inputs.Name,
 	})
 	require.NoError(t, err)
 
@@ -275,15 +275,15 @@ production:
 	err = Run(inputs)
 	require.NoError(t, err)
 
-	ws, err = client.Workspaces.List(ctx, inputs.Organization, tfe.WorkspaceListOptions{
-		Search: &inputs.Name,
+	ws, err = client.Workspaces.List(ctx, inputs.Organization, &tfe.WorkspaceListOptions{
+		Search: inputs.Name,
 	})
 	require.NoError(t, err)
 
 	assert.Len(t, ws.Items, 2)
 
 	for _, ws := range ws.Items {
-		v, err := client.Variables.List(ctx, ws.ID, tfe.VariableListOptions{})
+		v, err := client.Variables.List(ctx, ws.ID, &tfe.VariableListOptions{})
 		require.NoError(t, err)
 
 		assert.Len(t, v.Items, 1)
@@ -342,8 +342,8 @@ func TestWorkspaceRunTriggers(t *testing.T) {
 	err = Run(inputs)
 	require.NoError(t, err)
 
-	workspaces, err := client.Workspaces.List(ctx, inputs.Organization, tfe.WorkspaceListOptions{
-		Search: &inputs.Name,
+	workspaces, err := client.Workspaces.List(ctx, inputs.Organization, &tfe.WorkspaceListOptions{
+		Search: inputs.Name,
 	})
 	require.NoError(t, err)
 
@@ -354,8 +354,8 @@ func TestWorkspaceRunTriggers(t *testing.T) {
 		t.Fatal("alpha workspace not found")
 	}
 
-	triggers, err := client.RunTriggers.List(ctx, alpha.ID, tfe.RunTriggerListOptions{
-		RunTriggerType: tfe.String("inbound"),
+	triggers, err := client.RunTriggers.List(ctx, alpha.ID, &tfe.RunTriggerListOptions{
+		RunTriggerType: tfe.RunTriggerInbound,
 	})
 	require.NoError(t, err)
 
@@ -366,8 +366,8 @@ func TestWorkspaceRunTriggers(t *testing.T) {
 		t.Fatal("beta workspace not found")
 	}
 
-	triggers, err = client.RunTriggers.List(ctx, beta.ID, tfe.RunTriggerListOptions{
-		RunTriggerType: tfe.String("inbound"),
+	triggers, err = client.RunTriggers.List(ctx, beta.ID, &tfe.RunTriggerListOptions{
+		RunTriggerType: tfe.RunTriggerInbound,
 	})
 	require.NoError(t, err)
 
@@ -1,6 +1,14 @@
 package action
 
-import "github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	tfe "github.com/hashicorp/go-tfe"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfconfig"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
+)
 
 type NotificationInput struct {
 	Name            string `yaml:"name"`
@@ -14,35 +22,194 @@ type NotificationInput struct {
 	Triggers       []string `yaml:"triggers,omitempty"`
 }
 
+// NotificationInputs is a list of notification settings. A single map of notification settings is also accepted.
+type NotificationInputs []NotificationInput
+
+// UnmarshalYAML decodes either a list of notification settings or a single map of notification settings
+func (ni *NotificationInputs) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var list []NotificationInput
+
+	if err := unmarshal(&list); err == nil {
+		*ni = list
+		return nil
+	}
+
+	var single NotificationInput
+
+	if err := unmarshal(&single); err != nil {
+		return err
+	}
+
+	*ni = NotificationInputs{single}
+
+	return nil
+}
+
 type Notification struct {
 	Input     *NotificationInput
 	Workspace *Workspace
 }
 
-// MergeNotifications returns a list of notifications, one notification object per workspace
-func MergeNotifications(input *NotificationInput, workspaces []*Workspace) (notifications []*Notification) {
-	for _, ws := range workspaces {
-		if input != nil {
+// MergeNotifications returns a list of notifications, one notification object per workspace per notification input
+func MergeNotifications(inputs NotificationInputs, workspaceInputs map[string]NotificationInputs, workspaces []*Workspace) ([]*Notification, error) {
+	notifications := []*Notification{}
+
+	for i := range inputs {
+		for _, ws := range workspaces {
+			notifications = append(notifications, &Notification{
+				Input:     &inputs[i],
+				Workspace: ws,
+			})
+		}
+	}
+
+	for wsName, wsInputs := range workspaceInputs {
+		ws := FindWorkspace(workspaces, wsName)
+		if ws == nil {
+			return nil, fmt.Errorf("notifications specified for unknown workspace %q", wsName)
+		}
+
+		for i := range wsInputs {
 			notifications = append(notifications, &Notification{
-				Input:     input,
+				Input:     &wsInputs[i],
 				Workspace: ws,
 			})
 		}
 	}
 
-	return notifications
+	keys := map[string]bool{}
+
+	for _, n := range notifications {
+		if keys[n.Key()] {
+			return nil, fmt.Errorf("notification %q is specified more than once for workspace %q", n.Input.Name, n.Workspace.Workspace)
+		}
+
+		keys[n.Key()] = true
+	}
+
+	return notifications, nil
 }
 
+// Key returns the notification's unique for_each key
+func (n Notification) Key() string {
+	return resourceKey(n.Workspace.Workspace, n.Input.Name)
+}
+
+// MaskToken masks the notification's HMAC token in the GitHub Actions log output
+func (n Notification) MaskToken() {
+	if n.Input.Token == "" {
+		return
+	}
+
+	gha.Debugf("Masking the token of notification %q\n", n.Key())
+	gha.AddMask(n.Input.Token)
+}
+
+// ToResource returns a tfeprovider.NotificationConfiguration object from the calling Notification object
 func (n Notification) ToResource() *tfeprovider.NotificationConfiguration {
-	return &tfeprovider.NotificationConfiguration{
+	resource := &tfeprovider.NotificationConfiguration{
 		Name:            n.Input.Name,
 		DestinationType: n.Input.DestinationType,
 		URL:             n.Input.URL,
-		WorkspaceID:     *n.Workspace.ID,
-		EmailAddresses:  n.Input.EmailAddresses,
-		EmailUserIDs:    n.Input.EmailUserIDs,
+		WorkspaceID:     fmt.Sprintf("${tfe_workspace.workspace[%q].id}", n.Workspace.Workspace),
 		Enabled:         n.Input.Enabled,
 		Token:           n.Input.Token,
-		Triggers:        n.Input.Triggers,
 	}
+
+	if len(n.Input.EmailAddresses) > 0 {
+		resource.EmailAddresses = n.Input.EmailAddresses
+	}
+
+	if len(n.Input.EmailUserIDs) > 0 {
+		resource.EmailUserIDs = n.Input.EmailUserIDs
+	}
+
+	if len(n.Input.Triggers) > 0 {
+		resource.Triggers = n.Input.Triggers
+	}
+
+	return resource
+}
+
+// AppendNotifications takes a list of notifications and adds them to the passed module
+func AppendNotifications(module *tfconfig.Module, notifications []*Notification) {
+	if len(notifications) == 0 {
+		return
+	}
+
+	notificationForEach := map[string]tfeprovider.NotificationConfiguration{}
+
+	for _, n := range notifications {
+		notificationForEach[n.Key()] = *n.ToResource()
+	}
+
+	module.AppendResource("tfe_notification_configuration", "notifications", tfeprovider.NotificationConfiguration{
+		ForEach:         notificationForEach,
+		Name:            "${each.value.name}",
+		DestinationType: "${each.value.destination_type}",
+		WorkspaceID:     "${each.value.workspace_id}",
+		URL:             "${lookup(each.value, \"url\", null)}",
+		EmailAddresses:  "${lookup(each.value, \"email_addresses\", null)}",
+		EmailUserIDs:    "${lookup(each.value, \"email_user_ids\", null)}",
+		Enabled:         "${lookup(each.value, \"enabled\", null)}",
+		Token:           "${lookup(each.value, \"token\", null)}",
+		Triggers:        "${lookup(each.value, \"triggers\", null)}",
+	})
+}
+
+// FilterNotifications returns the notifications of the passed workspace
+func FilterNotifications(notifications []*Notification, workspace *Workspace) []*Notification {
+	var filtered []*Notification
+
+	for _, n := range notifications {
+		if n.Workspace.Workspace == workspace.Workspace {
+			filtered = append(filtered, n)
+		}
+	}
+
+	return filtered
+}
+
+// ToNotification takes a tfe.NotificationConfiguration and returns a Notification
+func ToNotification(nc *tfe.NotificationConfiguration, workspace *Workspace) *Notification {
+	input := &NotificationInput{
+		Name:            nc.Name,
+		DestinationType: string(nc.DestinationType),
+		URL:             nc.URL,
+		EmailAddresses:  nc.EmailAddresses,
+		Enabled:         strconv.FormatBool(nc.Enabled),
+		Triggers:        nc.Triggers,
+	}
+
+	for _, u := range nc.EmailUsers {
+		input.EmailUserIDs = append(input.EmailUserIDs, u.ID)
+	}
+
+	return &Notification{
+		Input:     input,
+		Workspace: workspace,
+	}
+}
+
+// FetchNotificationConfigurations returns the notification configurations of the passed workspace
+func FetchNotificationConfigurations(ctx context.Context, client *tfe.Client, workspaceID string) ([]*tfe.NotificationConfiguration, error) {
+	return listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]*tfe.NotificationConfiguration, *tfe.Pagination, error) {
+		list, err := client.NotificationConfigurations.List(ctx, workspaceID, &tfe.NotificationConfigurationListOptions{ListOptions: options})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return list.Items, list.Pagination, nil
+	})
+}
+
+// FindNotificationConfigurationByName returns the notification configuration matching the passed name, or nil if none match
+func FindNotificationConfigurationByName(configurations []*tfe.NotificationConfiguration, name string) *tfe.NotificationConfiguration {
+	for _, nc := range configurations {
+		if nc.Name == name {
+			return nc
+		}
+	}
+
+	return nil
 }
@@ -1,10 +1,19 @@
 package action
 
 import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	tfe "github.com/hashicorp/go-tfe"
+	"github.com/sethvargo/go-githubactions"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func TestNotificationToResource(t *testing.T) {
@@ -20,36 +29,262 @@ func TestNotificationToResource(t *testing.T) {
 		assert.Equal(t, &tfeprovider.NotificationConfiguration{
 			Name:            "foo",
 			DestinationType: "email",
-			WorkspaceID:     "ws-abc123",
+			WorkspaceID:     "${tfe_workspace.workspace[\"default\"].id}",
 		}, n.ToResource())
 	})
 }
 
+func TestNotificationMaskToken(t *testing.T) {
+	var out bytes.Buffer
+
+	SetActions(githubactions.New(githubactions.WithWriter(&out)))
+	t.Cleanup(func() {
+		SetActions(githubactions.New())
+	})
+
+	Notification{Input: &NotificationInput{Name: "hooks", Token: "hmac-secret"}, Workspace: &Workspace{Workspace: "staging"}}.MaskToken()
+	Notification{Input: &NotificationInput{Name: "email"}, Workspace: &Workspace{Workspace: "staging"}}.MaskToken()
+
+	assert.Contains(t, out.String(), "::add-mask::hmac-secret\n")
+	assert.Equal(t, 1, strings.Count(out.String(), "::add-mask::"), "notifications without a token aren't masked")
+}
+
+func TestNotificationsForNewWorkspaces(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	client := newTestTFClient(t, server.URL)
+
+	workspaces, err := ParseWorkspaces([]string{"staging"}, "foo")
+	require.NoError(t, err)
+
+	notifications, err := MergeNotifications(NotificationInputs{{Name: "slack", DestinationType: "slack"}}, nil, workspaces)
+	require.NoError(t, err)
+
+	module, err := NewWorkspaceConfig(ctx, client, workspaces, &NewWorkspaceConfigOptions{
+		WorkspaceResourceOptions: &WorkspaceResourceOptions{
+			Organization: "org",
+		},
+		Notifications: notifications,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]tfeprovider.NotificationConfiguration{
+		"staging/slack": {
+			Name:            "slack",
+			DestinationType: "slack",
+			WorkspaceID:     "${tfe_workspace.workspace[\"staging\"].id}",
+		},
+	}, module.Resources["tfe_notification_configuration"]["notifications"].(tfeprovider.NotificationConfiguration).ForEach)
+}
+
 func TestMergeNotifications(t *testing.T) {
-	t.Run("return a notification list", func(t *testing.T) {
-		input := &NotificationInput{
-			Name:            "foo",
-			DestinationType: "email",
+	t.Run("return a notification per workspace per input", func(t *testing.T) {
+		inputs := NotificationInputs{
+			{Name: "slack", DestinationType: "slack"},
+			{Name: "email", DestinationType: "email"},
 		}
 
 		workspaces := newTestMultiWorkspaceList()
 
-		notifications := MergeNotifications(input, workspaces)
+		notifications, err := MergeNotifications(inputs, nil, workspaces)
+		require.NoError(t, err)
 
-		assert.Len(t, notifications, 2)
 		assert.Equal(t, []*Notification{
-			{Input: input, Workspace: workspaces[0]},
-			{Input: input, Workspace: workspaces[1]},
+			{Input: &inputs[0], Workspace: workspaces[0]},
+			{Input: &inputs[0], Workspace: workspaces[1]},
+			{Input: &inputs[1], Workspace: workspaces[0]},
+			{Input: &inputs[1], Workspace: workspaces[1]},
 		}, notifications)
 	})
 
-	t.Run("return an empty list if notification is nil", func(t *testing.T) {
-		input := (*NotificationInput)(nil)
+	t.Run("return an empty list if no notifications are passed", func(t *testing.T) {
+		notifications, err := MergeNotifications(nil, nil, newTestMultiWorkspaceList())
+		require.NoError(t, err)
+
+		assert.Len(t, notifications, 0)
+	})
+
+	t.Run("add workspace notifications to the specified workspace", func(t *testing.T) {
+		inputs := NotificationInputs{{Name: "slack", DestinationType: "slack"}}
+		wsInputs := map[string]NotificationInputs{
+			"production": {{Name: "pager", DestinationType: "generic"}},
+		}
 
 		workspaces := newTestMultiWorkspaceList()
 
-		notifications := MergeNotifications(input, workspaces)
+		notifications, err := MergeNotifications(inputs, wsInputs, workspaces)
+		require.NoError(t, err)
 
-		assert.Len(t, notifications, 0)
+		assert.Equal(t, []*Notification{
+			{Input: &inputs[0], Workspace: workspaces[0]},
+			{Input: &inputs[0], Workspace: workspaces[1]},
+			{Input: &wsInputs["production"][0], Workspace: workspaces[1]},
+		}, notifications)
+	})
+
+	t.Run("error when a workspace name does not match known workspaces", func(t *testing.T) {
+		_, err := MergeNotifications(nil, map[string]NotificationInputs{
+			"playground": {{Name: "slack", DestinationType: "slack"}},
+		}, newTestMultiWorkspaceList())
+
+		assert.EqualError(t, err, "notifications specified for unknown workspace \"playground\"")
+	})
+
+	t.Run("error when a notification name is used twice for a workspace", func(t *testing.T) {
+		_, err := MergeNotifications(NotificationInputs{{Name: "slack", DestinationType: "slack"}}, map[string]NotificationInputs{
+			"staging": {{Name: "slack", DestinationType: "slack"}},
+		}, newTestMultiWorkspaceList())
+
+		assert.EqualError(t, err, "notification \"slack\" is specified more than once for workspace \"staging\"")
+	})
+}
+
+func TestNotificationInputsUnmarshalYAML(t *testing.T) {
+	t.Run("decode a list of notifications", func(t *testing.T) {
+		var inputs NotificationInputs
+
+		require.NoError(t, yaml.Unmarshal([]byte(`
+- name: slack
+  destination_type: slack
+- name: email
+  destination_type: email
+`), &inputs))
+
+		assert.Equal(t, NotificationInputs{
+			{Name: "slack", DestinationType: "slack"},
+			{Name: "email", DestinationType: "email"},
+		}, inputs)
 	})
+
+	t.Run("decode a single notification", func(t *testing.T) {
+		var inputs NotificationInputs
+
+		require.NoError(t, yaml.Unmarshal([]byte(`
+name: slack
+destination_type: slack
+enabled: true
+`), &inputs))
+
+		assert.Equal(t, NotificationInputs{{Name: "slack", DestinationType: "slack", Enabled: "true"}}, inputs)
+	})
+}
+
+func TestAppendNotifications(t *testing.T) {
+	t.Run("add notifications for each workspace", func(t *testing.T) {
+		module := NewModule()
+		workspaces := newTestMultiWorkspaceList()
+
+		AppendNotifications(module, []*Notification{
+			{Workspace: workspaces[0], Input: &NotificationInput{Name: "slack", DestinationType: "slack", URL: "https://hooks.slack.com/foo"}},
+			{Workspace: workspaces[1], Input: &NotificationInput{Name: "email", DestinationType: "email", EmailAddresses: []string{"foo@email.com"}}},
+		})
+
+		assert.Equal(t, tfeprovider.NotificationConfiguration{
+			ForEach: map[string]tfeprovider.NotificationConfiguration{
+				"staging/slack": {
+					Name:            "slack",
+					DestinationType: "slack",
+					URL:             "https://hooks.slack.com/foo",
+					WorkspaceID:     "${tfe_workspace.workspace[\"staging\"].id}",
+				},
+				"production/email": {
+					Name:            "email",
+					DestinationType: "email",
+					EmailAddresses:  []string{"foo@email.com"},
+					WorkspaceID:     "${tfe_workspace.workspace[\"production\"].id}",
+				},
+			},
+			Name:            "${each.value.name}",
+			DestinationType: "${each.value.destination_type}",
+			WorkspaceID:     "${each.value.workspace_id}",
+			URL:             "${lookup(each.value, \"url\", null)}",
+			EmailAddresses:  "${lookup(each.value, \"email_addresses\", null)}",
+			EmailUserIDs:    "${lookup(each.value, \"email_user_ids\", null)}",
+			Enabled:         "${lookup(each.value, \"enabled\", null)}",
+			Token:           "${lookup(each.value, \"token\", null)}",
+			Triggers:        "${lookup(each.value, \"triggers\", null)}",
+		}, module.Resources["tfe_notification_configuration"]["notifications"])
+	})
+
+	t.Run("add nothing when no notifications are passed", func(t *testing.T) {
+		module := NewModule()
+
+		AppendNotifications(module, nil)
+
+		assert.NotContains(t, module.Resources, "tfe_notification_configuration")
+	})
+}
+
+func TestToNotification(t *testing.T) {
+	t.Run("convert a notification configuration", func(t *testing.T) {
+		workspace := newTestWorkspace()
+
+		n := ToNotification(&tfe.NotificationConfiguration{
+			ID:              "nc-abc123",
+			Name:            "notify",
+			DestinationType: tfe.NotificationDestinationTypeSlack,
+			URL:             "https://hooks.slack.com/foo",
+			Enabled:         true,
+			Triggers:        []string{string(tfe.NotificationTriggerErrored)},
+			EmailUsers:      []*tfe.User{{ID: "user-abc123"}},
+		}, workspace)
+
+		assert.Equal(t, &Notification{
+			Input: &NotificationInput{
+				Name:            "notify",
+				DestinationType: "slack",
+				URL:             "https://hooks.slack.com/foo",
+				Enabled:         "true",
+				Triggers:        []string{"run:errored"},
+				EmailUserIDs:    []string{"user-abc123"},
+			},
+			Workspace: workspace,
+		}, n)
+	})
+}
+
+func TestFetchNotificationConfigurations(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123/notification-configurations", testServerPagesHandler(t, [][]string{
+		{`{"id": "nc-1", "type": "notification-configurations", "attributes": {"name": "slack", "destination-type": "slack"}}`},
+		{`{"id": "nc-2", "type": "notification-configurations", "attributes": {"name": "email", "destination-type": "email"}}`},
+	}))
+
+	client := newTestTFClient(t, server.URL)
+
+	configurations, err := FetchNotificationConfigurations(ctx, client, "ws-abc123")
+	require.NoError(t, err)
+
+	assert.Len(t, configurations, 2)
+
+	t.Run("find a notification configuration by name", func(t *testing.T) {
+		assert.Equal(t, "nc-2", FindNotificationConfigurationByName(configurations, "email").ID)
+	})
+
+	t.Run("return nil if no notification configuration matches", func(t *testing.T) {
+		assert.Nil(t, FindNotificationConfigurationByName(configurations, "webhook"))
+	})
+}
+
+func TestNotificationKey(t *testing.T) {
+	a := Notification{Input: &NotificationInput{Name: "b-c"}, Workspace: &Workspace{Workspace: "a"}}
+	ab := Notification{Input: &NotificationInput{Name: "c"}, Workspace: &Workspace{Workspace: "a-b"}}
+
+	assert.Equal(t, "a/b-c", a.Key())
+	assert.NotEqual(t, a.Key(), ab.Key())
 }
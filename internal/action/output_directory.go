@@ -0,0 +1,154 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfconfig"
+)
+
+// sensitiveValuePlaceholder replaces the values of sensitive variables in the configuration published by a run
+const sensitiveValuePlaceholder = "(sensitive value)"
+
+// DefaultTerraformLogLevel is the level of the Terraform logs kept in the output directory if none is configured
+const DefaultTerraformLogLevel = "INFO"
+
+// OutputDirectory keeps the generated Terraform configuration and the Terraform logs of a run after the working directory is removed.
+// Nothing is kept if the directory is empty.
+type OutputDirectory string
+
+// SaveModule writes the passed module, with the values of sensitive variables redacted, to the named file of the output directory
+func (d OutputDirectory) SaveModule(name string, module *tfconfig.Module) error {
+	if d == "" {
+		return nil
+	}
+
+	filePath := filepath.Join(string(d), name)
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	redacted, err := redactModule(module)
+	if err != nil {
+		return fmt.Errorf("failed to redact %s: %w", name, err)
+	}
+
+	b, err := json.MarshalIndent(redacted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to convert %s to JSON: %w", name, err)
+	}
+
+	if err := ioutil.WriteFile(filePath, b, 0644); err != nil {
+		return fmt.Errorf("failed to save %s to the output directory: %w", name, err)
+	}
+
+	return nil
+}
+
+// SetLogPath writes the Terraform logs of the passed Terraform CLI at the passed level to the output directory
+func (d OutputDirectory) SetLogPath(tf *tfexec.Terraform, level string) error {
+	if d == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(string(d), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	logPath, err := filepath.Abs(filepath.Join(string(d), "terraform.log"))
+	if err != nil {
+		return err
+	}
+
+	if level == "" {
+		level = DefaultTerraformLogLevel
+	}
+
+	// tfexec logs at the TRACE level unless a level is set
+	if err := tf.SetLog(strings.ToUpper(level)); err != nil {
+		return err
+	}
+
+	return tf.SetLogPath(logPath)
+}
+
+// SetRenderedConfigOutput sets the terraform_config output to the generated Terraform configuration, with the values of sensitive variables redacted
+func SetRenderedConfigOutput(module *tfconfig.Module) error {
+	redacted, err := redactModule(module)
+	if err != nil {
+		return fmt.Errorf("failed to redact the Terraform configuration: %w", err)
+	}
+
+	b, err := json.Marshal(redacted)
+	if err != nil {
+		return fmt.Errorf("failed to convert the Terraform configuration to JSON: %w", err)
+	}
+
+	gha.SetOutput("terraform_config", string(b))
+
+	return nil
+}
+
+// redactModule returns the JSON representation of the passed module, with the values of its sensitive tfe_variable resources,
+// for_each values included, replaced by a placeholder
+func redactModule(module *tfconfig.Module) (map[string]interface{}, error) {
+	b, err := json.Marshal(module)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	if resources, ok := m["resource"].(map[string]interface{}); ok {
+		redactSensitiveValues(resources["tfe_variable"])
+		redactNotificationTokens(resources["tfe_notification_configuration"])
+	}
+
+	return m, nil
+}
+
+// redactNotificationTokens replaces the token of every notification configuration within the passed JSON value
+func redactNotificationTokens(v interface{}) {
+	resources, _ := v.(map[string]interface{})
+
+	for _, r := range resources {
+		resource, _ := r.(map[string]interface{})
+		forEach, _ := resource["for_each"].(map[string]interface{})
+
+		for _, n := range forEach {
+			if n, ok := n.(map[string]interface{}); ok && n["token"] != nil {
+				n["token"] = sensitiveValuePlaceholder
+			}
+		}
+	}
+}
+
+// redactSensitiveValues replaces the value of every object marked as sensitive within the passed JSON value
+func redactSensitiveValues(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if sensitive, _ := v["sensitive"].(bool); sensitive {
+			if _, ok := v["value"]; ok {
+				v["value"] = sensitiveValuePlaceholder
+			}
+		}
+
+		for _, child := range v {
+			redactSensitiveValues(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			redactSensitiveValues(child)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package action
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
+)
+
+func TestOutputDirectorySaveModule(t *testing.T) {
+	t.Run("save the module", func(t *testing.T) {
+		dir := t.TempDir()
+
+		module := NewModule()
+		module.AppendResource("tfe_workspace", "workspace", map[string]interface{}{"name": "foo"})
+
+		require.NoError(t, OutputDirectory(dir).SaveModule("import/staging.tf.json", module))
+
+		b, err := os.ReadFile(path.Join(dir, "import", "staging.tf.json"))
+		require.NoError(t, err)
+
+		assert.Contains(t, string(b), `"tfe_workspace"`)
+	})
+
+	t.Run("redact sensitive values", func(t *testing.T) {
+		dir := t.TempDir()
+
+		module := NewModule()
+		module.AppendResource("tfe_variable", "staging-token", tfeprovider.Variable{Key: "token", Value: "secret", Sensitive: true})
+		module.AppendResource("tfe_variable", "staging-region", tfeprovider.Variable{Key: "region", Value: "us-east-1"})
+		module.AppendResource("tfe_variable", "variable_set_variables", map[string]interface{}{
+			"for_each": map[string]interface{}{
+				"shared/password": map[string]interface{}{"key": "password", "value": "hunter2", "sensitive": true},
+			},
+			"value": "${each.value.value}",
+		})
+		AppendNotifications(module, []*Notification{
+			{Input: &NotificationInput{Name: "hooks", DestinationType: "generic", Token: "hmac-secret"}, Workspace: &Workspace{Workspace: "staging"}},
+		})
+
+		require.NoError(t, OutputDirectory(dir).SaveModule("main.tf.json", module))
+
+		b, err := os.ReadFile(path.Join(dir, "main.tf.json"))
+		require.NoError(t, err)
+
+		assert.NotContains(t, string(b), "secret")
+		assert.NotContains(t, string(b), "hunter2")
+		assert.NotContains(t, string(b), "hmac-secret")
+		assert.Contains(t, string(b), `"token": "(sensitive value)"`)
+		assert.Contains(t, string(b), `"us-east-1"`)
+		assert.Contains(t, string(b), `"value": "(sensitive value)"`)
+		assert.Contains(t, string(b), `"value": "${each.value.value}"`)
+
+		assert.Equal(t, "secret", module.Resources["tfe_variable"]["staging-token"].(tfeprovider.Variable).Value, "the module is left untouched")
+	})
+
+	t.Run("skip without an output directory", func(t *testing.T) {
+		assert.NoError(t, OutputDirectory("").SaveModule("main.tf.json", NewModule()))
+	})
+}
@@ -0,0 +1,151 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// PlanOutputs are the machine readable outputs computed from the resource changes of a plan
+type PlanOutputs struct {
+	HasChanges         bool
+	AddCount           int
+	ChangeCount        int
+	DestroyCount       int
+	AffectedWorkspaces []string
+}
+
+// NewPlanOutputs counts the managed resource changes of the plan the same way Terraform does, replacements count as both an add and a destroy.
+// A nil plan returns outputs without changes.
+func NewPlanOutputs(plan *tfjson.Plan, workspaces []*Workspace) *PlanOutputs {
+	outputs := &PlanOutputs{AffectedWorkspaces: []string{}}
+
+	if plan == nil {
+		return outputs
+	}
+
+	affected := map[string]bool{}
+
+	for _, rc := range plan.ResourceChanges {
+		if rc.Mode != tfjson.ManagedResourceMode || rc.Change == nil {
+			continue
+		}
+
+		switch {
+		case rc.Change.Actions.Replace():
+			outputs.AddCount++
+			outputs.DestroyCount++
+		case rc.Change.Actions.Create():
+			outputs.AddCount++
+		case rc.Change.Actions.Update():
+			outputs.ChangeCount++
+		case rc.Change.Actions.Delete():
+			outputs.DestroyCount++
+		default:
+			continue
+		}
+
+		if ws := ChangeWorkspace(rc, workspaces); ws != nil && !affected[ws.Name] {
+			affected[ws.Name] = true
+			outputs.AffectedWorkspaces = append(outputs.AffectedWorkspaces, ws.Name)
+		}
+	}
+
+	sort.Strings(outputs.AffectedWorkspaces)
+
+	outputs.HasChanges = outputs.AddCount+outputs.ChangeCount+outputs.DestroyCount > 0
+
+	return outputs
+}
+
+// SetOutputs sets the plan outputs of the action
+func (o *PlanOutputs) SetOutputs() error {
+	b, err := json.Marshal(o.AffectedWorkspaces)
+	if err != nil {
+		return fmt.Errorf("failed to convert affected workspaces to JSON: %w", err)
+	}
+
+	gha.SetOutput("has_changes", strconv.FormatBool(o.HasChanges))
+	gha.SetOutput("add_count", strconv.Itoa(o.AddCount))
+	gha.SetOutput("change_count", strconv.Itoa(o.ChangeCount))
+	gha.SetOutput("destroy_count", strconv.Itoa(o.DestroyCount))
+	gha.SetOutput("affected_workspaces", string(b))
+
+	return nil
+}
+
+// WorkspaceOutput identifies a workspace in the workspace_ids output
+type WorkspaceOutput struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// StateWorkspaceIDs returns the IDs and names of the workspaces in the passed state, keyed by the workspace key
+func StateWorkspaceIDs(state *tfjson.State) map[string]WorkspaceOutput {
+	ids := map[string]WorkspaceOutput{}
+
+	if state == nil || state.Values == nil || state.Values.RootModule == nil {
+		return ids
+	}
+
+	for _, r := range state.Values.RootModule.Resources {
+		if r.Mode != tfjson.ManagedResourceMode || r.Type != "tfe_workspace" || r.Name != "workspace" {
+			continue
+		}
+
+		key, _ := r.Index.(string)
+		name, _ := r.AttributeValues["name"].(string)
+		id, _ := r.AttributeValues["id"].(string)
+
+		if key != "" && id != "" {
+			ids[key] = WorkspaceOutput{ID: id, Name: name}
+		}
+	}
+
+	return ids
+}
+
+// WorkspaceURLs returns the Terraform Cloud URLs of the passed workspaces, keyed by the workspace key
+func WorkspaceURLs(ids map[string]WorkspaceOutput, host string, organization string) map[string]string {
+	urls := map[string]string{}
+
+	for key, ws := range ids {
+		urls[key] = fmt.Sprintf("https://%s/app/%s/workspaces/%s", host, url.PathEscape(organization), url.PathEscape(ws.Name))
+	}
+
+	return urls
+}
+
+// SetWorkspaceOutputs reads the workspaces from the Terraform state and sets the workspace_ids and workspace_urls outputs
+func SetWorkspaceOutputs(ctx context.Context, tf TerraformCLI, host string, organization string) error {
+	state, err := tf.Show(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to show state: %w", err)
+	}
+
+	return setWorkspaceIDOutputs(StateWorkspaceIDs(state), host, organization)
+}
+
+// setWorkspaceIDOutputs sets the workspace_ids and workspace_urls outputs of the passed workspaces
+func setWorkspaceIDOutputs(ids map[string]WorkspaceOutput, host string, organization string) error {
+	b, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("failed to convert workspace IDs to JSON: %w", err)
+	}
+
+	gha.SetOutput("workspace_ids", string(b))
+
+	b, err = json.Marshal(WorkspaceURLs(ids, host, organization))
+	if err != nil {
+		return fmt.Errorf("failed to convert workspace URLs to JSON: %w", err)
+	}
+
+	gha.SetOutput("workspace_urls", string(b))
+
+	return nil
+}
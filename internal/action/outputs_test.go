@@ -0,0 +1,100 @@
+package action
+
+import (
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPlanOutputs(t *testing.T) {
+	t.Run("count the plan changes", func(t *testing.T) {
+		outputs := NewPlanOutputs(newTestPlan(), newTestMultiWorkspaceList())
+
+		assert.Equal(t, &PlanOutputs{
+			HasChanges:         true,
+			AddCount:           3,
+			ChangeCount:        1,
+			DestroyCount:       2,
+			AffectedWorkspaces: []string{"foo-production", "foo-staging"},
+		}, outputs)
+	})
+
+	t.Run("workspace scoped keys", func(t *testing.T) {
+		outputs := NewPlanOutputs(&tfjson.Plan{
+			ResourceChanges: []*tfjson.ResourceChange{
+				{
+					Mode:   tfjson.ManagedResourceMode,
+					Type:   "tfe_notification_configuration",
+					Name:   "notifications",
+					Index:  "staging/slack",
+					Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionUpdate}},
+				},
+				{
+					Mode:   tfjson.ManagedResourceMode,
+					Type:   "tfe_workspace_variable_set",
+					Name:   "variable_sets",
+					Index:  "production/aws",
+					Change: &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionDelete}},
+				},
+			},
+		}, newTestMultiWorkspaceList())
+
+		assert.Equal(t, []string{"foo-production", "foo-staging"}, outputs.AffectedWorkspaces)
+	})
+
+	t.Run("no plan", func(t *testing.T) {
+		assert.Equal(t, &PlanOutputs{AffectedWorkspaces: []string{}}, NewPlanOutputs(nil, newTestMultiWorkspaceList()))
+	})
+}
+
+func TestStateWorkspaceIDs(t *testing.T) {
+	state := &tfjson.State{
+		Values: &tfjson.StateValues{
+			RootModule: &tfjson.StateModule{
+				Resources: []*tfjson.StateResource{
+					{
+						Mode:            tfjson.ManagedResourceMode,
+						Type:            "tfe_workspace",
+						Name:            "workspace",
+						Index:           "staging",
+						AttributeValues: map[string]interface{}{"id": "ws-abc123", "name": "foo-staging"},
+					},
+					{
+						Mode:            tfjson.ManagedResourceMode,
+						Type:            "tfe_workspace",
+						Name:            "workspace",
+						Index:           "production",
+						AttributeValues: map[string]interface{}{"id": "ws-def456", "name": "foo-production"},
+					},
+					{
+						Mode:            tfjson.DataResourceMode,
+						Type:            "tfe_workspace",
+						Name:            "run_trigger_workspaces",
+						Index:           "source",
+						AttributeValues: map[string]interface{}{"id": "ws-source", "name": "source"},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, map[string]WorkspaceOutput{
+		"staging":    {ID: "ws-abc123", Name: "foo-staging"},
+		"production": {ID: "ws-def456", Name: "foo-production"},
+	}, StateWorkspaceIDs(state))
+
+	assert.Equal(t, map[string]WorkspaceOutput{}, StateWorkspaceIDs(&tfjson.State{}))
+}
+
+func TestWorkspaceURLs(t *testing.T) {
+	urls := WorkspaceURLs(map[string]WorkspaceOutput{
+		"staging":    {ID: "ws-abc123", Name: "foo-staging"},
+		"production": {ID: "ws-def456", Name: "foo-production"},
+	}, "app.terraform.io", "org")
+
+	assert.Equal(t, map[string]string{
+		"staging":    "https://app.terraform.io/app/org/workspaces/foo-staging",
+		"production": "https://app.terraform.io/app/org/workspaces/foo-production",
+	}, urls)
+}
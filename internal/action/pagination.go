@@ -0,0 +1,34 @@
+package action
+
+import (
+	"context"
+
+	tfe "github.com/hashicorp/go-tfe"
+)
+
+var maxPageSize int = 100
+
+// listAll calls the passed list function for every page of results and returns the combined items
+func listAll[T any](ctx context.Context, list func(ctx context.Context, options tfe.ListOptions) ([]T, *tfe.Pagination, error)) ([]T, error) {
+	var items []T
+
+	options := tfe.ListOptions{
+		PageNumber: 1,
+		PageSize:   maxPageSize,
+	}
+
+	for {
+		page, pagination, err := list(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page...)
+
+		if pagination == nil || pagination.NextPage == 0 || pagination.NextPage <= options.PageNumber {
+			return items, nil
+		}
+
+		options.PageNumber = pagination.NextPage
+	}
+}
@@ -0,0 +1,207 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	tfe "github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServerPagesHandler returns a route handler that serves one page of JSON:API resources per requested page number
+func testServerPagesHandler(t *testing.T, pages [][]string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		pageNumber := 1
+
+		if p := r.URL.Query().Get("page[number]"); p != "" {
+			n, err := strconv.Atoi(p)
+			require.NoError(t, err)
+
+			pageNumber = n
+		}
+
+		nextPage := "null"
+		if pageNumber < len(pages) {
+			nextPage = strconv.Itoa(pageNumber + 1)
+		}
+
+		w.WriteHeader(200)
+
+		_, err := fmt.Fprintf(w, `{
+  "data": [%s],
+  "meta": {
+    "pagination": {
+      "current-page": %d,
+      "next-page": %s,
+      "total-pages": %d
+    }
+  }
+}`, strings.Join(pages[pageNumber-1], ","), pageNumber, nextPage, len(pages))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestTeamPages returns the JSON:API representation of the passed team names split into pages
+func newTestTeamPages(pages ...[]string) [][]string {
+	teamPages := make([][]string, len(pages))
+
+	for i, names := range pages {
+		for _, name := range names {
+			teamPages[i] = append(teamPages[i], fmt.Sprintf(`{"id": "team-%[1]s", "type": "teams", "attributes": {"name": %[1]q}}`, name))
+		}
+	}
+
+	return teamPages
+}
+
+func TestListAll(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("request every page", func(t *testing.T) {
+		var requested []tfe.ListOptions
+
+		items, err := listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]int, *tfe.Pagination, error) {
+			requested = append(requested, options)
+
+			next := options.PageNumber + 1
+			if next > 3 {
+				next = 0
+			}
+
+			return []int{options.PageNumber}, &tfe.Pagination{CurrentPage: options.PageNumber, NextPage: next, TotalPages: 3}, nil
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []int{1, 2, 3}, items)
+		assert.Equal(t, []tfe.ListOptions{
+			{PageNumber: 1, PageSize: maxPageSize},
+			{PageNumber: 2, PageSize: maxPageSize},
+			{PageNumber: 3, PageSize: maxPageSize},
+		}, requested)
+	})
+
+	t.Run("stop when no pagination is returned", func(t *testing.T) {
+		items, err := listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]int, *tfe.Pagination, error) {
+			return []int{1}, nil, nil
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []int{1}, items)
+	})
+
+	t.Run("return list errors", func(t *testing.T) {
+		_, err := listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]int, *tfe.Pagination, error) {
+			return nil, nil, fmt.Errorf("list failed")
+		})
+
+		assert.EqualError(t, err, "list failed")
+	})
+}
+
+func TestPaginatedFetchers(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	mux.HandleFunc("/api/v2/organizations/org/teams", testServerPagesHandler(t, newTestTeamPages(
+		[]string{"alpha", "beta"},
+		[]string{"gamma"},
+	)))
+
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123/vars", testServerPagesHandler(t, [][]string{
+		{`{"id": "var-1", "type": "vars", "attributes": {"key": "foo", "category": "env"}}`},
+		{`{"id": "var-2", "type": "vars", "attributes": {"key": "bar", "category": "env"}}`},
+	}))
+
+	mux.HandleFunc("/api/v2/team-workspaces", testServerPagesHandler(t, [][]string{
+		{`{"id": "tws-1", "type": "team-workspaces", "attributes": {"access": "read"}, "relationships": {"team": {"data": {"id": "team-alpha", "type": "teams"}}}}`},
+		{`{"id": "tws-2", "type": "team-workspaces", "attributes": {"access": "write"}, "relationships": {"team": {"data": {"id": "team-gamma", "type": "teams"}}}}`},
+	}))
+
+	mux.HandleFunc("/api/v2/workspaces/ws-abc123/run-triggers", testServerPagesHandler(t, [][]string{
+		{`{"id": "rt-1", "type": "run-triggers", "relationships": {"sourceable": {"data": {"id": "ws-1", "type": "workspaces"}}}}`},
+		{`{"id": "rt-2", "type": "run-triggers", "relationships": {"sourceable": {"data": {"id": "ws-2", "type": "workspaces"}}}}`},
+	}))
+
+	mux.HandleFunc("/api/v2/organizations/org/oauth-clients", testServerPagesHandler(t, [][]string{
+		{`{"id": "oc-1", "type": "oauth-clients", "attributes": {"service-provider": "gitlab_hosted"}}`},
+		{`{"id": "oc-2", "type": "oauth-clients", "attributes": {"service-provider": "github"}, "relationships": {"oauth-tokens": {"data": [{"id": "ot-2", "type": "oauth-tokens"}]}}}`},
+	}))
+
+	client := newTestTFClient(t, server.URL)
+	workspace := newTestWorkspace()
+
+	t.Run("fetch teams from every page", func(t *testing.T) {
+		teams, err := FetchRelatedTeams(ctx, client, "org")
+		require.NoError(t, err)
+
+		assert.Len(t, teams, 3)
+	})
+
+	t.Run("find a team on a later page", func(t *testing.T) {
+		team, err := GetTeam(ctx, client, "gamma", "org")
+		require.NoError(t, err)
+
+		assert.Equal(t, "team-gamma", team.ID)
+	})
+
+	t.Run("fetch variables from every page", func(t *testing.T) {
+		variables, err := FetchRelatedVariables(ctx, client, workspace)
+		require.NoError(t, err)
+
+		assert.Len(t, variables, 2)
+		assert.Equal(t, "bar", variables[1].Key)
+	})
+
+	t.Run("fetch team access from every page and match teams from every page", func(t *testing.T) {
+		teams, err := FetchRelatedTeams(ctx, client, "org")
+		require.NoError(t, err)
+
+		access, err := FetchRelatedTeamAccess(ctx, client, workspace)
+		require.NoError(t, err)
+
+		items, err := ToTeamAccessItems(access, teams, workspace)
+		require.NoError(t, err)
+
+		assert.Equal(t, []TeamAccessItem{
+			{Access: "read", TeamName: "alpha", Workspace: workspace},
+			{Access: "write", TeamName: "gamma", Workspace: workspace},
+		}, items)
+	})
+
+	t.Run("fetch run triggers from every page", func(t *testing.T) {
+		triggers, err := FetchInboundRunTriggers(ctx, client, *workspace.ID)
+		require.NoError(t, err)
+
+		assert.Len(t, triggers, 2)
+		assert.Equal(t, "ws-2", triggers[1].Sourceable.ID)
+	})
+
+	t.Run("find a VCS client on a later page", func(t *testing.T) {
+		tokenID, err := GetVCSTokenIDByClientType(ctx, client, "org", "github")
+		require.NoError(t, err)
+
+		assert.Equal(t, "ot-2", tokenID)
+	})
+}
+
+func TestToTeamAccessItemsUnknownTeam(t *testing.T) {
+	_, err := ToTeamAccessItems([]*tfe.TeamAccess{
+		{ID: "tws-1", Team: &tfe.Team{ID: "team-missing"}},
+	}, []*tfe.Team{}, newTestWorkspace())
+
+	assert.EqualError(t, err, "team \"team-missing\" with access to workspace \"ws\" not found in the organization")
+}
@@ -0,0 +1,190 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+// ModePlan plans against the backend state and saves the plan, configuration and lock file to the plan artifact directory
+const ModePlan = "plan"
+
+// ModeApply applies exactly the plan saved to the plan artifact directory by a previous plan run
+const ModeApply = "apply"
+
+const (
+	planArtifactPlanFile     = "tfplan"
+	planArtifactModuleFile   = "main.tf.json"
+	planArtifactLockFile     = ".terraform.lock.hcl"
+	planArtifactMetadataFile = "metadata.json"
+)
+
+// PlanArtifactMetadata identifies the run and the state a saved plan was computed against
+type PlanArtifactMetadata struct {
+	Name             string        `json:"name"`
+	TerraformVersion string        `json:"terraform_version"`
+	State            StateSnapshot `json:"state"`
+}
+
+// copyFile copies the file at src to dst, creating or truncating dst
+func copyFile(src string, dst string) error {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(dst, b, 0644)
+}
+
+// SavePlanArtifact copies the plan, configuration and lock file from the working directory to the artifact directory, alongside the plan metadata
+func SavePlanArtifact(workDir string, planPath string, artifactDir string, metadata *PlanArtifactMetadata) error {
+	if err := os.MkdirAll(artifactDir, 0755); err != nil {
+		return fmt.Errorf("failed to create plan artifact directory: %w", err)
+	}
+
+	files := map[string]string{
+		planPath:               planArtifactPlanFile,
+		planArtifactModuleFile: planArtifactModuleFile,
+		planArtifactLockFile:   planArtifactLockFile,
+	}
+
+	for src, dst := range files {
+		if err := copyFile(path.Join(workDir, src), path.Join(artifactDir, dst)); err != nil {
+			return fmt.Errorf("failed to save %s to the plan artifact: %w", dst, err)
+		}
+	}
+
+	b, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to convert plan metadata to JSON: %w", err)
+	}
+
+	if err := ioutil.WriteFile(path.Join(artifactDir, planArtifactMetadataFile), b, 0644); err != nil {
+		return fmt.Errorf("failed to save plan metadata: %w", err)
+	}
+
+	return nil
+}
+
+// LoadPlanArtifact copies the configuration, lock file and plan from the artifact directory to the working directory and returns the plan metadata
+func LoadPlanArtifact(artifactDir string, workDir string) (*PlanArtifactMetadata, error) {
+	b, err := ioutil.ReadFile(path.Join(artifactDir, planArtifactMetadataFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan metadata: %w", err)
+	}
+
+	metadata := &PlanArtifactMetadata{}
+
+	if err := json.Unmarshal(b, metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode plan metadata: %w", err)
+	}
+
+	for _, f := range []string{planArtifactPlanFile, planArtifactModuleFile, planArtifactLockFile} {
+		if err := copyFile(path.Join(artifactDir, f), path.Join(workDir, f)); err != nil {
+			return nil, fmt.Errorf("failed to load %s from the plan artifact: %w", f, err)
+		}
+	}
+
+	return metadata, nil
+}
+
+// Check returns an error if the plan was saved by another run, another Terraform version, or if the state has moved on since the plan
+func (m *PlanArtifactMetadata) Check(name string, terraformVersion string, state *StateSnapshot) error {
+	if m.Name != name {
+		return fmt.Errorf("refusing to apply a plan saved for %q to %q", m.Name, name)
+	}
+
+	if m.TerraformVersion != terraformVersion {
+		return fmt.Errorf("refusing to apply a plan saved with Terraform %s using Terraform %s", m.TerraformVersion, terraformVersion)
+	}
+
+	if m.State.Lineage != state.Lineage {
+		return fmt.Errorf("refusing to apply a stale plan, the state lineage has changed from %q to %q since the plan", m.State.Lineage, state.Lineage)
+	}
+
+	if m.State.Serial != state.Serial {
+		return fmt.Errorf("refusing to apply a stale plan, the state has moved on from serial %d to %d since the plan", m.State.Serial, state.Serial)
+	}
+
+	return nil
+}
+
+// RunApply applies the plan saved to the plan artifact directory, after checking that the state has not moved on and that the plan satisfies the destroy protection policy
+func RunApply(ctx context.Context, config *Config, client *tfe.Client) error {
+	workDir, err := ioutil.TempDir("", config.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create working directory: %w", err)
+	}
+
+	defer os.RemoveAll(workDir)
+
+	metadata, err := LoadPlanArtifact(config.PlanArtifact, workDir)
+	if err != nil {
+		return err
+	}
+
+	tf, err := NewTerraformExec(ctx, workDir, config.TerraformInstallOptions())
+	if err != nil {
+		return fmt.Errorf("failed to create tfexec instance: %w", err)
+	}
+
+	if err := OutputDirectory(config.OutputDirectory).SetLogPath(tf, config.TerraformLogLevel); err != nil {
+		return fmt.Errorf("failed to set the Terraform log path: %w", err)
+	}
+
+	if err := writeTerraformCLIConfigFile(tf, NewTerraformCLIConfig(config, workDir), workDir); err != nil {
+		return fmt.Errorf("failed to write the Terraform CLI configuration file: %w", err)
+	}
+
+	if err := tf.Init(ctx); err != nil {
+		return fmt.Errorf("failed to initialize the Terraform configuration: %w", err)
+	}
+
+	_, snapshot, err := ReadStateSnapshot(ctx, tf)
+	if err != nil {
+		return fmt.Errorf("failed to read the state from the configured backend: %w", err)
+	}
+
+	tfVersion, err := TerraformVersion(ctx, tf)
+	if err != nil {
+		return fmt.Errorf("failed to read the Terraform version: %w", err)
+	}
+
+	if err := metadata.Check(config.Name, tfVersion, snapshot); err != nil {
+		return err
+	}
+
+	plan, err := tf.ShowPlanFile(ctx, planArtifactPlanFile)
+	if err != nil {
+		return fmt.Errorf("failed to create plan struct: %w", err)
+	}
+
+	destroyProtection, err := NewDestroyProtection(config.DestroyProtection, config.AllowWorkspaceDeletion)
+	if err != nil {
+		return fmt.Errorf("failed to parse destroy protection: %w", err)
+	}
+
+	if err := destroyProtection.Check(plan); err != nil {
+		return err
+	}
+
+	if err := CheckWorkspaceReplacements(ctx, client, plan, config.Organization); err != nil {
+		return err
+	}
+
+	gha.Infof("Applying the plan computed against state serial %d...\n", metadata.State.Serial)
+
+	if err = tf.Apply(ctx, tfexec.DirOrPlan(planArtifactPlanFile)); err != nil {
+		return fmt.Errorf("failed to apply: %w", err)
+	}
+
+	gha.Infof("Success\n")
+
+	return SetWorkspaceOutputs(ctx, tf, config.Host, config.Organization)
+}
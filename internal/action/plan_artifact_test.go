@@ -0,0 +1,87 @@
+package action
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanArtifact(t *testing.T) {
+	workDir := t.TempDir()
+
+	for name, contents := range map[string]string{
+		"plan.txt":            "plan",
+		"main.tf.json":        "{}",
+		".terraform.lock.hcl": "# lock",
+	} {
+		require.NoError(t, os.WriteFile(path.Join(workDir, name), []byte(contents), 0644))
+	}
+
+	artifactDir := path.Join(t.TempDir(), "tfplan")
+
+	metadata := &PlanArtifactMetadata{
+		Name:             "foo",
+		TerraformVersion: "1.1.2",
+		State:            StateSnapshot{Serial: 7, Lineage: "abc-123"},
+	}
+
+	require.NoError(t, SavePlanArtifact(workDir, "plan.txt", artifactDir, metadata))
+
+	applyDir := t.TempDir()
+
+	loaded, err := LoadPlanArtifact(artifactDir, applyDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, metadata, loaded)
+
+	for name, contents := range map[string]string{
+		"tfplan":              "plan",
+		"main.tf.json":        "{}",
+		".terraform.lock.hcl": "# lock",
+	} {
+		b, err := os.ReadFile(path.Join(applyDir, name))
+		require.NoError(t, err)
+
+		assert.Equal(t, contents, string(b))
+	}
+
+	t.Run("missing artifact", func(t *testing.T) {
+		_, err := LoadPlanArtifact(path.Join(t.TempDir(), "missing"), t.TempDir())
+		assert.ErrorContains(t, err, "failed to read plan metadata")
+	})
+}
+
+func TestPlanArtifactMetadataCheck(t *testing.T) {
+	metadata := &PlanArtifactMetadata{
+		Name:             "foo",
+		TerraformVersion: "1.1.2",
+		State:            StateSnapshot{Serial: 7, Lineage: "abc-123"},
+	}
+
+	for _, testCase := range []struct {
+		Description      string
+		Name             string
+		TerraformVersion string
+		State            StateSnapshot
+		Error            string
+	}{
+		{"current plan", "foo", "1.1.2", StateSnapshot{Serial: 7, Lineage: "abc-123"}, ""},
+		{"other name", "bar", "1.1.2", StateSnapshot{Serial: 7, Lineage: "abc-123"}, `refusing to apply a plan saved for "foo" to "bar"`},
+		{"other Terraform version", "foo", "1.2.0", StateSnapshot{Serial: 7, Lineage: "abc-123"}, "refusing to apply a plan saved with Terraform 1.1.2 using Terraform 1.2.0"},
+		{"new serial", "foo", "1.1.2", StateSnapshot{Serial: 8, Lineage: "abc-123"}, "refusing to apply a stale plan, the state has moved on from serial 7 to 8 since the plan"},
+		{"new lineage", "foo", "1.1.2", StateSnapshot{Serial: 7, Lineage: "def-456"}, `refusing to apply a stale plan, the state lineage has changed from "abc-123" to "def-456" since the plan`},
+	} {
+		t.Run(testCase.Description, func(t *testing.T) {
+			err := metadata.Check(testCase.Name, testCase.TerraformVersion, &testCase.State)
+
+			if testCase.Error == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, testCase.Error)
+			}
+		})
+	}
+}
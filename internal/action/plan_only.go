@@ -0,0 +1,65 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+)
+
+// ModePlanOnly plans against a local copy of the state read from the backend, without locking or writing the backend state
+const ModePlanOnly = "plan-only"
+
+// TerraformStatePuller is the subset of the Terraform CLI used to read the backend state
+type TerraformStatePuller interface {
+	StatePull(context.Context, ...tfexec.StatePullOption) (string, error)
+}
+
+// StateSnapshot identifies the state a plan was computed against
+type StateSnapshot struct {
+	Serial  int64  `json:"serial"`
+	Lineage string `json:"lineage"`
+}
+
+// ReadStateSnapshot reads the state from the configured backend without locking it, returning the raw state and the snapshot identifying it.
+// An empty state and snapshot are returned if the backend has no state yet.
+func ReadStateSnapshot(ctx context.Context, tf TerraformStatePuller) (string, *StateSnapshot, error) {
+	state, err := tf.StatePull(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	snapshot := &StateSnapshot{}
+
+	if state == "" {
+		return state, snapshot, nil
+	}
+
+	if err := json.Unmarshal([]byte(state), snapshot); err != nil {
+		return "", nil, fmt.Errorf("failed to decode the backend state: %w", err)
+	}
+
+	return state, snapshot, nil
+}
+
+// PullState reads the state from the configured backend and writes it to the local state file of the working directory.
+// Reading the state does not lock it, so only read access to the backend is needed.
+func PullState(ctx context.Context, tf TerraformStatePuller, workDir string) (*StateSnapshot, error) {
+	state, snapshot, err := ReadStateSnapshot(ctx, tf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the state from the configured backend, %q mode needs read access to the backend state: %w", ModePlanOnly, err)
+	}
+
+	if state == "" {
+		return snapshot, nil
+	}
+
+	if err := ioutil.WriteFile(path.Join(workDir, "terraform.tfstate"), []byte(state), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write the local state file: %w", err)
+	}
+
+	return snapshot, nil
+}
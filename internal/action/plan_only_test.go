@@ -0,0 +1,57 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStatePuller struct {
+	State string
+	Err   error
+}
+
+func (tf testStatePuller) StatePull(ctx context.Context, opts ...tfexec.StatePullOption) (string, error) {
+	return tf.State, tf.Err
+}
+
+func TestPullState(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("copy the backend state", func(t *testing.T) {
+		workDir := t.TempDir()
+		state := `{"version": 4, "serial": 42, "lineage": "abc-123", "resources": []}`
+
+		snapshot, err := PullState(ctx, testStatePuller{State: state}, workDir)
+		require.NoError(t, err)
+
+		assert.Equal(t, &StateSnapshot{Serial: 42, Lineage: "abc-123"}, snapshot)
+
+		b, err := os.ReadFile(path.Join(workDir, "terraform.tfstate"))
+		require.NoError(t, err)
+
+		assert.Equal(t, state, string(b))
+	})
+
+	t.Run("no backend state", func(t *testing.T) {
+		workDir := t.TempDir()
+
+		snapshot, err := PullState(ctx, testStatePuller{}, workDir)
+		require.NoError(t, err)
+
+		assert.Equal(t, &StateSnapshot{}, snapshot)
+		assert.NoFileExists(t, path.Join(workDir, "terraform.tfstate"))
+	})
+
+	t.Run("unreadable backend state", func(t *testing.T) {
+		_, err := PullState(ctx, testStatePuller{Err: errors.New("unauthorized")}, t.TempDir())
+
+		assert.EqualError(t, err, "failed to read the state from the configured backend, \"plan-only\" mode needs read access to the backend state: unauthorized")
+	})
+}
@@ -0,0 +1,207 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-tfe"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// TerraformStateCLI is the subset of the Terraform CLI used to move resources in state
+type TerraformStateCLI interface {
+	Show(context.Context, ...tfexec.ShowOption) (*tfjson.State, error)
+	StateMv(context.Context, string, string, ...tfexec.StateMvCmdOption) error
+}
+
+// PreviousWorkspaces returns the workspaces as they were named before the passed renames, in the same order as ParseWorkspaces returns the current workspaces.
+// previousName is the previous generic workspace name and renames maps previous workspace keys to current ones.
+func PreviousWorkspaces(workspaceNames []string, previousName string, renames map[string]string) ([]*Workspace, error) {
+	current := map[string]bool{}
+	for _, wsn := range workspaceNames {
+		current[wsn] = true
+	}
+
+	previousKeys := map[string]string{}
+
+	for from, to := range renames {
+		if !current[to] {
+			return nil, fmt.Errorf("workspace %q renamed to unknown workspace %q", from, to)
+		}
+
+		if current[from] {
+			return nil, fmt.Errorf("workspace %q is renamed to %q but is still configured", from, to)
+		}
+
+		if other, ok := previousKeys[to]; ok {
+			return nil, fmt.Errorf("workspaces %q and %q are both renamed to %q", other, from, to)
+		}
+
+		previousKeys[to] = from
+	}
+
+	var names []string
+
+	for _, wsn := range workspaceNames {
+		if from, ok := previousKeys[wsn]; ok {
+			names = append(names, from)
+		} else {
+			names = append(names, wsn)
+		}
+	}
+
+	return ParseWorkspaces(names, previousName)
+}
+
+// SetPreviousWorkspaceIDs looks up the workspaces not found by their current name under their previous name, so they are renamed rather than recreated
+func SetPreviousWorkspaceIDs(ctx context.Context, client *tfe.Client, workspaces []*Workspace, previous []*Workspace, organization string) error {
+	for i, workspace := range workspaces {
+		if workspace.ID != nil || previous[i].Name == workspace.Name {
+			continue
+		}
+
+		ws, err := client.Workspaces.Read(ctx, organization, previous[i].Name)
+		if err != nil {
+			if !errors.Is(err, tfe.ErrResourceNotFound) {
+				return err
+			}
+
+			continue
+		}
+
+		gha.Infof("Workspace %q found under its previous name %q, it will be renamed\n", workspace.Name, previous[i].Name)
+
+		workspace.ID = &ws.ID
+	}
+
+	return nil
+}
+
+// resourceAddress returns the address of a root module resource with the passed for_each key, or name if the resource has no for_each key
+func resourceAddress(r *tfjson.StateResource, key string) string {
+	if _, ok := r.Index.(string); ok {
+		return fmt.Sprintf("%s.%s[%q]", r.Type, r.Name, key)
+	}
+
+	return fmt.Sprintf("%s.%s", r.Type, key)
+}
+
+// RenamedWorkspaceMoves returns the current address of every resource in state belonging to a workspace whose key was renamed,
+// mapped to the address keyed by the current workspace key
+func RenamedWorkspaceMoves(ctx context.Context, tf TerraformStateCLI, workspaces []*Workspace, previous []*Workspace) (map[string]string, error) {
+	renamed := map[*Workspace]*Workspace{}
+
+	for i, ws := range workspaces {
+		if previous[i].Workspace != ws.Workspace {
+			renamed[previous[i]] = ws
+		}
+	}
+
+	moves := map[string]string{}
+
+	if len(renamed) == 0 {
+		return moves, nil
+	}
+
+	state, err := tf.Show(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to show state: %w", err)
+	}
+
+	if state.Values == nil || state.Values.RootModule == nil {
+		return moves, nil
+	}
+
+	for _, r := range state.Values.RootModule.Resources {
+		if r.Mode != tfjson.ManagedResourceMode {
+			continue
+		}
+
+		key := r.Name
+		if index, ok := r.Index.(string); ok {
+			key = index
+		}
+
+		owner := resourceWorkspace(r.Type, r.Name, key, previous)
+
+		ws, ok := renamed[owner]
+		if !ok {
+			continue
+		}
+
+		if r.Type == "tfe_workspace" {
+			moves[r.Address] = resourceAddress(r, ws.Workspace)
+			continue
+		}
+
+		moves[r.Address] = resourceAddress(r, workspaceResourceKey(r.Type, ws, workspaceResourceName(r.Type, key, owner)))
+	}
+
+	return moves, nil
+}
+
+// MoveRenamedWorkspaces moves the state of every resource belonging to a workspace whose key was renamed to the address keyed by the current workspace key
+func MoveRenamedWorkspaces(ctx context.Context, tf TerraformStateCLI, workspaces []*Workspace, previous []*Workspace) error {
+	moves, err := RenamedWorkspaceMoves(ctx, tf, workspaces, previous)
+	if err != nil {
+		return err
+	}
+
+	sources := []string{}
+	for source := range moves {
+		sources = append(sources, source)
+	}
+
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		gha.Infof("Moving %s to %s\n", source, moves[source])
+
+		if err := tf.StateMv(ctx, source, moves[source]); err != nil {
+			return fmt.Errorf("failed to move %s to %s: %w", source, moves[source], err)
+		}
+	}
+
+	return nil
+}
+
+// CheckWorkspaceReplacements returns an error if the plan replaces a workspace holding state versions, as the replacement would orphan its state
+func CheckWorkspaceReplacements(ctx context.Context, client *tfe.Client, plan *tfjson.Plan, organization string) error {
+	addresses := []string{}
+
+	for _, rc := range plan.ResourceChanges {
+		if rc.Mode != tfjson.ManagedResourceMode || rc.Type != "tfe_workspace" || rc.Change == nil || !rc.Change.Actions.Replace() {
+			continue
+		}
+
+		before, _ := rc.Change.Before.(map[string]interface{})
+		name, _ := before["name"].(string)
+
+		if name == "" {
+			continue
+		}
+
+		versions, err := client.StateVersions.List(ctx, &tfe.StateVersionListOptions{
+			ListOptions:  tfe.ListOptions{PageSize: 1},
+			Organization: organization,
+			Workspace:    name,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list state versions of workspace %q: %w", name, err)
+		}
+
+		if len(versions.Items) > 0 {
+			addresses = append(addresses, rc.Address)
+		}
+	}
+
+	if len(addresses) > 0 {
+		return fmt.Errorf("refusing to replace workspaces holding state versions, replacing them would orphan their state: %s. Use previous_name or workspace_renames to rename workspaces in place", strings.Join(addresses, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package action
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviousWorkspaces(t *testing.T) {
+	t.Run("rename workspaces", func(t *testing.T) {
+		previous, err := PreviousWorkspaces([]string{"staging", "prod"}, "bar", map[string]string{"production": "prod"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []*Workspace{
+			{Name: "bar-staging", Workspace: "staging"},
+			{Name: "bar-production", Workspace: "production"},
+		}, previous)
+	})
+
+	t.Run("rename the default workspace", func(t *testing.T) {
+		previous, err := PreviousWorkspaces(nil, "bar", nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []*Workspace{{Name: "bar", Workspace: "default"}}, previous)
+	})
+
+	for _, testCase := range []struct {
+		Description string
+		Renames     map[string]string
+		Error       string
+	}{
+		{"unknown workspace", map[string]string{"production": "prd"}, `workspace "production" renamed to unknown workspace "prd"`},
+		{"configured workspace", map[string]string{"staging": "prod"}, `workspace "staging" is renamed to "prod" but is still configured`},
+	} {
+		t.Run(testCase.Description, func(t *testing.T) {
+			_, err := PreviousWorkspaces([]string{"staging", "prod"}, "foo", testCase.Renames)
+			assert.EqualError(t, err, testCase.Error)
+		})
+	}
+}
+
+func TestSetPreviousWorkspaceIDs(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	mux.HandleFunc("/api/v2/organizations/org/workspaces/bar-production", testServerResHandler(t, 200, `{"data": {"id": "ws-def456", "type": "workspaces", "attributes": {"name": "bar-production"}}}`))
+	mux.HandleFunc("/api/v2/organizations/org/workspaces/bar-staging", testServerResHandler(t, 404, `{"errors": [{"status": "404"}]}`))
+
+	client := newTestTFClient(t, server.URL)
+
+	workspaces := []*Workspace{
+		{Name: "foo-staging", Workspace: "staging"},
+		{Name: "foo-prod", Workspace: "prod"},
+		{Name: "foo-dev", Workspace: "dev", ID: strPtr("ws-dev")},
+	}
+
+	previous := []*Workspace{
+		{Name: "bar-staging", Workspace: "staging"},
+		{Name: "bar-production", Workspace: "production"},
+		{Name: "bar-dev", Workspace: "dev"},
+	}
+
+	require.NoError(t, SetPreviousWorkspaceIDs(ctx, client, workspaces, previous, "org"))
+
+	assert.Nil(t, workspaces[0].ID)
+	assert.Equal(t, strPtr("ws-def456"), workspaces[1].ID)
+	assert.Equal(t, strPtr("ws-dev"), workspaces[2].ID)
+}
+
+func TestMoveRenamedWorkspaces(t *testing.T) {
+	ctx := context.Background()
+
+	resource := func(resourceType string, name string, index interface{}) *tfjson.StateResource {
+		address := resourceType + "." + name
+		if key, ok := index.(string); ok {
+			address += `["` + key + `"]`
+		}
+
+		return &tfjson.StateResource{Address: address, Mode: tfjson.ManagedResourceMode, Type: resourceType, Name: name, Index: index}
+	}
+
+	tf := &TestTFExec{
+		State: &tfjson.State{
+			Values: &tfjson.StateValues{
+				RootModule: &tfjson.StateModule{
+					Resources: []*tfjson.StateResource{
+						resource("tfe_workspace", "workspace", "production"),
+						resource("tfe_workspace", "workspace", "production-eu"),
+						resource("tfe_workspace", "workspace", "staging"),
+						resource("tfe_variable", "production-region", nil),
+						resource("tfe_variable", "production-eu-region", nil),
+						resource("tfe_team_access", "teams", "production-team-abc123"),
+						resource("tfe_variable", "variable_set_variables", "production-foo"),
+						resource("tfe_notification_configuration", "notifications", "production/slack"),
+						resource("tfe_workspace_variable_set", "variable_sets", "production/aws"),
+						resource("tfe_workspace_variable_set", "variable_sets", "production-eu/aws"),
+					},
+				},
+			},
+		},
+	}
+
+	workspaces := []*Workspace{
+		{Name: "foo-staging", Workspace: "staging"},
+		{Name: "foo-prod", Workspace: "prod"},
+		{Name: "foo-production-eu", Workspace: "production-eu"},
+	}
+
+	previous := []*Workspace{
+		{Name: "foo-staging", Workspace: "staging"},
+		{Name: "foo-production", Workspace: "production"},
+		{Name: "foo-production-eu", Workspace: "production-eu"},
+	}
+
+	moves, err := RenamedWorkspaceMoves(ctx, tf, workspaces, previous)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		`tfe_notification_configuration.notifications["production/slack"]`: `tfe_notification_configuration.notifications["prod/slack"]`,
+		`tfe_team_access.teams["production-team-abc123"]`:                  `tfe_team_access.teams["prod-team-abc123"]`,
+		"tfe_variable.production-region":                                   "tfe_variable.prod-region",
+		`tfe_workspace.workspace["production"]`:                            `tfe_workspace.workspace["prod"]`,
+		`tfe_workspace_variable_set.variable_sets["production/aws"]`:       `tfe_workspace_variable_set.variable_sets["prod/aws"]`,
+	}, moves)
+	assert.Empty(t, tf.StateMvArgs, "computing the moves doesn't change the state")
+
+	require.NoError(t, MoveRenamedWorkspaces(ctx, tf, workspaces, previous))
+
+	assert.Equal(t, [][2]string{
+		{`tfe_notification_configuration.notifications["production/slack"]`, `tfe_notification_configuration.notifications["prod/slack"]`},
+		{`tfe_team_access.teams["production-team-abc123"]`, `tfe_team_access.teams["prod-team-abc123"]`},
+		{"tfe_variable.production-region", "tfe_variable.prod-region"},
+		{`tfe_workspace.workspace["production"]`, `tfe_workspace.workspace["prod"]`},
+		{`tfe_workspace_variable_set.variable_sets["production/aws"]`, `tfe_workspace_variable_set.variable_sets["prod/aws"]`},
+	}, tf.StateMvArgs)
+}
+
+func TestCheckWorkspaceReplacements(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	mux.HandleFunc("/api/v2/state-versions", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("filter[workspace][name]") == "foo-staging" {
+			testServerResHandler(t, 200, `{"data": [{"id": "sv-abc123", "type": "state-versions"}]}`)(w, r)
+			return
+		}
+
+		testServerResHandler(t, 200, `{"data": []}`)(w, r)
+	})
+
+	client := newTestTFClient(t, server.URL)
+
+	replace := func(key string, name string) *tfjson.ResourceChange {
+		return &tfjson.ResourceChange{
+			Address: `tfe_workspace.workspace["` + key + `"]`,
+			Mode:    tfjson.ManagedResourceMode,
+			Type:    "tfe_workspace",
+			Name:    "workspace",
+			Index:   key,
+			Change: &tfjson.Change{
+				Actions: tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate},
+				Before:  map[string]interface{}{"name": name},
+			},
+		}
+	}
+
+	t.Run("refuse to replace workspaces with state", func(t *testing.T) {
+		err := CheckWorkspaceReplacements(ctx, client, &tfjson.Plan{
+			ResourceChanges: []*tfjson.ResourceChange{replace("staging", "foo-staging"), replace("production", "foo-production")},
+		}, "org")
+
+		assert.EqualError(t, err, `refusing to replace workspaces holding state versions, replacing them would orphan their state: tfe_workspace.workspace["staging"]. Use previous_name or workspace_renames to rename workspaces in place`)
+	})
+
+	t.Run("allow replacing workspaces without state", func(t *testing.T) {
+		err := CheckWorkspaceReplacements(ctx, client, &tfjson.Plan{
+			ResourceChanges: []*tfjson.ResourceChange{replace("production", "foo-production")},
+		}, "org")
+
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,75 @@
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/go-version"
+)
+
+// ModeRender renders the Terraform configuration without contacting Terraform Cloud or running Terraform
+const ModeRender = "render"
+
+// oauthClientLookupVersion is the earliest tfe provider version able to look up an OAuth client by organization and service provider
+var oauthClientLookupVersion = version.Must(version.NewVersion("0.38.0"))
+
+// checkOAuthClientLookup returns an error if the VCS OAuth client is left to a tfe provider version too old to look it up.
+// Version constraints are left to Terraform.
+func checkOAuthClientLookup(config *Config) error {
+	if config.VCSType == "" || config.VCSTokenID != "" || config.TFEProviderVersion == "" {
+		return nil
+	}
+
+	v, err := version.NewVersion(config.TFEProviderVersion)
+	if err != nil {
+		return nil
+	}
+
+	if v.LessThan(oauthClientLookupVersion) {
+		return fmt.Errorf("vcs_type requires tfe_provider_version %s or later to look up the VCS OAuth client in render mode, got %s. Raise tfe_provider_version or pass vcs_token_id instead", oauthClientLookupVersion, v)
+	}
+
+	return nil
+}
+
+// RunRender renders the Terraform configuration from the action config alone, lookups that need Terraform Cloud are left to Terraform data sources.
+// The configuration is set on the terraform_config output and written to the output directory, if one is set.
+func RunRender(ctx context.Context, config *Config) error {
+	if err := checkOAuthClientLookup(config); err != nil {
+		return err
+	}
+
+	workspaces, err := ParseWorkspaces(config.Workspaces, config.Name)
+	if err != nil {
+		return fmt.Errorf("failed to parse workspaces: %w", err)
+	}
+
+	wsConfig, err := NewRunOptions(config, workspaces)
+	if err != nil {
+		return err
+	}
+
+	module, err := NewWorkspaceConfig(ctx, nil, workspaces, wsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create new workspace configuration: %w", err)
+	}
+
+	if err := OutputDirectory(config.OutputDirectory).SaveModule("main.tf.json", module); err != nil {
+		return err
+	}
+
+	redacted, err := redactModule(module)
+	if err != nil {
+		return fmt.Errorf("failed to redact the Terraform configuration: %w", err)
+	}
+
+	b, err := json.MarshalIndent(redacted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to convert the Terraform configuration to JSON: %w", err)
+	}
+
+	gha.Infof("%s\n", b)
+
+	return SetRenderedConfigOutput(module)
+}
@@ -0,0 +1,92 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/sethvargo/go-githubactions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRender(t *testing.T) {
+	ctx := context.Background()
+
+	dir := t.TempDir()
+
+	err := RunRender(ctx, &Config{
+		Name:            "foo",
+		Organization:    "org",
+		Workspaces:      []string{"staging"},
+		VCSType:         "github",
+		VCSRepo:         "org/repo",
+		OutputDirectory: dir,
+		Variables:       VariablesInput{{Key: "region", Value: "us-east-1", Category: "env"}},
+	})
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(path.Join(dir, "main.tf.json"))
+	require.NoError(t, err)
+
+	var module map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &module))
+
+	assert.Equal(t, map[string]interface{}{
+		"tfe_oauth_client": map[string]interface{}{
+			"vcs": map[string]interface{}{"organization": "org", "service_provider": "github"},
+		},
+	}, module["data"])
+
+	resources := module["resource"].(map[string]interface{})
+
+	assert.Contains(t, resources, "tfe_workspace")
+	assert.Contains(t, resources["tfe_variable"], "staging-region")
+
+	t.Run("refuse to look up the VCS client with an old provider", func(t *testing.T) {
+		err := RunRender(ctx, &Config{
+			Name:               "foo",
+			Organization:       "org",
+			VCSType:            "github",
+			VCSRepo:            "org/repo",
+			TFEProviderVersion: "0.30.2",
+			OutputDirectory:    t.TempDir(),
+		})
+
+		assert.EqualError(t, err, "vcs_type requires tfe_provider_version 0.38.0 or later to look up the VCS OAuth client in render mode, got 0.30.2. Raise tfe_provider_version or pass vcs_token_id instead")
+	})
+
+	t.Run("allow version constraints and VCS token IDs", func(t *testing.T) {
+		for _, config := range []*Config{
+			{Name: "foo", Organization: "org", VCSType: "github", VCSRepo: "org/repo", TFEProviderVersion: "~> 0.40"},
+			{Name: "foo", Organization: "org", VCSType: "github", VCSRepo: "org/repo", TFEProviderVersion: "0.38.0"},
+			{Name: "foo", Organization: "org", VCSTokenID: "ot-abc123", VCSRepo: "org/repo", TFEProviderVersion: "0.30.2"},
+		} {
+			assert.NoError(t, RunRender(ctx, config))
+		}
+	})
+
+	t.Run("redact sensitive values from the logs", func(t *testing.T) {
+		var out bytes.Buffer
+
+		SetActions(githubactions.New(githubactions.WithWriter(&out)))
+		t.Cleanup(func() {
+			SetActions(githubactions.New())
+		})
+
+		err := RunRender(ctx, &Config{
+			Name:            "foo",
+			Organization:    "org",
+			Workspaces:      []string{"staging"},
+			OutputDirectory: t.TempDir(),
+			Variables:       VariablesInput{{Key: "token", Value: "hunter2", Category: "env", Sensitive: true}},
+		})
+		require.NoError(t, err)
+
+		assert.Contains(t, out.String(), sensitiveValuePlaceholder)
+		assert.NotContains(t, out.String(), `"hunter2"`)
+	})
+}
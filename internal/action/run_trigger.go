@@ -18,6 +18,7 @@ type RunTriggerInputs []RunTriggerInput
 
 type RunTrigger struct {
 	SourceID     string
+	SourceName   string
 	Workspace    *Workspace
 	WorkspaceRef map[string]tfeprovider.DataWorkspace
 }
@@ -36,6 +37,8 @@ func (rt *RunTriggerInput) ToRunTrigger(target *Workspace, workspaces []*Workspa
 	if rt.SourceID != "" {
 		trigger.SourceID = rt.SourceID
 	} else if rt.SourceName != "" {
+		trigger.SourceName = rt.SourceName
+
 		for _, ws := range workspaces {
 			if ws.Name == rt.SourceName {
 				trigger.SourceID = fmt.Sprintf("${tfe_workspace.workspace[%q].id}", ws.Workspace)
@@ -140,17 +143,17 @@ func AppendRunTriggers(module *tfconfig.Module, triggers RunTriggers) {
 
 // FetchInboundRunTriggers takes a workspace and returns related tfe.RunTrigger objects
 func FetchInboundRunTriggers(ctx context.Context, client *tfe.Client, workspaceID string) ([]*tfe.RunTrigger, error) {
-	rts, err := client.RunTriggers.List(ctx, workspaceID, tfe.RunTriggerListOptions{
-		ListOptions: tfe.ListOptions{
-			PageSize: maxPageSize,
-		},
-		RunTriggerType: tfe.String("inbound"),
-	})
-	if err != nil {
-		return nil, err
-	}
+	return listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]*tfe.RunTrigger, *tfe.Pagination, error) {
+		list, err := client.RunTriggers.List(ctx, workspaceID, &tfe.RunTriggerListOptions{
+			ListOptions:    options,
+			RunTriggerType: tfe.RunTriggerInbound,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
 
-	return rts.Items, nil
+		return list.Items, list.Pagination, nil
+	})
 }
 
 // ToRunTriggers takes a list of tfe.RunTriggers and returns a list of RunTriggers
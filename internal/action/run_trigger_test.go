@@ -51,6 +51,7 @@ func TestMergeRunTriggers(t *testing.T) {
 		assert.Equal(t, RunTriggers{
 			{
 				SourceID:     "${tfe_workspace.workspace[\"default\"].id}",
+				SourceName:   workspaces[0].Name,
 				Workspace:    workspaces[0],
 				WorkspaceRef: (map[string]tfeprovider.DataWorkspace)(nil),
 			}}, triggers)
@@ -68,8 +69,9 @@ func TestMergeRunTriggers(t *testing.T) {
 
 		assert.Equal(t, RunTriggers{
 			{
-				SourceID:  "${data.tfe_workspace.run_trigger_workspaces[\"foo\"].id}",
-				Workspace: workspaces[0],
+				SourceID:   "${data.tfe_workspace.run_trigger_workspaces[\"foo\"].id}",
+				SourceName: "foo",
+				Workspace:  workspaces[0],
 				WorkspaceRef: map[string]tfeprovider.DataWorkspace{
 					"foo": {
 						Name:         "foo",
@@ -154,8 +156,9 @@ func TestMergeRunTriggers(t *testing.T) {
 
 		assert.Equal(t, RunTriggers{
 			{
-				SourceID:  "${data.tfe_workspace.run_trigger_workspaces[\"foo\"].id}",
-				Workspace: workspaces[0],
+				SourceID:   "${data.tfe_workspace.run_trigger_workspaces[\"foo\"].id}",
+				SourceName: "foo",
+				Workspace:  workspaces[0],
 				WorkspaceRef: map[string]tfeprovider.DataWorkspace{
 					"foo": {
 						Name:         "foo",
@@ -0,0 +1,315 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// resourceLabels are the human friendly names of the resources managed by the action
+var resourceLabels = map[string]string{
+	"tfe_workspace":                  "workspace",
+	"tfe_variable":                   "variable",
+	"tfe_team_access":                "team access",
+	"tfe_run_trigger":                "run trigger",
+	"tfe_notification_configuration": "notification",
+	"tfe_variable_set":               "variable set",
+	"tfe_workspace_variable_set":     "variable set attachment",
+}
+
+// summaryAttributes are the attributes shown for created and destroyed resources
+var summaryAttributes = map[string][]string{
+	"tfe_variable":                   {"category", "value"},
+	"tfe_team_access":                {"access"},
+	"tfe_notification_configuration": {"destination_type"},
+}
+
+// maxSummaryValueLength is the length after which values are truncated in the summary
+const maxSummaryValueLength = 60
+
+// PlanChange is a single resource change in a plan, matched to the workspace it belongs to
+type PlanChange struct {
+	Action    string
+	Resource  string
+	Name      string
+	Details   string
+	Workspace *Workspace
+}
+
+// changeKey returns the for_each key of the resource change, or the resource name if it is not a for_each resource
+func changeKey(rc *tfjson.ResourceChange) string {
+	if key, ok := rc.Index.(string); ok {
+		return key
+	}
+
+	return rc.Name
+}
+
+// ChangeWorkspace returns the workspace that the resource change belongs to, nil is returned for organization level resources.
+// Resources are keyed by workspace, resources keyed by resourceKey are looked up by their scope, otherwise the workspace with the longest matching key prefix is returned.
+func ChangeWorkspace(rc *tfjson.ResourceChange, workspaces []*Workspace) *Workspace {
+	return resourceWorkspace(rc.Type, rc.Name, changeKey(rc), workspaces)
+}
+
+// resourceWorkspace returns the workspace owning the resource of the passed type, name and for_each key, the key of resources without for_each is their name
+func resourceWorkspace(resourceType string, name string, key string, workspaces []*Workspace) *Workspace {
+	if resourceType == "tfe_workspace" {
+		return FindWorkspace(workspaces, key)
+	}
+
+	if resourceType == "tfe_variable_set" || (resourceType == "tfe_variable" && name == "variable_set_variables") {
+		return nil
+	}
+
+	if scopedResourceTypes[resourceType] {
+		scope, _, _ := strings.Cut(key, "/")
+		return FindWorkspace(workspaces, scope)
+	}
+
+	var match *Workspace
+
+	for _, ws := range workspaces {
+		if strings.HasPrefix(key, ws.Workspace+"-") && (match == nil || len(ws.Workspace) > len(match.Workspace)) {
+			match = ws
+		}
+	}
+
+	return match
+}
+
+// workspaceResourceName returns the name of the resource within the passed owning workspace, that is its for_each key without the workspace
+func workspaceResourceName(resourceType string, key string, ws *Workspace) string {
+	if scopedResourceTypes[resourceType] {
+		return strings.TrimPrefix(key, resourceKey(ws.Workspace, ""))
+	}
+
+	return strings.TrimPrefix(key, ws.Workspace+"-")
+}
+
+// workspaceResourceKey returns the for_each key of the named resource of the passed type within the workspace, the inverse of workspaceResourceName
+func workspaceResourceKey(resourceType string, ws *Workspace, name string) string {
+	if scopedResourceTypes[resourceType] {
+		return resourceKey(ws.Workspace, name)
+	}
+
+	return ws.Workspace + "-" + name
+}
+
+// changeAction returns the summarized action of the resource change, an empty string is returned for changes that are not shown
+func changeAction(actions tfjson.Actions) string {
+	switch {
+	case actions.Replace():
+		return "replace"
+	case actions.Create():
+		return "create"
+	case actions.Update():
+		return "update"
+	case actions.Delete():
+		return "destroy"
+	default:
+		return ""
+	}
+}
+
+// NewPlanChanges returns the managed resource changes of the plan, excluding no-op and read changes
+func NewPlanChanges(plan *tfjson.Plan, workspaces []*Workspace) []PlanChange {
+	changes := []PlanChange{}
+
+	for _, rc := range plan.ResourceChanges {
+		if rc.Mode != tfjson.ManagedResourceMode || rc.Change == nil {
+			continue
+		}
+
+		action := changeAction(rc.Change.Actions)
+		if action == "" {
+			continue
+		}
+
+		label, ok := resourceLabels[rc.Type]
+		if !ok {
+			label = rc.Type
+		}
+
+		ws := ChangeWorkspace(rc, workspaces)
+
+		name := changeKey(rc)
+		if ws != nil && rc.Type != "tfe_workspace" {
+			name = workspaceResourceName(rc.Type, name, ws)
+		} else if ws != nil {
+			name = ws.Name
+		}
+
+		changes = append(changes, PlanChange{
+			Action:    action,
+			Resource:  label,
+			Name:      name,
+			Details:   changeDetails(rc, action),
+			Workspace: ws,
+		})
+	}
+
+	return changes
+}
+
+// changeDetails describes the resource change. Updates list the changed attributes, creates and destroys list the summary attributes of the resource.
+func changeDetails(rc *tfjson.ResourceChange, action string) string {
+	before, _ := rc.Change.Before.(map[string]interface{})
+	after, _ := rc.Change.After.(map[string]interface{})
+	beforeSensitive, _ := rc.Change.BeforeSensitive.(map[string]interface{})
+	afterSensitive, _ := rc.Change.AfterSensitive.(map[string]interface{})
+	afterUnknown, _ := rc.Change.AfterUnknown.(map[string]interface{})
+
+	details := []string{}
+
+	switch action {
+	case "create", "destroy":
+		values, sensitive := after, afterSensitive
+		if action == "destroy" {
+			values, sensitive = before, beforeSensitive
+		}
+
+		for _, attr := range summaryAttributes[rc.Type] {
+			if v, ok := values[attr]; ok && v != nil {
+				details = append(details, fmt.Sprintf("%s: %s", attr, summaryValue(rc.Type, attr, v, values, sensitive, nil)))
+			}
+		}
+	default:
+		keys := []string{}
+
+		for k := range after {
+			if !reflect.DeepEqual(before[k], after[k]) || afterUnknown[k] == true {
+				keys = append(keys, k)
+			}
+		}
+
+		for k, unknown := range afterUnknown {
+			if _, ok := after[k]; !ok && unknown == true {
+				keys = append(keys, k)
+			}
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			details = append(details, fmt.Sprintf("%s: %s → %s",
+				k,
+				summaryValue(rc.Type, k, before[k], before, beforeSensitive, nil),
+				summaryValue(rc.Type, k, after[k], after, afterSensitive, afterUnknown),
+			))
+		}
+	}
+
+	return strings.Join(details, "<br>")
+}
+
+// summaryValue formats an attribute value for the summary. Sensitive values are redacted. Variable values are only shown for variables not marked as sensitive.
+func summaryValue(resourceType string, attr string, value interface{}, object map[string]interface{}, sensitive map[string]interface{}, unknown map[string]interface{}) string {
+	if unknown[attr] == true {
+		return "(known after apply)"
+	}
+
+	if resourceType == "tfe_variable" && attr == "value" {
+		if object["sensitive"] == true {
+			return redactedValue
+		}
+	} else if sensitive[attr] != nil && sensitive[attr] != false {
+		return redactedValue
+	}
+
+	if value == nil {
+		return "`null`"
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "(unknown)"
+		}
+
+		s = string(b)
+	}
+
+	if r := []rune(s); len(r) > maxSummaryValueLength {
+		s = string(r[:maxSummaryValueLength]) + "…"
+	}
+
+	s = strings.NewReplacer("|", "\\|", "`", "'", "\r", "", "\n", " ").Replace(s)
+
+	return fmt.Sprintf("`%s`", s)
+}
+
+// PlanSummaryMarkdown renders the plan changes as Markdown tables, grouped by workspace
+func PlanSummaryMarkdown(title string, changes []PlanChange) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "### %s\n\n", title)
+
+	if len(changes) == 0 {
+		b.WriteString("No changes\n")
+		return b.String()
+	}
+
+	counts := map[string]int{}
+	for _, c := range changes {
+		counts[c.Action]++
+	}
+
+	fmt.Fprintf(&b, "**%d to create, %d to update, %d to replace, %d to destroy**\n", counts["create"], counts["update"], counts["replace"], counts["destroy"])
+
+	groups := map[string][]PlanChange{}
+	names := []string{}
+
+	for _, c := range changes {
+		name := "Organization"
+		if c.Workspace != nil {
+			name = c.Workspace.Name
+		}
+
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+
+		groups[name] = append(groups[name], c)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n#### %s\n\n", name)
+		b.WriteString("| Action | Resource | Name | Details |\n")
+		b.WriteString("| - | - | - | - |\n")
+
+		for _, c := range groups[name] {
+			fmt.Fprintf(&b, "| %s | %s | `%s` | %s |\n", c.Action, c.Resource, strings.ReplaceAll(c.Name, "|", "\\|"), c.Details)
+		}
+	}
+
+	return b.String()
+}
+
+// WriteStepSummary appends the passed Markdown to the GitHub Actions job summary, nothing is written outside of GitHub Actions
+func WriteStepSummary(markdown string) error {
+	filePath := os.Getenv("GITHUB_STEP_SUMMARY")
+	if filePath == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open job summary file: %w", err)
+	}
+
+	defer f.Close()
+
+	if _, err := f.WriteString(markdown + "\n"); err != nil {
+		return fmt.Errorf("failed to write job summary: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package action
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPlan returns a plan with one change of each kind across the test workspaces
+func newTestPlan() *tfjson.Plan {
+	return &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			{
+				Address: "tfe_workspace.workspace[\"production\"]",
+				Mode:    tfjson.ManagedResourceMode,
+				Type:    "tfe_workspace",
+				Name:    "workspace",
+				Index:   "production",
+				Change: &tfjson.Change{
+					Actions:      tfjson.Actions{tfjson.ActionCreate},
+					After:        map[string]interface{}{"name": "foo-production"},
+					AfterUnknown: map[string]interface{}{"id": true},
+				},
+			},
+			{
+				Address: "tfe_variable.staging-region",
+				Mode:    tfjson.ManagedResourceMode,
+				Type:    "tfe_variable",
+				Name:    "staging-region",
+				Change: &tfjson.Change{
+					Actions:         tfjson.Actions{tfjson.ActionUpdate},
+					Before:          map[string]interface{}{"key": "region", "value": "us-east-1", "category": "env", "sensitive": false},
+					After:           map[string]interface{}{"key": "region", "value": "us-west-2", "category": "env", "sensitive": false},
+					BeforeSensitive: map[string]interface{}{"value": true},
+					AfterSensitive:  map[string]interface{}{"value": true},
+				},
+			},
+			{
+				Address: "tfe_variable.staging-secret",
+				Mode:    tfjson.ManagedResourceMode,
+				Type:    "tfe_variable",
+				Name:    "staging-secret",
+				Change: &tfjson.Change{
+					Actions:        tfjson.Actions{tfjson.ActionCreate},
+					After:          map[string]interface{}{"key": "secret", "value": "hunter2", "category": "env", "sensitive": true},
+					AfterSensitive: map[string]interface{}{"value": true},
+				},
+			},
+			{
+				Address: "tfe_team_access.teams[\"staging-team-abc123\"]",
+				Mode:    tfjson.ManagedResourceMode,
+				Type:    "tfe_team_access",
+				Name:    "teams",
+				Index:   "staging-team-abc123",
+				Change: &tfjson.Change{
+					Actions: tfjson.Actions{tfjson.ActionDelete},
+					Before:  map[string]interface{}{"access": "read"},
+				},
+			},
+			{
+				Address: "tfe_variable.variable_set_variables[\"shared/foo\"]",
+				Mode:    tfjson.ManagedResourceMode,
+				Type:    "tfe_variable",
+				Name:    "variable_set_variables",
+				Index:   "shared/foo",
+				Change: &tfjson.Change{
+					Actions: tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate},
+					Before:  map[string]interface{}{"value": "a|b", "category": "env", "sensitive": false},
+					After:   map[string]interface{}{"value": "a|b", "category": "terraform", "sensitive": false},
+				},
+			},
+			{
+				Address: "tfe_run_trigger.trigger[\"staging-ws-def456\"]",
+				Mode:    tfjson.ManagedResourceMode,
+				Type:    "tfe_run_trigger",
+				Name:    "trigger",
+				Index:   "staging-ws-def456",
+				Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionNoop}},
+			},
+			{
+				Address: "data.tfe_team.teams[\"readers\"]",
+				Mode:    tfjson.DataResourceMode,
+				Type:    "tfe_team",
+				Name:    "teams",
+				Index:   "readers",
+				Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionRead}},
+			},
+		},
+	}
+}
+
+func TestChangeWorkspace(t *testing.T) {
+	workspaces := []*Workspace{
+		{Name: "foo-staging", Workspace: "staging"},
+		{Name: "foo-staging-eu", Workspace: "staging-eu"},
+	}
+
+	for _, testCase := range []struct {
+		Description string
+		Change      *tfjson.ResourceChange
+		Expect      *Workspace
+	}{
+		{"workspace", &tfjson.ResourceChange{Type: "tfe_workspace", Name: "workspace", Index: "staging-eu"}, workspaces[1]},
+		{"variable", &tfjson.ResourceChange{Type: "tfe_variable", Name: "staging-foo"}, workspaces[0]},
+		{"longest matching workspace", &tfjson.ResourceChange{Type: "tfe_team_access", Name: "teams", Index: "staging-eu-team-abc123"}, workspaces[1]},
+		{"variable set variable", &tfjson.ResourceChange{Type: "tfe_variable", Name: "variable_set_variables", Index: "staging-foo"}, nil},
+		{"unknown workspace", &tfjson.ResourceChange{Type: "tfe_variable", Name: "production-foo"}, nil},
+		{"notification", &tfjson.ResourceChange{Type: "tfe_notification_configuration", Name: "notifications", Index: "staging/slack-eu"}, workspaces[0]},
+		{"variable set attachment", &tfjson.ResourceChange{Type: "tfe_workspace_variable_set", Name: "variable_sets", Index: "staging-eu/aws"}, workspaces[1]},
+	} {
+		t.Run(testCase.Description, func(t *testing.T) {
+			assert.Equal(t, testCase.Expect, ChangeWorkspace(testCase.Change, workspaces))
+		})
+	}
+}
+
+func TestNewPlanChanges(t *testing.T) {
+	workspaces := newTestMultiWorkspaceList()
+
+	changes := NewPlanChanges(newTestPlan(), workspaces)
+
+	assert.Equal(t, []PlanChange{
+		{Action: "create", Resource: "workspace", Name: "foo-production", Workspace: workspaces[1]},
+		{Action: "update", Resource: "variable", Name: "region", Details: "value: `us-east-1` → `us-west-2`", Workspace: workspaces[0]},
+		{Action: "create", Resource: "variable", Name: "secret", Details: "category: `env`<br>value: (sensitive)", Workspace: workspaces[0]},
+		{Action: "destroy", Resource: "team access", Name: "team-abc123", Details: "access: `read`", Workspace: workspaces[0]},
+		{Action: "replace", Resource: "variable", Name: "shared/foo", Details: "category: `env` → `terraform`"},
+	}, changes)
+
+	t.Run("workspace scoped keys", func(t *testing.T) {
+		changes := NewPlanChanges(&tfjson.Plan{
+			ResourceChanges: []*tfjson.ResourceChange{
+				{
+					Address: "tfe_notification_configuration.notifications[\"staging/slack\"]",
+					Mode:    tfjson.ManagedResourceMode,
+					Type:    "tfe_notification_configuration",
+					Name:    "notifications",
+					Index:   "staging/slack",
+					Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionDelete}},
+				},
+				{
+					Address: "tfe_workspace_variable_set.variable_sets[\"production/aws\"]",
+					Mode:    tfjson.ManagedResourceMode,
+					Type:    "tfe_workspace_variable_set",
+					Name:    "variable_sets",
+					Index:   "production/aws",
+					Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionCreate}},
+				},
+			},
+		}, workspaces)
+
+		assert.Equal(t, []PlanChange{
+			{Action: "destroy", Resource: "notification", Name: "slack", Workspace: workspaces[0]},
+			{Action: "create", Resource: "variable set attachment", Name: "aws", Workspace: workspaces[1]},
+		}, changes)
+	})
+}
+
+func TestPlanSummaryMarkdown(t *testing.T) {
+	t.Run("group changes by workspace", func(t *testing.T) {
+		summary := PlanSummaryMarkdown("Plan", NewPlanChanges(newTestPlan(), newTestMultiWorkspaceList()))
+
+		assert.Equal(t, "### Plan\n\n"+
+			"**2 to create, 1 to update, 1 to replace, 1 to destroy**\n"+
+			"\n#### Organization\n\n"+
+			"| Action | Resource | Name | Details |\n"+
+			"| - | - | - | - |\n"+
+			"| replace | variable | `shared/foo` | category: `env` → `terraform` |\n"+
+			"\n#### foo-production\n\n"+
+			"| Action | Resource | Name | Details |\n"+
+			"| - | - | - | - |\n"+
+			"| create | workspace | `foo-production` |  |\n"+
+			"\n#### foo-staging\n\n"+
+			"| Action | Resource | Name | Details |\n"+
+			"| - | - | - | - |\n"+
+			"| update | variable | `region` | value: `us-east-1` → `us-west-2` |\n"+
+			"| create | variable | `secret` | category: `env`<br>value: (sensitive) |\n"+
+			"| destroy | team access | `team-abc123` | access: `read` |\n", summary)
+	})
+
+	t.Run("report no changes", func(t *testing.T) {
+		assert.Equal(t, "### Plan\n\nNo changes\n", PlanSummaryMarkdown("Plan", nil))
+	})
+}
+
+func TestWriteStepSummary(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "summary.md")
+
+	t.Setenv("GITHUB_STEP_SUMMARY", filePath)
+
+	require.NoError(t, WriteStepSummary("first"))
+	require.NoError(t, WriteStepSummary("second"))
+
+	b, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+
+	assert.Equal(t, "first\nsecond\n", string(b))
+}
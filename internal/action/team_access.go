@@ -2,6 +2,7 @@ package action
 
 import (
 	"context"
+	"fmt"
 
 	tfe "github.com/hashicorp/go-tfe"
 	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
@@ -92,6 +93,9 @@ func findTeamByID(teams []*tfe.Team, teamID string) *tfe.Team {
 func ToTeamAccessItems(access []*tfe.TeamAccess, teams []*tfe.Team, workspace *Workspace) (ta []TeamAccessItem, err error) {
 	for _, a := range access {
 		t := findTeamByID(teams, a.Team.ID)
+		if t == nil {
+			return nil, fmt.Errorf("team %q with access to workspace %q not found in the organization", a.Team.ID, workspace.Name)
+		}
 
 		item := TeamAccessItem{
 			Workspace: workspace,
@@ -115,31 +119,29 @@ func ToTeamAccessItems(access []*tfe.TeamAccess, teams []*tfe.Team, workspace *W
 	return ta, nil
 }
 
-// FetchRelatedTeamAccess finds all team access resources related to the passed workspace
-func FetchRelatedTeams(ctx context.Context, client *tfe.Client, workspace *Workspace, organization string) ([]*tfe.Team, error) {
-	teams, err := client.Teams.List(ctx, organization, tfe.TeamListOptions{
-		ListOptions: tfe.ListOptions{
-			PageSize: maxPageSize,
-		},
-	})
-	if err != nil {
-		return nil, err
-	}
+// FetchRelatedTeams returns the teams of the passed organization
+func FetchRelatedTeams(ctx context.Context, client *tfe.Client, organization string) ([]*tfe.Team, error) {
+	return listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]*tfe.Team, *tfe.Pagination, error) {
+		list, err := client.Teams.List(ctx, organization, &tfe.TeamListOptions{ListOptions: options})
+		if err != nil {
+			return nil, nil, err
+		}
 
-	return teams.Items, nil
+		return list.Items, list.Pagination, nil
+	})
 }
 
 // FetchRelatedTeamAccess finds all team access resources related to the passed workspace
 func FetchRelatedTeamAccess(ctx context.Context, client *tfe.Client, workspace *Workspace) ([]*tfe.TeamAccess, error) {
-	teamAccess, err := client.TeamAccess.List(ctx, tfe.TeamAccessListOptions{
-		ListOptions: tfe.ListOptions{
-			PageSize: maxPageSize,
-		},
-		WorkspaceID: workspace.ID,
-	})
-	if err != nil {
-		return nil, err
-	}
+	return listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]*tfe.TeamAccess, *tfe.Pagination, error) {
+		list, err := client.TeamAccess.List(ctx, &tfe.TeamAccessListOptions{
+			ListOptions: options,
+			WorkspaceID: *workspace.ID,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
 
-	return teamAccess.Items, nil
+		return list.Items, list.Pagination, nil
+	})
 }
@@ -1,53 +1,469 @@
 package action
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/go-version"
-	install "github.com/hashicorp/hc-install"
 	"github.com/hashicorp/hc-install/product"
 	"github.com/hashicorp/hc-install/releases"
-	"github.com/hashicorp/hc-install/src"
 	"github.com/hashicorp/terraform-exec/tfexec"
+	"golang.org/x/crypto/openpgp"
 )
 
-func NewTerraformExec(ctx context.Context, workDir string, tfVersion string) (*tfexec.Terraform, error) {
-	v, err := version.NewVersion(tfVersion)
+// terraformDownloadAttempts is the number of attempts made to list and download Terraform releases before failing the run
+const terraformDownloadAttempts = 3
+
+// terraformDownloadRetryDelay is the delay before the first retry of a failed Terraform download, doubled on every retry
+var terraformDownloadRetryDelay = 2 * time.Second
+
+// TerraformInstallOptions controls how the Terraform binary running the generated configuration is found.
+// The binary at Path is used as is. Otherwise the Version, an exact version or a version constraint, is looked up in the CacheDir,
+// then downloaded from the MirrorURL, a mirror of https://releases.hashicorp.com, or from HashiCorp releases if no mirror is set.
+// Without a CacheDir, the binary is downloaded to a temporary directory in the InstallDir, or in the system temporary directory if it is empty.
+type TerraformInstallOptions struct {
+	Version    string
+	Path       string
+	MirrorURL  string
+	CacheDir   string
+	InstallDir string
+}
+
+// NewTerraformExec returns a Terraform CLI running in the passed working directory, using the Terraform binary found with the passed options.
+// A binary that is not cached is downloaded to the working directory, so it is removed along with it.
+func NewTerraformExec(ctx context.Context, workDir string, options *TerraformInstallOptions) (*tfexec.Terraform, error) {
+	if options.InstallDir == "" {
+		withInstallDir := *options
+		withInstallDir.InstallDir = workDir
+		options = &withInstallDir
+	}
+
+	execPath, err := FindTerraform(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	tf, err := tfexec.NewTerraform(workDir, execPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Path != "" && options.Version != "" {
+		checkTerraformVersion(ctx, tf, options.Version)
+	}
+
+	return tf, nil
+}
+
+// checkTerraformVersion warns if the version of the passed Terraform CLI does not satisfy the requested version
+func checkTerraformVersion(ctx context.Context, tf *tfexec.Terraform, requested string) {
+	v, err := TerraformVersion(ctx, tf)
+	if err != nil {
+		gha.Warningf("Failed to check the version of Terraform at %s: %s\n", tf.ExecPath(), err)
+		return
+	}
+
+	constraints, err := version.NewConstraint(requested)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse Terraform version: %w", err)
+		return
 	}
 
-	installer := install.NewInstaller()
-	execPath, err := installer.Ensure(ctx, []src.Source{
-		&releases.ExactVersion{
-			Product: product.Terraform,
-			Version: v,
-		},
+	if !constraints.Check(version.Must(version.NewVersion(v))) {
+		gha.Warningf("Terraform at %s is version %s, which does not satisfy the requested version %q\n", tf.ExecPath(), v, requested)
+	}
+}
+
+// TerraformVersion returns the version of the passed Terraform CLI
+func TerraformVersion(ctx context.Context, tf *tfexec.Terraform) (string, error) {
+	v, _, err := tf.Version(ctx, false)
+	if err != nil {
+		return "", err
+	}
+
+	return v.String(), nil
+}
+
+// FindTerraform returns the path of a Terraform binary matching the passed options, downloading it if it is not cached.
+// A version constraint is resolved to the latest available version satisfying it. If the available versions can't be listed,
+// the latest cached version satisfying the constraint is used instead.
+func FindTerraform(ctx context.Context, options *TerraformInstallOptions) (string, error) {
+	if options.Path != "" {
+		if _, err := os.Stat(options.Path); err != nil {
+			return "", fmt.Errorf("failed to find Terraform at %s: %w", options.Path, err)
+		}
+
+		return options.Path, nil
+	}
+
+	v, err := version.NewVersion(options.Version)
+	if err != nil {
+		constraints, cErr := version.NewConstraint(options.Version)
+		if cErr != nil {
+			return "", fmt.Errorf("failed to parse Terraform version %q, it must be a version or a version constraint: %w", options.Version, cErr)
+		}
+
+		if v, err = resolveTerraformVersion(ctx, constraints, options); err != nil {
+			return "", err
+		}
+	}
+
+	if execPath := cachedTerraformPath(options.CacheDir, v); execPath != "" {
+		if _, err := os.Stat(execPath); err == nil {
+			gha.Infof("Using cached Terraform %s\n", v)
+			return execPath, nil
+		}
+	}
+
+	var execPath string
+
+	err = withRetries(ctx, fmt.Sprintf("download Terraform %s", v), func() error {
+		execPath, err = downloadTerraform(ctx, v, options)
+		return err
+	})
+
+	return execPath, err
+}
+
+// resolveTerraformVersion returns the latest available Terraform version satisfying the passed constraints
+func resolveTerraformVersion(ctx context.Context, constraints version.Constraints, options *TerraformInstallOptions) (*version.Version, error) {
+	var available []*version.Version
+
+	err := withRetries(ctx, "list Terraform versions", func() error {
+		var err error
+
+		if options.MirrorURL != "" {
+			available, err = listMirrorTerraformVersions(ctx, options.MirrorURL)
+		} else {
+			available, err = listTerraformVersions(ctx)
+		}
+
+		return err
 	})
+	if err != nil {
+		cached := cachedTerraformVersions(options.CacheDir)
+		if v := latestTerraformVersion(cached, constraints); v != nil {
+			gha.Warningf("Failed to list Terraform versions, using cached Terraform %s: %s\n", v, err)
+			return v, nil
+		}
+
+		return nil, err
+	}
+
+	v := latestTerraformVersion(available, constraints)
+	if v == nil {
+		return nil, fmt.Errorf("no Terraform version satisfies %q", constraints)
+	}
+
+	gha.Infof("Resolved Terraform version %q to %s\n", constraints, v)
+
+	return v, nil
+}
+
+// latestTerraformVersion returns the latest of the passed versions satisfying the constraints, or nil if none do
+func latestTerraformVersion(versions []*version.Version, constraints version.Constraints) *version.Version {
+	var latest *version.Version
+
+	for _, v := range versions {
+		if constraints.Check(v) && (latest == nil || v.GreaterThan(latest)) {
+			latest = v
+		}
+	}
+
+	return latest
+}
+
+// withRetries calls f until it succeeds, up to terraformDownloadAttempts times, waiting longer between each attempt
+func withRetries(ctx context.Context, description string, f func() error) error {
+	delay := terraformDownloadRetryDelay
+
+	var err error
+
+	for attempt := 1; attempt <= terraformDownloadAttempts; attempt++ {
+		if err = f(); err == nil {
+			return nil
+		}
+
+		if attempt == terraformDownloadAttempts {
+			break
+		}
+
+		gha.Warningf("Failed to %s, retrying in %s: %s\n", description, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+
+	return fmt.Errorf("failed to %s after %d attempts: %w", description, terraformDownloadAttempts, err)
+}
+
+// cachedTerraformPath returns the path of the passed Terraform version in the cache directory, or an empty string if there is no cache directory
+func cachedTerraformPath(cacheDir string, v *version.Version) string {
+	if cacheDir == "" {
+		return ""
+	}
+
+	return filepath.Join(cacheDir, "terraform", v.String(), product.Terraform.BinaryName())
+}
+
+// cachedTerraformVersions returns the Terraform versions found in the cache directory
+func cachedTerraformVersions(cacheDir string) []*version.Version {
+	if cacheDir == "" {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(cacheDir, "terraform"))
+	if err != nil {
+		return nil
+	}
+
+	var versions []*version.Version
+
+	for _, e := range entries {
+		v, err := version.NewVersion(e.Name())
+		if err != nil {
+			continue
+		}
+
+		if _, err := os.Stat(cachedTerraformPath(cacheDir, v)); err == nil {
+			versions = append(versions, v)
+		}
+	}
+
+	return versions
+}
+
+// listTerraformVersions returns the Terraform versions published on HashiCorp releases
+func listTerraformVersions(ctx context.Context) ([]*version.Version, error) {
+	sources, err := (&releases.Versions{Product: product.Terraform}).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*version.Version
+
+	for _, s := range sources {
+		if ev, ok := s.(*releases.ExactVersion); ok {
+			versions = append(versions, ev.Version)
+		}
+	}
+
+	return versions, nil
+}
+
+// mirrorGet returns the body of the passed path of the release mirror
+func mirrorGet(ctx context.Context, mirrorURL string, filePath string) ([]byte, error) {
+	u := strings.TrimSuffix(mirrorURL, "/") + "/" + filePath
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get %s: %s", u, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
 
+// listMirrorTerraformVersions returns the Terraform versions listed in the index of the release mirror
+func listMirrorTerraformVersions(ctx context.Context, mirrorURL string) ([]*version.Version, error) {
+	b, err := mirrorGet(ctx, mirrorURL, "terraform/index.json")
 	if err != nil {
 		return nil, err
 	}
 
-	return tfexec.NewTerraform(workDir, execPath)
+	var index struct {
+		Versions map[string]json.RawMessage `json:"versions"`
+	}
+
+	if err := json.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("failed to decode the Terraform release index: %w", err)
+	}
+
+	var versions []*version.Version
+
+	for raw := range index.Versions {
+		if v, err := version.NewVersion(raw); err == nil {
+			versions = append(versions, v)
+		}
+	}
+
+	sort.Sort(version.Collection(versions))
+
+	return versions, nil
 }
 
-func writeTerraformrcFile(host string, token string) error {
-	b := []byte(fmt.Sprintf(`credentials %q { token = %q	}`, host, token))
+// downloadTerraform downloads the passed Terraform version to the cache directory, or to a temporary directory of the install directory if there is no cache directory
+func downloadTerraform(ctx context.Context, v *version.Version, options *TerraformInstallOptions) (string, error) {
+	parentDir := options.InstallDir
+
+	if options.CacheDir != "" {
+		if err := os.MkdirAll(options.CacheDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create the Terraform cache directory: %w", err)
+		}
+
+		parentDir = options.CacheDir
+	}
+
+	installDir, err := ioutil.TempDir(parentDir, ".terraform-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create the Terraform install directory: %w", err)
+	}
+
+	if options.MirrorURL != "" {
+		gha.Infof("Downloading Terraform %s from %s\n", v, options.MirrorURL)
+		err = downloadMirrorTerraform(ctx, options.MirrorURL, v, installDir)
+	} else {
+		gha.Infof("Downloading Terraform %s\n", v)
+		source := &releases.ExactVersion{Product: product.Terraform, Version: v, InstallDir: installDir}
+
+		if err = source.Validate(); err == nil {
+			_, err = source.Install(ctx)
+		}
+	}
 
-	home, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("failed to retrieve homedir: %w", err)
+		os.RemoveAll(installDir)
+		return "", err
+	}
+
+	execPath := cachedTerraformPath(options.CacheDir, v)
+	if execPath == "" {
+		return filepath.Join(installDir, product.Terraform.BinaryName()), nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filepath.Dir(execPath)), 0755); err != nil {
+		return "", fmt.Errorf("failed to create the Terraform cache directory: %w", err)
+	}
+
+	// a concurrent run may have cached the same version first, in which case its binary is used
+	if err := os.Rename(installDir, filepath.Dir(execPath)); err != nil {
+		os.RemoveAll(installDir)
+
+		if _, statErr := os.Stat(execPath); statErr != nil {
+			return "", fmt.Errorf("failed to cache Terraform %s: %w", v, err)
+		}
 	}
 
-	err = ioutil.WriteFile(path.Join(home, ".terraformrc"), b, 0644)
+	return execPath, nil
+}
+
+// verifyChecksumsSignature verifies that the passed checksums of a Terraform release are signed by HashiCorp, the way hc-install verifies them
+func verifyChecksumsSignature(sums []byte, signature []byte) error {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(terraformReleaseKey))
 	if err != nil {
-		return fmt.Errorf("failed to write Terraform Cloud credentials to home directory: %w", err)
+		return fmt.Errorf("failed to read the HashiCorp public key: %w", err)
+	}
+
+	if _, err := openpgp.CheckDetachedSignature(keyring, bytes.NewReader(sums), bytes.NewReader(signature)); err != nil {
+		return fmt.Errorf("failed to verify the checksums signature: %w", err)
 	}
 
 	return nil
 }
+
+// downloadMirrorTerraform downloads the Terraform archive of the current platform from the release mirror,
+// verifies the signature of the release checksums and the checksum of the archive, then unpacks the binary to dstDir
+func downloadMirrorTerraform(ctx context.Context, mirrorURL string, v *version.Version, dstDir string) error {
+	archiveName := fmt.Sprintf("terraform_%s_%s_%s.zip", v, runtime.GOOS, runtime.GOARCH)
+	sumsPath := path.Join("terraform", v.String(), fmt.Sprintf("terraform_%s_SHA256SUMS", v))
+
+	sums, err := mirrorGet(ctx, mirrorURL, sumsPath)
+	if err != nil {
+		return err
+	}
+
+	signature, err := mirrorGet(ctx, mirrorURL, sumsPath+".sig")
+	if err != nil {
+		return err
+	}
+
+	if err := verifyChecksumsSignature(sums, signature); err != nil {
+		return err
+	}
+
+	expected := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[1] == archiveName {
+			expected = fields[0]
+		}
+	}
+
+	if expected == "" {
+		return fmt.Errorf("no checksum found for %s", archiveName)
+	}
+
+	archive, err := mirrorGet(ctx, mirrorURL, path.Join("terraform", v.String(), archiveName))
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(archive)
+	if hex.EncodeToString(sum[:]) != expected {
+		return fmt.Errorf("checksum mismatch for %s", archiveName)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", archiveName, err)
+	}
+
+	for _, f := range r.File {
+		if f.Name != product.Terraform.BinaryName() {
+			continue
+		}
+
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+
+		defer src.Close()
+
+		dst, err := os.OpenFile(filepath.Join(dstDir, f.Name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			return err
+		}
+
+		defer dst.Close()
+
+		if _, err := io.Copy(dst, src); err != nil {
+			return err
+		}
+
+		return dst.Close()
+	}
+
+	return fmt.Errorf("%s does not contain the Terraform binary", archiveName)
+}
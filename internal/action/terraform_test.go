@@ -0,0 +1,201 @@
+package action
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// newTestTerraformArchive returns a zip archive holding a fake Terraform binary
+func newTestTerraformArchive(t *testing.T) []byte {
+	var b bytes.Buffer
+
+	w := zip.NewWriter(&b)
+
+	f, err := w.Create("terraform")
+	require.NoError(t, err)
+
+	_, err = f.Write([]byte("#!/bin/sh\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, w.Close())
+
+	return b.Bytes()
+}
+
+// newTestReleaseKey returns a new signing key, which replaces the HashiCorp public key for the duration of the test
+func newTestReleaseKey(t *testing.T) *openpgp.Entity {
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", nil)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	key := terraformReleaseKey
+	terraformReleaseKey = b.String()
+
+	t.Cleanup(func() {
+		terraformReleaseKey = key
+	})
+
+	return entity
+}
+
+// newTestTerraformMirror returns a release mirror serving the passed Terraform versions, with an archive for the current platform of the archived version
+// and its checksums signed by the passed signer
+func newTestTerraformMirror(t *testing.T, versions []string, archived string, archive []byte, checksum string, signer *openpgp.Entity) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	index := ""
+	for i, v := range versions {
+		if i > 0 {
+			index += ", "
+		}
+
+		index += fmt.Sprintf(`%q: {"name": "terraform", "version": %q}`, v, v)
+	}
+
+	archiveName := fmt.Sprintf("terraform_%s_%s_%s.zip", archived, runtime.GOOS, runtime.GOARCH)
+
+	mux.HandleFunc("/terraform/index.json", testServerResHandler(t, 200, fmt.Sprintf(`{"name": "terraform", "versions": {%s}}`, index)))
+	sums := fmt.Sprintf("%s  %s\n", checksum, archiveName)
+
+	var signature bytes.Buffer
+
+	require.NoError(t, openpgp.DetachSign(&signature, signer, strings.NewReader(sums), nil))
+
+	mux.HandleFunc(fmt.Sprintf("/terraform/%s/terraform_%s_SHA256SUMS", archived, archived), testServerResHandler(t, 200, sums))
+	mux.HandleFunc(fmt.Sprintf("/terraform/%s/terraform_%s_SHA256SUMS.sig", archived, archived), testServerResHandler(t, 200, signature.String()))
+	mux.HandleFunc(fmt.Sprintf("/terraform/%s/%s", archived, archiveName), func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(archive)
+		require.NoError(t, err)
+	})
+
+	return server
+}
+
+func TestFindTerraform(t *testing.T) {
+	ctx := context.Background()
+
+	delay := terraformDownloadRetryDelay
+	terraformDownloadRetryDelay = 0
+
+	t.Cleanup(func() {
+		terraformDownloadRetryDelay = delay
+	})
+
+	archive := newTestTerraformArchive(t)
+	sum := sha256.Sum256(archive)
+	key := newTestReleaseKey(t)
+
+	t.Run("resolve a constraint and cache the download from a mirror", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		server := newTestTerraformMirror(t, []string{"1.2.0", "1.3.5", "1.3.7", "1.4.0"}, "1.3.7", archive, hex.EncodeToString(sum[:]), key)
+
+		execPath, err := FindTerraform(ctx, &TerraformInstallOptions{Version: "~> 1.3.0", MirrorURL: server.URL, CacheDir: cacheDir})
+		require.NoError(t, err)
+
+		assert.Equal(t, filepath.Join(cacheDir, "terraform", "1.3.7", "terraform"), execPath)
+
+		b, err := ioutil.ReadFile(execPath)
+		require.NoError(t, err)
+
+		assert.Equal(t, "#!/bin/sh\n", string(b))
+
+		server.Close()
+
+		cached, err := FindTerraform(ctx, &TerraformInstallOptions{Version: "1.3.7", MirrorURL: server.URL, CacheDir: cacheDir})
+		require.NoError(t, err)
+
+		assert.Equal(t, execPath, cached)
+
+		cached, err = FindTerraform(ctx, &TerraformInstallOptions{Version: ">= 1.3", MirrorURL: server.URL, CacheDir: cacheDir})
+		require.NoError(t, err)
+
+		assert.Equal(t, execPath, cached)
+	})
+
+	t.Run("reject archives not matching their checksum", func(t *testing.T) {
+		server := newTestTerraformMirror(t, []string{"1.3.7"}, "1.3.7", archive, "abc123", key)
+
+		_, err := FindTerraform(ctx, &TerraformInstallOptions{Version: "1.3.7", MirrorURL: server.URL, CacheDir: t.TempDir()})
+
+		assert.EqualError(t, err, fmt.Sprintf("failed to download Terraform 1.3.7 after 3 attempts: checksum mismatch for terraform_1.3.7_%s_%s.zip", runtime.GOOS, runtime.GOARCH))
+	})
+
+	t.Run("reject checksums not signed by HashiCorp", func(t *testing.T) {
+		other, err := openpgp.NewEntity("Other", "", "other@example.com", nil)
+		require.NoError(t, err)
+
+		server := newTestTerraformMirror(t, []string{"1.3.7"}, "1.3.7", archive, hex.EncodeToString(sum[:]), other)
+
+		_, err = FindTerraform(ctx, &TerraformInstallOptions{Version: "1.3.7", MirrorURL: server.URL, CacheDir: t.TempDir()})
+
+		assert.EqualError(t, err, "failed to download Terraform 1.3.7 after 3 attempts: failed to verify the checksums signature: openpgp: signature made by unknown entity")
+	})
+
+	t.Run("download to the install directory without a cache directory", func(t *testing.T) {
+		installDir := t.TempDir()
+		server := newTestTerraformMirror(t, []string{"1.3.7"}, "1.3.7", archive, hex.EncodeToString(sum[:]), key)
+
+		execPath, err := FindTerraform(ctx, &TerraformInstallOptions{Version: "1.3.7", MirrorURL: server.URL, InstallDir: installDir})
+		require.NoError(t, err)
+
+		assert.Equal(t, installDir, filepath.Dir(filepath.Dir(execPath)))
+	})
+
+	t.Run("fail when no version satisfies the constraint", func(t *testing.T) {
+		server := newTestTerraformMirror(t, []string{"1.2.0"}, "1.2.0", archive, hex.EncodeToString(sum[:]), key)
+
+		_, err := FindTerraform(ctx, &TerraformInstallOptions{Version: "~> 1.3", MirrorURL: server.URL})
+
+		assert.EqualError(t, err, "no Terraform version satisfies \"~> 1.3\"")
+	})
+
+	t.Run("use the passed binary", func(t *testing.T) {
+		execPath := filepath.Join(t.TempDir(), "terraform")
+		require.NoError(t, ioutil.WriteFile(execPath, []byte("#!/bin/sh\n"), 0755))
+
+		found, err := FindTerraform(ctx, &TerraformInstallOptions{Version: "1.1.8", Path: execPath})
+		require.NoError(t, err)
+
+		assert.Equal(t, execPath, found)
+
+		_, err = FindTerraform(ctx, &TerraformInstallOptions{Path: filepath.Join(t.TempDir(), "missing")})
+
+		assert.ErrorContains(t, err, "failed to find Terraform at")
+	})
+}
+
+func TestTerraformReleaseKey(t *testing.T) {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(terraformReleaseKey))
+	require.NoError(t, err)
+
+	require.Len(t, keyring, 1)
+	assert.Equal(t, "72D7468F", keyring[0].PrimaryKey.KeyIdShortString())
+}
@@ -2,10 +2,15 @@ package action
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
 
 	tfe "github.com/hashicorp/go-tfe"
-	"github.com/sethvargo/go-githubactions"
 	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
 )
 
@@ -13,12 +18,162 @@ type VariablesInput []VariablesInputItem
 
 type WorkspaceVariablesInput map[string]VariablesInput
 
+// hasKey returns whether a variable with the passed key is in the inputs
+func (vi VariablesInput) hasKey(key string) bool {
+	for _, v := range vi {
+		if v.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
 type VariablesInputItem struct {
-	Key         string `yaml:"key"`
-	Value       string `yaml:"value"`
-	Description string `yaml:"description,omitempty"`
-	Category    string `yaml:"category,omitempty"`
-	Sensitive   bool   `yaml:"sensitive,omitempty"`
+	Key         string             `yaml:"key"`
+	Value       string             `yaml:"value"`
+	ValueFrom   *VariableValueFrom `yaml:"value_from,omitempty"`
+	Description string             `yaml:"description,omitempty"`
+	Category    string             `yaml:"category,omitempty"`
+	Sensitive   bool               `yaml:"sensitive,omitempty"`
+	HCL         bool               `yaml:"hcl,omitempty"`
+}
+
+// VariableValueFrom is a source that a variable value is read from instead of being inlined in the inputs
+type VariableValueFrom struct {
+	Env     string `yaml:"env,omitempty"`
+	File    string `yaml:"file,omitempty"`
+	JSONKey string `yaml:"json_key,omitempty"`
+}
+
+// UnmarshalYAML decodes a variable input. List and map values are rendered to an HCL string and the variable is marked as HCL.
+func (vi *VariablesInputItem) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain VariablesInputItem
+
+	var item plain
+
+	if err := unmarshal(&item); err == nil {
+		*vi = VariablesInputItem(item)
+		return nil
+	}
+
+	var complexItem struct {
+		Key         string             `yaml:"key"`
+		Value       interface{}        `yaml:"value"`
+		ValueFrom   *VariableValueFrom `yaml:"value_from,omitempty"`
+		Description string             `yaml:"description,omitempty"`
+		Category    string             `yaml:"category,omitempty"`
+		Sensitive   bool               `yaml:"sensitive,omitempty"`
+		HCL         bool               `yaml:"hcl,omitempty"`
+	}
+
+	if err := unmarshal(&complexItem); err != nil {
+		return err
+	}
+
+	value, err := toHCL(complexItem.Value)
+	if err != nil {
+		return fmt.Errorf("failed to render variable %q as HCL: %w", complexItem.Key, err)
+	}
+
+	*vi = VariablesInputItem{
+		Key:         complexItem.Key,
+		Value:       value,
+		ValueFrom:   complexItem.ValueFrom,
+		Description: complexItem.Description,
+		Category:    complexItem.Category,
+		Sensitive:   complexItem.Sensitive,
+		HCL:         true,
+	}
+
+	return nil
+}
+
+// Resolve reads the variable value from its source
+func (vf VariableValueFrom) Resolve() (string, error) {
+	switch {
+	case vf.Env != "" && vf.File != "":
+		return "", fmt.Errorf("only one of env or file can be set")
+	case vf.Env != "":
+		if vf.JSONKey != "" {
+			return "", fmt.Errorf("json_key can only be used with file")
+		}
+
+		value, ok := os.LookupEnv(vf.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q is not set", vf.Env)
+		}
+
+		return value, nil
+	case vf.File != "":
+		b, err := ioutil.ReadFile(vf.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+
+		if vf.JSONKey == "" {
+			return strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r"), nil
+		}
+
+		var values map[string]interface{}
+
+		if err := json.Unmarshal(b, &values); err != nil {
+			return "", fmt.Errorf("failed to decode JSON file %q: %w", vf.File, err)
+		}
+
+		value, ok := values[vf.JSONKey]
+		if !ok {
+			return "", fmt.Errorf("key %q not found in JSON file %q", vf.JSONKey, vf.File)
+		}
+
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+
+		return string(encoded), nil
+	default:
+		return "", fmt.Errorf("one of env or file must be set")
+	}
+}
+
+// ResolveValues sets the value of every variable with a value_from source. Resolved values are masked in the GitHub Actions log output before they are used,
+// and their variables are marked as sensitive, so the values are never shown in plans, summaries or pull request comments.
+func (vi VariablesInput) ResolveValues() error {
+	for i := range vi {
+		if vi[i].ValueFrom == nil {
+			continue
+		}
+
+		if vi[i].Value != "" {
+			return fmt.Errorf("variable %q cannot set both value and value_from", vi[i].Key)
+		}
+
+		value, err := vi[i].ValueFrom.Resolve()
+		if err != nil {
+			return fmt.Errorf("failed to resolve the value of variable %q: %w", vi[i].Key, err)
+		}
+
+		maskValue(value)
+
+		vi[i].Value = value
+		vi[i].Sensitive = true
+	}
+
+	return nil
+}
+
+// maskValue masks each line of the passed value in the GitHub Actions log output
+func maskValue(value string) {
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			gha.AddMask(line)
+		}
+	}
 }
 
 type Variables []Variable
@@ -29,6 +184,7 @@ type Variable struct {
 	Description string
 	Category    string
 	Sensitive   bool
+	HCL         bool
 	Workspace   *Workspace
 }
 
@@ -40,6 +196,7 @@ func NewVariable(vi VariablesInputItem, w *Workspace) *Variable {
 		Description: vi.Description,
 		Category:    vi.Category,
 		Sensitive:   vi.Sensitive,
+		HCL:         vi.HCL,
 		Workspace:   w,
 	}
 }
@@ -55,8 +212,8 @@ func (vs Variables) MaskSensitive() {
 
 // Mask masks a variable's value in the GitHub Actions log output
 func (v Variable) Mask() {
-	githubactions.Debugf("Masking variable %q\n", v.Key)
-	githubactions.AddMask(v.Value)
+	gha.Debugf("Masking variable %q\n", v.Key)
+	gha.AddMask(v.Value)
 }
 
 // ToResource converts a variable to a Terraform variable resource
@@ -67,6 +224,7 @@ func (v Variable) ToResource() *tfeprovider.Variable {
 		Description: v.Description,
 		Category:    v.Category,
 		Sensitive:   v.Sensitive,
+		HCL:         v.HCL,
 		WorkspaceID: fmt.Sprintf("${tfe_workspace.workspace[%q].id}", v.Workspace.Workspace),
 	}
 }
@@ -79,20 +237,128 @@ func ToVariable(v *tfe.Variable, workspace *Workspace) *Variable {
 		Description: v.Description,
 		Category:    string(v.Category),
 		Sensitive:   v.Sensitive,
+		HCL:         v.HCL,
 		Workspace:   workspace,
 	}
 }
 
 // FetchRelatedVariables returns tfe.Variables related to the passed workspace
 func FetchRelatedVariables(ctx context.Context, client *tfe.Client, workspace *Workspace) ([]*tfe.Variable, error) {
-	vars, err := client.Variables.List(ctx, *workspace.ID, tfe.VariableListOptions{
-		ListOptions: tfe.ListOptions{
-			PageSize: maxPageSize,
-		},
+	return listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]*tfe.Variable, *tfe.Pagination, error) {
+		list, err := client.Variables.List(ctx, *workspace.ID, &tfe.VariableListOptions{ListOptions: options})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return list.Items, list.Pagination, nil
 	})
-	if err != nil {
-		return nil, err
+}
+
+// hclQuote returns the passed string as a quoted HCL string literal, with template sequences escaped so they are kept as written
+func hclQuote(s string) string {
+	var b strings.Builder
+
+	b.WriteByte('"')
+
+	for i, r := range s {
+		switch r {
+		case '$', '%':
+			// escape template sequences twice, as the HCL value is evaluated once as a string of the main.tf.json template
+			// and then by Terraform Cloud, e.g. ${ is written as $$${
+			if strings.HasPrefix(s[i+1:], "{") {
+				b.WriteRune(r)
+				b.WriteRune(r)
+			}
+
+			b.WriteRune(r)
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, "\\u%04x", r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
 	}
 
-	return vars.Items, nil
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+var hclIdentifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// toHCL renders a decoded YAML value as an HCL expression
+func toHCL(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return hclQuote(v), nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+
+		for i, item := range v {
+			s, err := toHCL(item)
+			if err != nil {
+				return "", err
+			}
+
+			items[i] = s
+		}
+
+		return fmt.Sprintf("[%s]", strings.Join(items, ", ")), nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+
+		for key, item := range v {
+			k, ok := key.(string)
+			if !ok {
+				k = fmt.Sprint(key)
+			}
+
+			m[k] = item
+		}
+
+		return toHCL(m)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+
+		for key := range v {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		attrs := make([]string, len(keys))
+
+		for i, key := range keys {
+			s, err := toHCL(v[key])
+			if err != nil {
+				return "", err
+			}
+
+			name := key
+			if !hclIdentifierRegexp.MatchString(key) {
+				name = hclQuote(key)
+			}
+
+			attrs[i] = fmt.Sprintf("%s = %s", name, s)
+		}
+
+		return fmt.Sprintf("{%s}", strings.Join(attrs, ", ")), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
 }
@@ -0,0 +1,264 @@
+package action
+
+import (
+	"context"
+	"fmt"
+
+	tfe "github.com/hashicorp/go-tfe"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfconfig"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
+)
+
+// VariableSetInput is an organization variable set. Sets declaring variables are created and managed by the action, other sets are looked up by name and attached.
+type VariableSetInput struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description,omitempty"`
+	Global      bool           `yaml:"global,omitempty"`
+	Variables   VariablesInput `yaml:"variables,omitempty"`
+	Workspaces  []string       `yaml:"workspaces,omitempty"`
+}
+
+type VariableSetInputs []VariableSetInput
+
+type VariableSet struct {
+	Input      *VariableSetInput
+	Workspaces []*Workspace
+}
+
+type VariableSetDataResource struct {
+	ForEach      map[string]VariableSetDataResource `json:"for_each,omitempty"`
+	Name         string                             `json:"name"`
+	Organization string                             `json:"organization"`
+}
+
+// NewVariableSets validates the variable set inputs and resolves the workspaces each set is attached to. Sets without workspaces are attached to every workspace, unless they are global.
+func NewVariableSets(inputs VariableSetInputs, workspaces []*Workspace) ([]*VariableSet, error) {
+	sets := []*VariableSet{}
+	names := map[string]bool{}
+
+	for i := range inputs {
+		input := &inputs[i]
+
+		if input.Name == "" {
+			return nil, fmt.Errorf("variable set name is required")
+		}
+
+		if names[input.Name] {
+			return nil, fmt.Errorf("variable set %q is specified more than once", input.Name)
+		}
+
+		names[input.Name] = true
+
+		if !input.Managed() && (input.Global || input.Description != "") {
+			return nil, fmt.Errorf("variable set %q sets description or global without variables, existing variable sets can only be attached by name", input.Name)
+		}
+
+		if input.Global && len(input.Workspaces) > 0 {
+			return nil, fmt.Errorf("variable set %q is global and cannot be limited to workspaces", input.Name)
+		}
+
+		keys := map[string]bool{}
+
+		for _, v := range input.Variables {
+			if keys[v.Key] {
+				return nil, fmt.Errorf("variable %q is specified more than once in variable set %q", v.Key, input.Name)
+			}
+
+			keys[v.Key] = true
+		}
+
+		set := &VariableSet{Input: input}
+
+		switch {
+		case input.Global:
+		case len(input.Workspaces) == 0:
+			set.Workspaces = workspaces
+		default:
+			for _, wsName := range input.Workspaces {
+				ws := FindWorkspace(workspaces, wsName)
+				if ws == nil {
+					return nil, fmt.Errorf("variable set %q specified for unknown workspace %q", input.Name, wsName)
+				}
+
+				set.Workspaces = append(set.Workspaces, ws)
+			}
+		}
+
+		sets = append(sets, set)
+	}
+
+	return sets, nil
+}
+
+// Managed returns whether the variable set is created by the action rather than looked up by name
+func (vi VariableSetInput) Managed() bool {
+	return len(vi.Variables) > 0
+}
+
+// IDRef returns a reference to the variable set ID, either from the managed resource or the data source
+func (vs VariableSet) IDRef() string {
+	if vs.Input.Managed() {
+		return fmt.Sprintf("${tfe_variable_set.variable_sets[%q].id}", vs.Input.Name)
+	}
+
+	return fmt.Sprintf("${data.tfe_variable_set.variable_sets[%q].id}", vs.Input.Name)
+}
+
+// MaskSensitive masks the sensitive variable set values in the GitHub Actions log output
+func (vs VariableSet) MaskSensitive() {
+	variables := Variables{}
+
+	for _, v := range vs.Input.Variables {
+		variables = append(variables, Variable{Key: v.Key, Value: v.Value, Sensitive: v.Sensitive})
+	}
+
+	variables.MaskSensitive()
+}
+
+// AppendVariableSets adds the passed variable sets, their variables and workspace attachments to the module
+func AppendVariableSets(module *tfconfig.Module, sets []*VariableSet, organization string) {
+	if len(sets) == 0 {
+		return
+	}
+
+	dataForEach := map[string]VariableSetDataResource{}
+	setForEach := map[string]tfeprovider.VariableSet{}
+	variableForEach := map[string]tfeprovider.VariableSetVariable{}
+	attachmentForEach := map[string]tfeprovider.WorkspaceVariableSet{}
+
+	for _, set := range sets {
+		if set.Input.Managed() {
+			resource := tfeprovider.VariableSet{
+				Name:         set.Input.Name,
+				Description:  set.Input.Description,
+				Organization: organization,
+			}
+
+			if set.Input.Global {
+				resource.Global = true
+			}
+
+			setForEach[set.Input.Name] = resource
+		} else {
+			dataForEach[set.Input.Name] = VariableSetDataResource{
+				Name:         set.Input.Name,
+				Organization: organization,
+			}
+		}
+
+		for _, v := range set.Input.Variables {
+			variable := tfeprovider.VariableSetVariable{
+				Key:           v.Key,
+				Value:         v.Value,
+				Description:   v.Description,
+				Category:      v.Category,
+				VariableSetID: set.IDRef(),
+			}
+
+			if v.Sensitive {
+				variable.Sensitive = true
+			}
+
+			if v.HCL {
+				variable.HCL = true
+			}
+
+			variableForEach[resourceKey(set.Input.Name, v.Key)] = variable
+		}
+
+		for _, ws := range set.Workspaces {
+			attachmentForEach[resourceKey(ws.Workspace, set.Input.Name)] = tfeprovider.WorkspaceVariableSet{
+				VariableSetID: set.IDRef(),
+				WorkspaceID:   fmt.Sprintf("${tfe_workspace.workspace[%q].id}", ws.Workspace),
+			}
+		}
+	}
+
+	if len(dataForEach) > 0 {
+		module.AppendData("tfe_variable_set", "variable_sets", VariableSetDataResource{
+			ForEach:      dataForEach,
+			Name:         "${each.value.name}",
+			Organization: "${each.value.organization}",
+		})
+	}
+
+	if len(setForEach) > 0 {
+		module.AppendResource("tfe_variable_set", "variable_sets", tfeprovider.VariableSet{
+			ForEach:      setForEach,
+			Name:         "${each.value.name}",
+			Description:  "${lookup(each.value, \"description\", null)}",
+			Global:       "${lookup(each.value, \"global\", false)}",
+			Organization: "${each.value.organization}",
+		})
+	}
+
+	if len(variableForEach) > 0 {
+		module.AppendResource("tfe_variable", "variable_set_variables", tfeprovider.VariableSetVariable{
+			ForEach:       variableForEach,
+			Key:           "${each.value.key}",
+			Value:         "${each.value.value}",
+			Description:   "${lookup(each.value, \"description\", null)}",
+			Category:      "${each.value.category}",
+			VariableSetID: "${each.value.variable_set_id}",
+			Sensitive:     "${lookup(each.value, \"sensitive\", false)}",
+			HCL:           "${lookup(each.value, \"hcl\", false)}",
+		})
+	}
+
+	if len(attachmentForEach) > 0 {
+		module.AppendResource("tfe_workspace_variable_set", "variable_sets", tfeprovider.WorkspaceVariableSet{
+			ForEach:       attachmentForEach,
+			VariableSetID: "${each.value.variable_set_id}",
+			WorkspaceID:   "${each.value.workspace_id}",
+		})
+	}
+}
+
+// FetchVariableSets returns every variable set in the organization, including the workspaces each set is attached to
+func FetchVariableSets(ctx context.Context, client *tfe.Client, organization string) ([]*tfe.VariableSet, error) {
+	return listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]*tfe.VariableSet, *tfe.Pagination, error) {
+		list, err := client.VariableSets.List(ctx, organization, &tfe.VariableSetListOptions{
+			ListOptions: options,
+			Include:     string(tfe.VariableSetWorkspaces),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return list.Items, list.Pagination, nil
+	})
+}
+
+// FetchVariableSetVariables returns the variables of the passed variable set
+func FetchVariableSetVariables(ctx context.Context, client *tfe.Client, variableSetID string) ([]*tfe.VariableSetVariable, error) {
+	return listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]*tfe.VariableSetVariable, *tfe.Pagination, error) {
+		list, err := client.VariableSetVariables.List(ctx, variableSetID, &tfe.VariableSetVariableListOptions{ListOptions: options})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return list.Items, list.Pagination, nil
+	})
+}
+
+// FindVariableSetByName returns the variable set matching the passed name, nil is returned if no set matches
+func FindVariableSetByName(sets []*tfe.VariableSet, name string) *tfe.VariableSet {
+	for _, s := range sets {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// isAttached returns whether the passed variable set is attached to the workspace with the passed ID
+func isAttached(set *tfe.VariableSet, workspaceID string) bool {
+	for _, ws := range set.Workspaces {
+		if ws.ID == workspaceID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,241 @@
+package action
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
+)
+
+func TestNewVariableSets(t *testing.T) {
+	workspaces := newTestMultiWorkspaceList()
+
+	t.Run("attach sets to every workspace by default", func(t *testing.T) {
+		inputs := VariableSetInputs{
+			{Name: "aws"},
+			{Name: "shared", Variables: VariablesInput{{Key: "foo", Value: "bar", Category: "env"}}, Workspaces: []string{"production"}},
+			{Name: "global", Global: true, Variables: VariablesInput{{Key: "foo", Value: "bar", Category: "env"}}},
+		}
+
+		sets, err := NewVariableSets(inputs, workspaces)
+		require.NoError(t, err)
+
+		assert.Equal(t, []*VariableSet{
+			{Input: &inputs[0], Workspaces: workspaces},
+			{Input: &inputs[1], Workspaces: []*Workspace{workspaces[1]}},
+			{Input: &inputs[2]},
+		}, sets)
+	})
+
+	for _, testCase := range []struct {
+		Description string
+		Inputs      VariableSetInputs
+		Expect      string
+	}{
+		{
+			Description: "require a name",
+			Inputs:      VariableSetInputs{{Variables: VariablesInput{{Key: "foo"}}}},
+			Expect:      "variable set name is required",
+		},
+		{
+			Description: "reject duplicate sets",
+			Inputs:      VariableSetInputs{{Name: "aws"}, {Name: "aws"}},
+			Expect:      "variable set \"aws\" is specified more than once",
+		},
+		{
+			Description: "reject settings for sets attached by name",
+			Inputs:      VariableSetInputs{{Name: "aws", Global: true}},
+			Expect:      "variable set \"aws\" sets description or global without variables, existing variable sets can only be attached by name",
+		},
+		{
+			Description: "reject global sets limited to workspaces",
+			Inputs:      VariableSetInputs{{Name: "aws", Global: true, Variables: VariablesInput{{Key: "foo"}}, Workspaces: []string{"staging"}}},
+			Expect:      "variable set \"aws\" is global and cannot be limited to workspaces",
+		},
+		{
+			Description: "reject duplicate variables",
+			Inputs:      VariableSetInputs{{Name: "aws", Variables: VariablesInput{{Key: "foo"}, {Key: "foo"}}}},
+			Expect:      "variable \"foo\" is specified more than once in variable set \"aws\"",
+		},
+		{
+			Description: "reject unknown workspaces",
+			Inputs:      VariableSetInputs{{Name: "aws", Workspaces: []string{"prod"}}},
+			Expect:      "variable set \"aws\" specified for unknown workspace \"prod\"",
+		},
+	} {
+		t.Run(testCase.Description, func(t *testing.T) {
+			_, err := NewVariableSets(testCase.Inputs, workspaces)
+
+			assert.EqualError(t, err, testCase.Expect)
+		})
+	}
+}
+
+func TestAppendVariableSets(t *testing.T) {
+	t.Run("add managed and existing variable sets", func(t *testing.T) {
+		module := NewModule()
+		workspaces := newTestMultiWorkspaceList()
+
+		AppendVariableSets(module, []*VariableSet{
+			{Input: &VariableSetInput{Name: "aws"}, Workspaces: workspaces},
+			{
+				Input: &VariableSetInput{
+					Name:        "shared",
+					Description: "Shared settings",
+					Variables: VariablesInput{
+						{Key: "AWS_SECRET_ACCESS_KEY", Value: "secret", Category: "env", Sensitive: true},
+						{Key: "zones", Value: `["a"]`, Category: "terraform", HCL: true},
+					},
+				},
+				Workspaces: workspaces[1:],
+			},
+		}, "org")
+
+		assert.Equal(t, VariableSetDataResource{
+			ForEach: map[string]VariableSetDataResource{
+				"aws": {Name: "aws", Organization: "org"},
+			},
+			Name:         "${each.value.name}",
+			Organization: "${each.value.organization}",
+		}, module.Data["tfe_variable_set"]["variable_sets"])
+
+		assert.Equal(t, tfeprovider.VariableSet{
+			ForEach: map[string]tfeprovider.VariableSet{
+				"shared": {Name: "shared", Description: "Shared settings", Organization: "org"},
+			},
+			Name:         "${each.value.name}",
+			Description:  "${lookup(each.value, \"description\", null)}",
+			Global:       "${lookup(each.value, \"global\", false)}",
+			Organization: "${each.value.organization}",
+		}, module.Resources["tfe_variable_set"]["variable_sets"])
+
+		assert.Equal(t, map[string]tfeprovider.VariableSetVariable{
+			"shared/AWS_SECRET_ACCESS_KEY": {
+				Key:           "AWS_SECRET_ACCESS_KEY",
+				Value:         "secret",
+				Category:      "env",
+				Sensitive:     true,
+				VariableSetID: "${tfe_variable_set.variable_sets[\"shared\"].id}",
+			},
+			"shared/zones": {
+				Key:           "zones",
+				Value:         `["a"]`,
+				Category:      "terraform",
+				HCL:           true,
+				VariableSetID: "${tfe_variable_set.variable_sets[\"shared\"].id}",
+			},
+		}, module.Resources["tfe_variable"]["variable_set_variables"].(tfeprovider.VariableSetVariable).ForEach)
+
+		assert.Equal(t, tfeprovider.WorkspaceVariableSet{
+			ForEach: map[string]tfeprovider.WorkspaceVariableSet{
+				"staging/aws": {
+					VariableSetID: "${data.tfe_variable_set.variable_sets[\"aws\"].id}",
+					WorkspaceID:   "${tfe_workspace.workspace[\"staging\"].id}",
+				},
+				"production/aws": {
+					VariableSetID: "${data.tfe_variable_set.variable_sets[\"aws\"].id}",
+					WorkspaceID:   "${tfe_workspace.workspace[\"production\"].id}",
+				},
+				"production/shared": {
+					VariableSetID: "${tfe_variable_set.variable_sets[\"shared\"].id}",
+					WorkspaceID:   "${tfe_workspace.workspace[\"production\"].id}",
+				},
+			},
+			VariableSetID: "${each.value.variable_set_id}",
+			WorkspaceID:   "${each.value.workspace_id}",
+		}, module.Resources["tfe_workspace_variable_set"]["variable_sets"])
+	})
+
+	t.Run("add no attachments for global sets", func(t *testing.T) {
+		module := NewModule()
+
+		AppendVariableSets(module, []*VariableSet{
+			{Input: &VariableSetInput{Name: "global", Global: true, Variables: VariablesInput{{Key: "foo", Value: "bar", Category: "env"}}}},
+		}, "org")
+
+		assert.Equal(t, true, module.Resources["tfe_variable_set"]["variable_sets"].(tfeprovider.VariableSet).ForEach["global"].Global)
+		assert.NotContains(t, module.Resources, "tfe_workspace_variable_set")
+		assert.NotContains(t, module.Data, "tfe_variable_set")
+	})
+
+	t.Run("add nothing when no variable sets are passed", func(t *testing.T) {
+		module := NewModule()
+
+		AppendVariableSets(module, nil, "org")
+
+		assert.Empty(t, module.Resources)
+		assert.Empty(t, module.Data)
+	})
+}
+
+func TestImportVariableSets(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	mux.HandleFunc("/api/v2/organizations/org/varsets", testServerPagesHandler(t, [][]string{
+		{`{"id": "varset-aws", "type": "varsets", "attributes": {"name": "aws"}, "relationships": {"workspaces": {"data": [{"id": "ws-abc123", "type": "workspaces"}]}}}`},
+		{`{"id": "varset-shared", "type": "varsets", "attributes": {"name": "shared"}, "relationships": {"workspaces": {"data": [{"id": "ws-def456", "type": "workspaces"}]}}}`},
+	}))
+
+	mux.HandleFunc("/api/v2/varsets/varset-shared/relationships/vars", testServerPagesHandler(t, [][]string{
+		{
+			`{"id": "var-foo", "type": "vars", "attributes": {"key": "foo", "category": "env"}}`,
+			`{"id": "var-unmanaged", "type": "vars", "attributes": {"key": "unmanaged", "category": "env"}}`,
+		},
+	}))
+
+	client := newTestTFClient(t, server.URL)
+	workspaces := newTestMultiWorkspaceList()
+
+	sets := []*VariableSet{
+		{Input: &VariableSetInput{Name: "aws"}, Workspaces: workspaces},
+		{Input: &VariableSetInput{Name: "shared", Variables: VariablesInput{{Key: "foo", Value: "bar", Category: "env"}}}, Workspaces: workspaces},
+		{Input: &VariableSetInput{Name: "missing", Variables: VariablesInput{{Key: "foo", Value: "bar", Category: "env"}}}, Workspaces: workspaces},
+	}
+
+	t.Run("import existing sets, their variables and attachments", func(t *testing.T) {
+		tf := TestTFExec{
+			State: &tfjson.State{},
+		}
+
+		require.NoError(t, ImportVariableSets(ctx, client, &tf, sets, "org"))
+
+		assert.Equal(t, []*ImportArgs{
+			{Address: "tfe_workspace_variable_set.variable_sets[\"staging/aws\"]", ID: "org/foo-staging/aws", Opts: ([]tfexec.ImportOption)(nil)},
+			{Address: "tfe_variable_set.variable_sets[\"shared\"]", ID: "varset-shared", Opts: ([]tfexec.ImportOption)(nil)},
+			{Address: "tfe_variable.variable_set_variables[\"shared/foo\"]", ID: "org/varset-shared/var-foo", Opts: ([]tfexec.ImportOption)(nil)},
+			{Address: "tfe_workspace_variable_set.variable_sets[\"production/shared\"]", ID: "org/foo-production/shared", Opts: ([]tfexec.ImportOption)(nil)},
+		}, tf.ImportArgs)
+	})
+
+	t.Run("skip resources already in state", func(t *testing.T) {
+		tf := TestTFExec{
+			State: &tfjson.State{
+				Values: &tfjson.StateValues{
+					RootModule: &tfjson.StateModule{
+						Resources: []*tfjson.StateResource{
+							{Address: "tfe_workspace_variable_set.variable_sets[\"staging/aws\"]"},
+							{Address: "tfe_variable_set.variable_sets[\"shared\"]"},
+						},
+					},
+				},
+			},
+		}
+
+		require.NoError(t, ImportVariableSets(ctx, client, &tf, sets, "org"))
+
+		assert.Len(t, tf.ImportArgs, 2)
+	})
+}
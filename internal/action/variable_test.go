@@ -0,0 +1,224 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	tfe "github.com/hashicorp/go-tfe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/takescoop/terraform-cloud-workspace-action/internal/tfeprovider"
+	yaml "gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+func TestVariablesInputUnmarshalYAML(t *testing.T) {
+	for _, testCase := range []struct {
+		Description string
+		Input       string
+		Expect      VariablesInput
+	}{
+		{
+			Description: "string value",
+			Input:       `[{key: foo, value: bar, category: env}]`,
+			Expect:      VariablesInput{{Key: "foo", Value: "bar", Category: "env"}},
+		},
+		{
+			Description: "scalar values are kept as written",
+			Input:       `[{key: foo, value: 1.0, category: terraform}, {key: bar, value: true, category: terraform}]`,
+			Expect: VariablesInput{
+				{Key: "foo", Value: "1.0", Category: "terraform"},
+				{Key: "bar", Value: "true", Category: "terraform"},
+			},
+		},
+		{
+			Description: "value from an environment variable",
+			Input:       `[{key: foo, value_from: {env: FOO}, category: env, sensitive: true}]`,
+			Expect:      VariablesInput{{Key: "foo", ValueFrom: &VariableValueFrom{Env: "FOO"}, Category: "env", Sensitive: true}},
+		},
+		{
+			Description: "HCL string value",
+			Input:       `[{key: foo, value: '["a", "b"]', category: terraform, hcl: true}]`,
+			Expect:      VariablesInput{{Key: "foo", Value: `["a", "b"]`, Category: "terraform", HCL: true}},
+		},
+		{
+			Description: "list value is rendered to HCL",
+			Input: `
+- key: zones
+  value: [us-east-1a, us-east-1b]
+  category: terraform`,
+			Expect: VariablesInput{{Key: "zones", Value: `["us-east-1a", "us-east-1b"]`, Category: "terraform", HCL: true}},
+		},
+		{
+			Description: "map value is rendered to HCL",
+			Input: `
+- key: settings
+  description: nested settings
+  value:
+    size: 3
+    enabled: true
+    tags: [a, b]
+    "team name": "platform \"core\""
+    empty: null
+  category: terraform
+  sensitive: true`,
+			Expect: VariablesInput{{
+				Key:         "settings",
+				Value:       `{empty = null, enabled = true, size = 3, tags = ["a", "b"], "team name" = "platform \"core\""}`,
+				Description: "nested settings",
+				Category:    "terraform",
+				Sensitive:   true,
+				HCL:         true,
+			}},
+		},
+		{
+			Description: "template sequences are escaped",
+			Input: `
+- key: commands
+  value: ["echo ${HOME}", "100%{x}", "$5 and 10%"]
+  category: terraform`,
+			Expect: VariablesInput{{Key: "commands", Value: `["echo $$${HOME}", "100%%%{x}", "$5 and 10%"]`, Category: "terraform", HCL: true}},
+		},
+	} {
+		t.Run(testCase.Description, func(t *testing.T) {
+			var inputs VariablesInput
+
+			require.NoError(t, yaml.Unmarshal([]byte(testCase.Input), &inputs))
+			assert.Equal(t, testCase.Expect, inputs)
+
+			var v3Inputs VariablesInput
+
+			require.NoError(t, yamlv3.Unmarshal([]byte(testCase.Input), &v3Inputs))
+			assert.Equal(t, testCase.Expect, v3Inputs)
+		})
+	}
+
+	t.Run("template sequences are kept after Terraform evaluates the value", func(t *testing.T) {
+		var inputs VariablesInput
+
+		require.NoError(t, yamlv3.Unmarshal([]byte(`[{key: commands, value: ["echo ${HOME}", "100%{x}"], category: terraform}]`), &inputs))
+
+		// Terraform evaluates the main.tf.json string once, leaving the escaped HCL value stored in Terraform Cloud
+		evaluated := strings.NewReplacer("$${", "${", "%%{", "%{").Replace(inputs[0].Value)
+		assert.Equal(t, `["echo $${HOME}", "100%%{x}"]`, evaluated)
+	})
+
+	t.Run("return decoding errors", func(t *testing.T) {
+		var inputs VariablesInput
+
+		assert.Error(t, yaml.Unmarshal([]byte(`[{key: foo, value: [a], sensitive: maybe}]`), &inputs))
+	})
+}
+
+func TestVariableToResource(t *testing.T) {
+	v := NewVariable(VariablesInputItem{Key: "zones", Value: `["a"]`, Category: "terraform", HCL: true}, newTestWorkspace())
+
+	assert.Equal(t, &tfeprovider.Variable{
+		Key:         "zones",
+		Value:       `["a"]`,
+		Category:    "terraform",
+		HCL:         true,
+		WorkspaceID: "${tfe_workspace.workspace[\"default\"].id}",
+	}, v.ToResource())
+}
+
+func TestToVariable(t *testing.T) {
+	workspace := newTestWorkspace()
+
+	v := ToVariable(&tfe.Variable{
+		ID:       "var-abc123",
+		Key:      "zones",
+		Value:    `["a"]`,
+		Category: tfe.CategoryTerraform,
+		HCL:      true,
+	}, workspace)
+
+	assert.Equal(t, &Variable{
+		Key:       "zones",
+		Value:     `["a"]`,
+		Category:  "terraform",
+		HCL:       true,
+		Workspace: workspace,
+	}, v)
+}
+
+func TestVariablesInputResolveValues(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(path.Join(dir, "secret.txt"), []byte("file-secret\n"), 0600))
+	require.NoError(t, os.WriteFile(path.Join(dir, "secrets.json"), []byte(`{"password": "json-secret", "ports": [80, 443]}`), 0600))
+
+	t.Setenv("TEST_VARIABLE_SECRET", "env-secret")
+
+	t.Run("resolve values from every source", func(t *testing.T) {
+		inputs := VariablesInput{
+			{Key: "inline", Value: "bar", Category: "env"},
+			{Key: "env", ValueFrom: &VariableValueFrom{Env: "TEST_VARIABLE_SECRET"}, Category: "env", Sensitive: true},
+			{Key: "file", ValueFrom: &VariableValueFrom{File: path.Join(dir, "secret.txt")}, Category: "env"},
+			{Key: "json", ValueFrom: &VariableValueFrom{File: path.Join(dir, "secrets.json"), JSONKey: "password"}, Category: "terraform"},
+			{Key: "ports", ValueFrom: &VariableValueFrom{File: path.Join(dir, "secrets.json"), JSONKey: "ports"}, Category: "terraform", HCL: true},
+		}
+
+		require.NoError(t, inputs.ResolveValues())
+
+		values := []string{}
+		for _, v := range inputs {
+			values = append(values, v.Value)
+		}
+
+		assert.Equal(t, []string{"bar", "env-secret", "file-secret", "json-secret", "[80,443]"}, values)
+
+		sensitive := []bool{}
+		for _, v := range inputs {
+			sensitive = append(sensitive, v.Sensitive)
+		}
+
+		assert.Equal(t, []bool{false, true, true, true, true}, sensitive)
+	})
+
+	for _, testCase := range []struct {
+		Description string
+		Input       VariablesInputItem
+		Expect      string
+	}{
+		{
+			Description: "reject both value and value_from",
+			Input:       VariablesInputItem{Key: "foo", Value: "bar", ValueFrom: &VariableValueFrom{Env: "TEST_VARIABLE_SECRET"}},
+			Expect:      "variable \"foo\" cannot set both value and value_from",
+		},
+		{
+			Description: "reject multiple sources",
+			Input:       VariablesInputItem{Key: "foo", ValueFrom: &VariableValueFrom{Env: "TEST_VARIABLE_SECRET", File: "secret.txt"}},
+			Expect:      "failed to resolve the value of variable \"foo\": only one of env or file can be set",
+		},
+		{
+			Description: "reject empty sources",
+			Input:       VariablesInputItem{Key: "foo", ValueFrom: &VariableValueFrom{}},
+			Expect:      "failed to resolve the value of variable \"foo\": one of env or file must be set",
+		},
+		{
+			Description: "reject JSON keys for environment variables",
+			Input:       VariablesInputItem{Key: "foo", ValueFrom: &VariableValueFrom{Env: "TEST_VARIABLE_SECRET", JSONKey: "password"}},
+			Expect:      "failed to resolve the value of variable \"foo\": json_key can only be used with file",
+		},
+		{
+			Description: "reject unset environment variables",
+			Input:       VariablesInputItem{Key: "foo", ValueFrom: &VariableValueFrom{Env: "TEST_VARIABLE_UNSET"}},
+			Expect:      "failed to resolve the value of variable \"foo\": environment variable \"TEST_VARIABLE_UNSET\" is not set",
+		},
+		{
+			Description: "reject missing JSON keys",
+			Input:       VariablesInputItem{Key: "foo", ValueFrom: &VariableValueFrom{File: path.Join(dir, "secrets.json"), JSONKey: "token"}},
+			Expect:      fmt.Sprintf("failed to resolve the value of variable \"foo\": key \"token\" not found in JSON file %q", path.Join(dir, "secrets.json")),
+		},
+	} {
+		t.Run(testCase.Description, func(t *testing.T) {
+			inputs := VariablesInput{testCase.Input}
+
+			assert.EqualError(t, inputs.ResolveValues(), testCase.Expect)
+		})
+	}
+}
@@ -23,16 +23,19 @@ type Workspace struct {
 
 // getVCSClientByName looks for a VCS client of the passed type against the VCS clients in the Terraform Cloud organization
 func getVCSClientByName(ctx context.Context, tfc *tfe.Client, organization string, vcsType string) (*tfe.OAuthClient, error) {
-	list, err := tfc.OAuthClients.List(ctx, organization, tfe.OAuthClientListOptions{
-		ListOptions: tfe.ListOptions{
-			PageSize: maxPageSize,
-		},
+	clients, err := listAll(ctx, func(ctx context.Context, options tfe.ListOptions) ([]*tfe.OAuthClient, *tfe.Pagination, error) {
+		list, err := tfc.OAuthClients.List(ctx, organization, &tfe.OAuthClientListOptions{ListOptions: options})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return list.Items, list.Pagination, nil
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	for _, v := range list.Items {
+	for _, v := range clients {
 		if v.ServiceProvider == tfe.ServiceProviderType(vcsType) {
 			return v, nil
 		}
@@ -55,6 +58,9 @@ func GetVCSTokenIDByClientType(ctx context.Context, tfc *tfe.Client, organizatio
 	return vcsClient.OAuthTokens[0].ID, nil
 }
 
+// vcsOAuthTokenRef references the OAuth token of the VCS client looked up by Terraform, used when the configuration is rendered without a Terraform Cloud client
+const vcsOAuthTokenRef = "${data.tfe_oauth_client.vcs.oauth_token_id}"
+
 type WorkspaceResourceOptions struct {
 	AgentPoolID            string
 	AutoApply              *bool
@@ -74,9 +80,61 @@ type WorkspaceResourceOptions struct {
 	VCSTokenID             string
 	VCSType                string
 	WorkingDirectory       string
+	WorkspaceSettings      map[string]WorkspaceSettingsInput
+}
+
+// WorkspaceSettingsInput holds the settings that can be overridden for a single workspace, unset values fall back to the shared settings
+type WorkspaceSettingsInput struct {
+	AgentPoolID      string `yaml:"agent_pool_id,omitempty"`
+	AutoApply        *bool  `yaml:"auto_apply,omitempty"`
+	Description      string `yaml:"description,omitempty"`
+	ExecutionMode    string `yaml:"execution_mode,omitempty"`
+	SSHKeyID         string `yaml:"ssh_key_id,omitempty"`
+	TerraformVersion string `yaml:"terraform_version,omitempty"`
+	WorkingDirectory string `yaml:"working_directory,omitempty"`
+}
+
+// merge returns the calling settings with any value set on the passed override replacing the original
+func (s WorkspaceSettingsInput) merge(override WorkspaceSettingsInput) WorkspaceSettingsInput {
+	if override.AgentPoolID != "" {
+		s.AgentPoolID = override.AgentPoolID
+		s.ExecutionMode = "agent"
+	}
+
+	if override.AutoApply != nil {
+		s.AutoApply = override.AutoApply
+	}
+
+	if override.Description != "" {
+		s.Description = override.Description
+	}
+
+	if override.ExecutionMode != "" {
+		s.ExecutionMode = override.ExecutionMode
+
+		// an agent pool only applies to the agent execution mode, so a shared pool doesn't apply to workspaces using another mode
+		if override.ExecutionMode != "agent" && override.AgentPoolID == "" {
+			s.AgentPoolID = ""
+		}
+	}
+
+	if override.SSHKeyID != "" {
+		s.SSHKeyID = override.SSHKeyID
+	}
+
+	if override.TerraformVersion != "" {
+		s.TerraformVersion = override.TerraformVersion
+	}
+
+	if override.WorkingDirectory != "" {
+		s.WorkingDirectory = override.WorkingDirectory
+	}
+
+	return s
 }
 
-// NewWorkspaceResource adds defaults and conditional fields to a WorkspaceWorkspaceResource struct
+// NewWorkspaceResource adds defaults and conditional fields to a WorkspaceWorkspaceResource struct.
+// Without a client, the VCS token is looked up by Terraform rather than through the API.
 func NewWorkspaceResource(ctx context.Context, client *tfe.Client, workspaces []*Workspace, config *WorkspaceResourceOptions) (*tfeprovider.Workspace, error) {
 	wsForEach := map[string]*tfeprovider.Workspace{}
 
@@ -92,10 +150,6 @@ func NewWorkspaceResource(ctx context.Context, client *tfe.Client, workspaces []
 		Organization: config.Organization,
 	}
 
-	if config.AutoApply != nil {
-		ws.AutoApply = config.AutoApply
-	}
-
 	var vcs *tfeprovider.VCSRepo
 
 	if config.VCSType != "" || config.VCSTokenID != "" {
@@ -104,7 +158,9 @@ func NewWorkspaceResource(ctx context.Context, client *tfe.Client, workspaces []
 		}
 
 		vcsTokenID := config.VCSTokenID
-		if vcsTokenID == "" {
+		if vcsTokenID == "" && client == nil {
+			vcsTokenID = vcsOAuthTokenRef
+		} else if vcsTokenID == "" {
 			t, err := GetVCSTokenIDByClientType(ctx, client, config.Organization, config.VCSType)
 			if err != nil {
 				return nil, err
@@ -124,13 +180,6 @@ func NewWorkspaceResource(ctx context.Context, client *tfe.Client, workspaces []
 
 	ws.VCSRepo = vcs
 
-	if config.AgentPoolID != "" {
-		ws.AgentPoolID = config.AgentPoolID
-		ws.ExecutionMode = "agent"
-	} else if config.ExecutionMode != "" {
-		ws.ExecutionMode = config.ExecutionMode
-	}
-
 	if config.GlobalRemoteState != nil {
 		ws.GlobalRemoteState = config.GlobalRemoteState
 
@@ -139,13 +188,13 @@ func NewWorkspaceResource(ctx context.Context, client *tfe.Client, workspaces []
 		}
 	}
 
-	ws.Description = config.Description
-	ws.TerraformVersion = config.TerraformVersion
 	ws.QueueAllRuns = config.QueueAllRuns
 	ws.SpeculativeEnabled = config.SpeculativeEnabled
 	ws.FileTriggersEnabled = config.FileTriggersEnabled
-	ws.SSHKeyID = config.SSHKeyID
-	ws.WorkingDirectory = config.WorkingDirectory
+
+	if err := SetWorkspaceSettings(ws, workspaces, config); err != nil {
+		return nil, err
+	}
 
 	if err := SetTags(ws, config.Tags); err != nil {
 		return nil, err
@@ -154,6 +203,130 @@ func NewWorkspaceResource(ctx context.Context, client *tfe.Client, workspaces []
 	return ws, nil
 }
 
+// SetWorkspaceSettings sets the settings that can differ per workspace on the passed workspace resource.
+// Settings shared by every workspace are set directly, while settings that differ are looked up by workspace key,
+// the same way workspace tags are.
+func SetWorkspaceSettings(module *tfeprovider.Workspace, workspaces []*Workspace, config *WorkspaceResourceOptions) error {
+	settings, err := EffectiveWorkspaceSettings(workspaces, config)
+	if err != nil {
+		return err
+	}
+
+	if module.AutoApply, err = workspaceSettingValue(settings, func(s WorkspaceSettingsInput) interface{} {
+		if s.AutoApply == nil {
+			return nil
+		}
+
+		return *s.AutoApply
+	}); err != nil {
+		return err
+	}
+
+	for _, attr := range []struct {
+		Target *string
+		Value  func(WorkspaceSettingsInput) string
+	}{
+		{&module.AgentPoolID, func(s WorkspaceSettingsInput) string { return s.AgentPoolID }},
+		{&module.Description, func(s WorkspaceSettingsInput) string { return s.Description }},
+		{&module.ExecutionMode, func(s WorkspaceSettingsInput) string { return s.ExecutionMode }},
+		{&module.SSHKeyID, func(s WorkspaceSettingsInput) string { return s.SSHKeyID }},
+		{&module.TerraformVersion, func(s WorkspaceSettingsInput) string { return s.TerraformVersion }},
+		{&module.WorkingDirectory, func(s WorkspaceSettingsInput) string { return s.WorkingDirectory }},
+	} {
+		value := attr.Value
+
+		v, err := workspaceSettingValue(settings, func(s WorkspaceSettingsInput) interface{} {
+			if value(s) == "" {
+				return nil
+			}
+
+			return value(s)
+		})
+		if err != nil {
+			return err
+		}
+
+		if v != nil {
+			*attr.Target = v.(string)
+		}
+	}
+
+	return nil
+}
+
+// EffectiveWorkspaceSettings returns the settings of each workspace, keyed by workspace, with per-workspace overrides merged over the shared settings
+func EffectiveWorkspaceSettings(workspaces []*Workspace, config *WorkspaceResourceOptions) (map[string]WorkspaceSettingsInput, error) {
+	for wsName := range config.WorkspaceSettings {
+		if FindWorkspace(workspaces, wsName) == nil {
+			return nil, fmt.Errorf("settings specified for unknown workspace %q", wsName)
+		}
+	}
+
+	settings := map[string]WorkspaceSettingsInput{}
+
+	for _, ws := range workspaces {
+		s := WorkspaceSettingsInput{
+			AgentPoolID:      config.AgentPoolID,
+			AutoApply:        config.AutoApply,
+			Description:      config.Description,
+			ExecutionMode:    config.ExecutionMode,
+			SSHKeyID:         config.SSHKeyID,
+			TerraformVersion: config.TerraformVersion,
+			WorkingDirectory: config.WorkingDirectory,
+		}.merge(config.WorkspaceSettings[ws.Workspace])
+
+		if s.AgentPoolID != "" && s.ExecutionMode != "" && s.ExecutionMode != "agent" {
+			return nil, fmt.Errorf("workspace %q sets agent_pool_id with the %q execution mode, an agent pool requires the \"agent\" execution mode", ws.Workspace, s.ExecutionMode)
+		}
+
+		if s.AgentPoolID != "" {
+			s.ExecutionMode = "agent"
+		}
+
+		settings[ws.Workspace] = s
+	}
+
+	return settings, nil
+}
+
+// workspaceSettingValue returns the setting value if it is the same for every workspace, otherwise an expression looking up the value by workspace key.
+// Workspaces without a value for the setting resolve to null.
+func workspaceSettingValue(settings map[string]WorkspaceSettingsInput, value func(WorkspaceSettingsInput) interface{}) (interface{}, error) {
+	values := map[string]interface{}{}
+
+	var first interface{}
+
+	uniform := true
+	i := 0
+
+	for wsName, s := range settings {
+		v := value(s)
+
+		if i == 0 {
+			first = v
+		} else if v != first {
+			uniform = false
+		}
+
+		if v != nil {
+			values[wsName] = v
+		}
+
+		i++
+	}
+
+	if uniform {
+		return first, nil
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal workspace settings: %w", err)
+	}
+
+	return fmt.Sprintf("${lookup(%s, each.key, null)}", string(b)), nil
+}
+
 type Tag string
 type Tags []Tag
 
@@ -265,6 +438,7 @@ type NewWorkspaceConfigOptions struct {
 	TeamAccess               TeamAccess
 	RunTriggers              RunTriggers
 	Notifications            []*Notification
+	VariableSets             []*VariableSet
 	WorkspaceResourceOptions *WorkspaceResourceOptions
 	Providers                []Provider
 }
@@ -289,6 +463,13 @@ func NewWorkspaceConfig(ctx context.Context, client *tfe.Client, workspaces []*W
 
 	module.AppendResource("tfe_workspace", "workspace", wsResource)
 
+	if wsResource.VCSRepo != nil && wsResource.VCSRepo.OauthTokenID == vcsOAuthTokenRef {
+		module.AppendData("tfe_oauth_client", "vcs", tfeprovider.DataOAuthClient{
+			Organization:    wsResource.Organization,
+			ServiceProvider: config.WorkspaceResourceOptions.VCSType,
+		})
+	}
+
 	if config.Backend != nil {
 		module.Terraform.Backend = config.Backend
 	}
@@ -301,14 +482,14 @@ func NewWorkspaceConfig(ctx context.Context, client *tfe.Client, workspaces []*W
 		module.AppendResource("tfe_variable", fmt.Sprintf("%s-%s", v.Workspace.Workspace, v.Key), v.ToResource())
 	}
 
-	for _, n := range config.Notifications {
-		module.AppendResource("tfe_notification_configuration", n.Workspace.Workspace, n.ToResource())
-	}
+	AppendNotifications(module, config.Notifications)
 
 	AppendRunTriggers(module, config.RunTriggers)
 
 	AppendTeamAccess(module, config.TeamAccess, wsResource.Organization)
 
+	AppendVariableSets(module, config.VariableSets, wsResource.Organization)
+
 	AddProviders(module, config.Providers)
 
 	return module, nil
@@ -329,12 +510,12 @@ func WriteModuleFile(module *tfconfig.Module, filePath string) error {
 }
 
 // TerraformInit updates the current configuration using the passed module and runs "terraform init"
-func TerraformInit(ctx context.Context, tf *tfexec.Terraform, module *tfconfig.Module, filePath string) error {
+func TerraformInit(ctx context.Context, tf *tfexec.Terraform, module *tfconfig.Module, filePath string, opts ...tfexec.InitOption) error {
 	if err := WriteModuleFile(module, filePath); err != nil {
 		return err
 	}
 
-	if err := tf.Init(ctx); err != nil {
+	if err := tf.Init(ctx, opts...); err != nil {
 		return err
 	}
 
@@ -376,6 +557,18 @@ func WillDestroy(plan *tfjson.Plan, targetType string) bool {
 	return false
 }
 
+// scopedResourceTypes are the workspace resources keyed by resourceKey, the other workspace resources are keyed by "<workspace>-<name>"
+var scopedResourceTypes = map[string]bool{
+	"tfe_notification_configuration": true,
+	"tfe_workspace_variable_set":     true,
+}
+
+// resourceKey returns the for_each key of the named resource within the passed scope, a workspace or a variable set.
+// Workspace names and variable keys can't contain a slash, so keys of different scopes can't collide.
+func resourceKey(scope string, name string) string {
+	return scope + "/" + name
+}
+
 // FindWorkspace returns a workspace that matches the passed Terraform workspace identifier (not the workspace name)
 func FindWorkspace(workspaces []*Workspace, target string) *Workspace {
 	for _, v := range workspaces {
@@ -275,6 +275,18 @@ func TestNewWorkspaceResource(t *testing.T) {
 		assert.Equal(t, ws.VCSRepo.Identifier, "org/repo")
 	})
 
+	t.Run("look up the VCS token with a data source without a client", func(t *testing.T) {
+		ws, err := NewWorkspaceResource(ctx, nil, newTestSingleWorkspaceList(), &WorkspaceResourceOptions{
+			Organization: "org",
+			VCSType:      "github",
+			VCSRepo:      "org/repo",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "${data.tfe_oauth_client.vcs.oauth_token_id}", ws.VCSRepo.OauthTokenID)
+	})
+
 	t.Run("fail if vcs_repo is not passed", func(t *testing.T) {
 		_, err := NewWorkspaceResource(ctx, client, newTestSingleWorkspaceList(), &WorkspaceResourceOptions{
 			Organization: "org",
@@ -357,6 +369,97 @@ func TestNewWorkspaceResource(t *testing.T) {
 
 		assert.Equal(t, ws.Description, "description")
 	})
+
+	t.Run("set settings directly when workspace settings match", func(t *testing.T) {
+		ws, err := NewWorkspaceResource(ctx, client, newTestMultiWorkspaceList(), &WorkspaceResourceOptions{
+			Organization:     "org",
+			AutoApply:        boolPtr(true),
+			WorkingDirectory: "terraform",
+			WorkspaceSettings: map[string]WorkspaceSettingsInput{
+				"staging":    {AutoApply: boolPtr(false)},
+				"production": {AutoApply: boolPtr(false)},
+			},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, false, ws.AutoApply)
+		assert.Equal(t, "terraform", ws.WorkingDirectory)
+	})
+
+	t.Run("look up settings by workspace when workspace settings differ", func(t *testing.T) {
+		ws, err := NewWorkspaceResource(ctx, client, newTestMultiWorkspaceList(), &WorkspaceResourceOptions{
+			Organization:     "org",
+			Description:      "shared",
+			WorkingDirectory: "terraform",
+			WorkspaceSettings: map[string]WorkspaceSettingsInput{
+				"staging": {
+					AutoApply:        boolPtr(true),
+					WorkingDirectory: "terraform/staging",
+				},
+				"production": {
+					AutoApply:        boolPtr(false),
+					TerraformVersion: "1.1.0",
+					WorkingDirectory: "terraform/production",
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "${lookup({\"production\":false,\"staging\":true}, each.key, null)}", ws.AutoApply)
+		assert.Equal(t, "${lookup({\"production\":\"terraform/production\",\"staging\":\"terraform/staging\"}, each.key, null)}", ws.WorkingDirectory)
+		assert.Equal(t, "${lookup({\"production\":\"1.1.0\"}, each.key, null)}", ws.TerraformVersion)
+		assert.Equal(t, "shared", ws.Description)
+	})
+
+	t.Run("set agent execution mode for workspaces with an agent pool", func(t *testing.T) {
+		ws, err := NewWorkspaceResource(ctx, client, newTestMultiWorkspaceList(), &WorkspaceResourceOptions{
+			Organization:  "org",
+			ExecutionMode: "remote",
+			WorkspaceSettings: map[string]WorkspaceSettingsInput{
+				"production": {AgentPoolID: "apool-123"},
+			},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "${lookup({\"production\":\"apool-123\"}, each.key, null)}", ws.AgentPoolID)
+		assert.Equal(t, "${lookup({\"production\":\"agent\",\"staging\":\"remote\"}, each.key, null)}", ws.ExecutionMode)
+	})
+
+	t.Run("clear a shared agent pool for workspaces overriding the execution mode", func(t *testing.T) {
+		ws, err := NewWorkspaceResource(ctx, client, newTestMultiWorkspaceList(), &WorkspaceResourceOptions{
+			Organization: "org",
+			AgentPoolID:  "apool-123",
+			WorkspaceSettings: map[string]WorkspaceSettingsInput{
+				"staging": {ExecutionMode: "remote"},
+			},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "${lookup({\"production\":\"apool-123\"}, each.key, null)}", ws.AgentPoolID)
+		assert.Equal(t, "${lookup({\"production\":\"agent\",\"staging\":\"remote\"}, each.key, null)}", ws.ExecutionMode)
+	})
+
+	t.Run("fail if an agent pool is set with another execution mode", func(t *testing.T) {
+		_, err := NewWorkspaceResource(ctx, client, newTestMultiWorkspaceList(), &WorkspaceResourceOptions{
+			Organization: "org",
+			WorkspaceSettings: map[string]WorkspaceSettingsInput{
+				"staging": {AgentPoolID: "apool-123", ExecutionMode: "local"},
+			},
+		})
+
+		assert.EqualError(t, err, "workspace \"staging\" sets agent_pool_id with the \"local\" execution mode, an agent pool requires the \"agent\" execution mode")
+	})
+
+	t.Run("fail if settings are passed for an unknown workspace", func(t *testing.T) {
+		_, err := NewWorkspaceResource(ctx, client, newTestMultiWorkspaceList(), &WorkspaceResourceOptions{
+			Organization: "org",
+			WorkspaceSettings: map[string]WorkspaceSettingsInput{
+				"playground": {AutoApply: boolPtr(true)},
+			},
+		})
+
+		assert.EqualError(t, err, "settings specified for unknown workspace \"playground\"")
+	})
 }
 
 func TestNewWorkspaceResourceWithTags(t *testing.T) {
@@ -594,6 +697,30 @@ func TestNewWorkspaceConfig(t *testing.T) {
 		assert.Equal(t, output.Valid, true, output.Diagnostics)
 	})
 
+	t.Run("validate notification configuration for a workspace to be created", func(t *testing.T) {
+		workspace := &Workspace{Name: "ws", Workspace: "default"}
+
+		wsConfig, err := NewWorkspaceConfig(ctx, client, []*Workspace{workspace}, &NewWorkspaceConfigOptions{
+			WorkspaceResourceOptions: &WorkspaceResourceOptions{
+				Organization: "org",
+			},
+			Notifications: []*Notification{{
+				Workspace: workspace,
+				Input: &NotificationInput{
+					Name:            "my-notification",
+					DestinationType: "email",
+					EmailAddresses:  []string{"email@foo.com"},
+				},
+			}},
+		})
+		require.NoError(t, err)
+
+		output, err := RunValidate(ctx, name, execPath, wsConfig)
+		require.NoError(t, err)
+
+		assert.Equal(t, output.Valid, true, output.Diagnostics)
+	})
+
 	t.Run("validate basic workspace config", func(t *testing.T) {
 		wsConfig, err := NewWorkspaceConfig(ctx, client, newTestSingleWorkspaceList(), &NewWorkspaceConfigOptions{
 			WorkspaceResourceOptions: &WorkspaceResourceOptions{
@@ -776,6 +903,13 @@ func TestNewWorkspaceConfig(t *testing.T) {
 					Category:  "env",
 					Workspace: workspace,
 				},
+				Variable{
+					Key:       "zones",
+					Value:     `["us-east-1a", "us-east-1b"]`,
+					Category:  "terraform",
+					HCL:       true,
+					Workspace: workspace,
+				},
 			},
 		})
 		if err != nil {
@@ -812,6 +946,24 @@ func TestNewWorkspaceConfig(t *testing.T) {
 		assert.Equal(t, output.Valid, true, output.Diagnostics)
 	})
 
+	t.Run("validate workspaces with workspace settings", func(t *testing.T) {
+		module, err := NewWorkspaceConfig(ctx, client, newTestMultiWorkspaceList(), &NewWorkspaceConfigOptions{
+			WorkspaceResourceOptions: &WorkspaceResourceOptions{
+				Organization: "org",
+				WorkspaceSettings: map[string]WorkspaceSettingsInput{
+					"staging":    {AutoApply: boolPtr(true), WorkingDirectory: "staging"},
+					"production": {AutoApply: boolPtr(false), ExecutionMode: "local"},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		output, err := RunValidate(ctx, name, execPath, module)
+		require.NoError(t, err)
+
+		assert.Equal(t, output.Valid, true, output.Diagnostics)
+	})
+
 	t.Run("validate workspaces run triggers", func(t *testing.T) {
 		workspaces := newTestMultiWorkspaceList()
 
@@ -862,7 +1014,7 @@ func TestWillDestroy(t *testing.T) {
 
 		defer os.RemoveAll(workDir)
 
-		tf, err := NewTerraformExec(ctx, workDir, "1.0.3")
+		tf, err := NewTerraformExec(ctx, workDir, &TerraformInstallOptions{Version: "1.0.3"})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -915,7 +1067,7 @@ func TestWillDestroy(t *testing.T) {
 
 		defer os.RemoveAll(workDir)
 
-		tf, err := NewTerraformExec(ctx, workDir, "1.0.3")
+		tf, err := NewTerraformExec(ctx, workDir, &TerraformInstallOptions{Version: "1.0.3"})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -970,7 +1122,7 @@ func TestWillDestroy(t *testing.T) {
 
 		defer os.RemoveAll(workDir)
 
-		tf, err := NewTerraformExec(ctx, workDir, "1.0.3")
+		tf, err := NewTerraformExec(ctx, workDir, &TerraformInstallOptions{Version: "1.0.3"})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -1121,3 +1273,9 @@ func TestMergeWorkspaceTags(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestResourceKey(t *testing.T) {
+	assert.NotEqual(t, resourceKey("a", "b_c"), resourceKey("a_b", "c"))
+	assert.NotEqual(t, resourceKey("a", "b-c"), resourceKey("a-b", "c"))
+	assert.Equal(t, "staging/aws", resourceKey("staging", "aws"))
+}
@@ -0,0 +1,7 @@
+package tfconfig
+
+// Import is an import block, importing the existing resource with the ID into the resource at the To address
+type Import struct {
+	To string `json:"to"`
+	ID string `json:"id"`
+}
@@ -6,6 +6,7 @@ type Module struct {
 	Resources map[string]map[string]interface{} `json:"resource,omitempty"`
 	Data      map[string]map[string]interface{} `json:"data,omitempty"`
 	Providers map[string]ProviderConfig         `json:"provider,omitempty"`
+	Imports   []Import                          `json:"import,omitempty"`
 }
 
 // AppendData appends a data source of type "sourceType" with name "name" to the workspace's data configuration
@@ -0,0 +1,6 @@
+package tfeprovider
+
+type DataOAuthClient struct {
+	Organization    string `json:"organization"`
+	ServiceProvider string `json:"service_provider"`
+}
@@ -8,4 +8,5 @@ type Variable struct {
 	Category    string `json:"category,omitempty"`
 	WorkspaceID string `json:"workspace_id,omitempty"`
 	Sensitive   bool   `json:"sensitive,omitempty"`
+	HCL         bool   `json:"hcl,omitempty"`
 }
@@ -0,0 +1,26 @@
+package tfeprovider
+
+type VariableSet struct {
+	ForEach      map[string]VariableSet `json:"for_each,omitempty"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	Global       interface{}            `json:"global,omitempty"`
+	Organization string                 `json:"organization"`
+}
+
+type VariableSetVariable struct {
+	ForEach       map[string]VariableSetVariable `json:"for_each,omitempty"`
+	Key           string                         `json:"key"`
+	Value         string                         `json:"value"`
+	Description   string                         `json:"description,omitempty"`
+	Category      string                         `json:"category,omitempty"`
+	VariableSetID string                         `json:"variable_set_id"`
+	Sensitive     interface{}                    `json:"sensitive,omitempty"`
+	HCL           interface{}                    `json:"hcl,omitempty"`
+}
+
+type WorkspaceVariableSet struct {
+	ForEach       map[string]WorkspaceVariableSet `json:"for_each,omitempty"`
+	VariableSetID string                          `json:"variable_set_id"`
+	WorkspaceID   string                          `json:"workspace_id"`
+}
@@ -4,7 +4,7 @@ type Workspace struct {
 	ForEach map[string]*Workspace `json:"for_each,omitempty"`
 
 	AgentPoolID            string      `json:"agent_pool_id,omitempty"`
-	AutoApply              *bool       `json:"auto_apply,omitempty"`
+	AutoApply              interface{} `json:"auto_apply,omitempty"`
 	Description            string      `json:"description,omitempty"`
 	ExecutionMode          string      `json:"execution_mode,omitempty"`
 	FileTriggersEnabled    *bool       `json:"file_triggers_enabled,omitempty"`
@@ -13,14 +13,21 @@ func main() {
 		Token:                     githubactions.GetInput("terraform_token"),
 		Host:                      githubactions.GetInput("terraform_host"),
 		Name:                      strings.TrimSpace(githubactions.GetInput("name")),
+		PreviousName:              strings.TrimSpace(githubactions.GetInput("previous_name")),
 		Description:               githubactions.GetInput("description"),
 		Tags:                      githubactions.GetInput("tags"),
 		WorkspaceTags:             githubactions.GetInput("workspace_tags"),
 		Organization:              githubactions.GetInput("terraform_organization"),
 		Apply:                     inputs.GetBool("apply"),
 		RunnerTerraformVersion:    githubactions.GetInput("runner_terraform_version"),
+		TerraformPath:             githubactions.GetInput("terraform_path"),
+		TerraformMirrorURL:        githubactions.GetInput("terraform_mirror_url"),
+		TerraformCacheDir:         githubactions.GetInput("terraform_cache_dir"),
+		ProviderCacheDir:          githubactions.GetInput("provider_cache_dir"),
+		ProviderMirror:            githubactions.GetInput("provider_mirror"),
 		RemoteStates:              githubactions.GetInput("remote_states"),
 		Workspaces:                githubactions.GetInput("workspaces"),
+		WorkspaceRenames:          githubactions.GetInput("workspace_renames"),
 		Variables:                 githubactions.GetInput("variables"),
 		WorkspaceVariables:        githubactions.GetInput("workspace_variables"),
 		TeamAccess:                githubactions.GetInput("team_access"),
@@ -46,6 +53,19 @@ func main() {
 		TFEProviderVersion:        githubactions.GetInput("tfe_provider_version"),
 		Import:                    inputs.GetBool("import"),
 		AllowWorkspaceDeletion:    inputs.GetBool("allow_workspace_deletion"),
+		DestroyProtection:         githubactions.GetInput("destroy_protection"),
+		ConfigFile:                githubactions.GetInput("config_file"),
+		WorkspaceSettings:         githubactions.GetInput("workspace_settings"),
+		WorkspaceNotifications:    githubactions.GetInput("workspace_notifications"),
+		VariableSets:              githubactions.GetInput("variable_sets"),
+		Mode:                      githubactions.GetInput("mode"),
+		Engine:                    githubactions.GetInput("engine"),
+		PlanArtifact:              githubactions.GetInput("plan_artifact"),
+		OutputDirectory:           githubactions.GetInput("output_directory"),
+		TerraformLogLevel:         githubactions.GetInput("terraform_log_level"),
+		PRComment:                 inputs.GetBool("pr_comment"),
+		GitHubToken:               githubactions.GetInput("github_token"),
+		GitHubAPIURL:              githubactions.GetInput("github_api_url"),
 	}); err != nil {
 		githubactions.Fatalf("Error: %s", err)
 	}